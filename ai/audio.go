@@ -0,0 +1,93 @@
+package ai
+
+import (
+	"Brainy/lib/sl"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// TranscriptionProvider converts speech to text
+type TranscriptionProvider interface {
+	Transcribe(ctx context.Context, request *TranscriptionRequest) (*Transcription, error)
+}
+
+type TranscriptionRequest struct {
+	Model    string
+	FileName string // the extension tells the audio format
+	Audio    []byte
+	Language string // ISO-639-1 hint, optional
+}
+
+type Transcription struct {
+	Text  string              `json:"text"`
+	Usage *TranscriptionUsage `json:"usage"`
+	Error *Error              `json:"error"`
+}
+
+// TranscriptionUsage is reported in tokens by token-billed models, in seconds of audio by the others
+type TranscriptionUsage struct {
+	Type         string  `json:"type"` // "tokens" or "duration"
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	Seconds      float64 `json:"seconds"`
+}
+
+// Transcribe uploads the audio to the Whisper-style transcription endpoint
+func (p *OpenAIProvider) Transcribe(ctx context.Context, request *TranscriptionRequest) (*Transcription, error) {
+	fields := map[string]string{
+		"model":           request.Model,
+		"response_format": "json",
+	}
+	if request.Language != "" {
+		fields["language"] = request.Language
+	}
+	files := []formFile{{Field: "file", Name: request.FileName, Data: request.Audio}}
+
+	var transcription Transcription
+	if err := p.postMultipart(ctx, p.audioURL+"/audio/transcriptions", fields, files, &transcription); err != nil {
+		return nil, err
+	}
+	return &transcription, nil
+}
+
+// SpeechProvider converts text to speech
+type SpeechProvider interface {
+	Speak(ctx context.Context, request *SpeechRequest) ([]byte, error)
+}
+
+type SpeechRequest struct {
+	Model          string `json:"model"`
+	Input          string `json:"input"`
+	Voice          string `json:"voice"`
+	ResponseFormat string `json:"response_format,omitempty"` // opus, mp3, aac, flac, wav or pcm
+}
+
+// Speak sends a speech request and returns the audio bytes
+func (p *OpenAIProvider) Speak(ctx context.Context, request *SpeechRequest) ([]byte, error) {
+	jsonBytes, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling request: %w", err)
+	}
+
+	resp, err := p.do(ctx, p.baseURL+"/audio/speech", "application/json", jsonBytes)
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			p.log.Error("closing response body", sl.Err(err))
+		}
+	}(resp.Body)
+
+	audio, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading audio: %w", err)
+	}
+	if len(audio) == 0 {
+		return nil, fmt.Errorf("empty audio in response")
+	}
+	return audio, nil
+}
@@ -3,15 +3,16 @@ package ai
 import (
 	"Brainy/core"
 	"Brainy/holder"
+	"Brainy/lib/intent"
 	"Brainy/lib/sl"
+	"Brainy/lib/tokenizer"
 	"Brainy/storage"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
-	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -19,22 +20,53 @@ type ChatGPT struct {
 	conf           *core.Config
 	log            *slog.Logger
 	contextManager *holder.ContextManager
-	httpClient     *http.Client
+	provider       Provider
+	tools          *ToolRegistry
 	prefsAnalyzer  *PreferencesAnalyzer
+	usage          *UsageTracker
+	files          core.FileLoader
+	settings       storage.SettingsStorage
+	images         storage.ImageStorage
+	imageSender    core.ImageSender
+	vectors        storage.VectorStorage
+	documents      storage.DocumentStorage
+	fetcher        *Fetcher
+	moderation     storage.ModerationStorage
+	moderated      sync.Map // last moderation decision by chat ID
+	summarizing    sync.Map // chat IDs with summarization in progress
+	intents        intentCounts
 }
 
-func NewChat(conf *core.Config, log *slog.Logger, store storage.ContextStorage) *ChatGPT {
-	return &ChatGPT{
+func NewChat(conf *core.Config, log *slog.Logger, store storage.ContextStorage, provider Provider) *ChatGPT {
+	tools := NewToolRegistry()
+	RegisterBuiltinTools(tools, conf.Timezone)
+
+	c := &ChatGPT{
 		conf:           conf,
 		log:            log.With(sl.Module("chat-gpt")),
 		contextManager: holder.NewContextManager(store),
-		httpClient: &http.Client{
-			Timeout: 60 * time.Second,
-		},
-	}
+		provider:       provider,
+		tools:          tools,
+	}
+	// stored turns are counted with the model answering in the chat
+	c.contextManager.SetTokenCounter(func(chatId int64, text string) int {
+		return c.chatEncoding(chatId).Count(text)
+	})
+	return c
+}
+
+// chatEncoding returns the token encoding of the model answering in the chat
+func (c *ChatGPT) chatEncoding(chatId int64) *tokenizer.Encoding {
+	return tokenizer.ForModel(c.ChatModel(chatId))
+}
+
+// Tools returns the registry of functions the model can call, register more tools there
+func (c *ChatGPT) Tools() *ToolRegistry {
+	return c.tools
 }
 
 func (c *ChatGPT) Close() error {
+	c.logIntents()
 	return c.contextManager.Close()
 }
 
@@ -42,145 +74,182 @@ func (c *ChatGPT) ClearContext(userId int64) {
 	c.contextManager.ClearUserContext(userId)
 }
 
-// GenerateImage generates an image using DALL-E API
-func (c *ChatGPT) GenerateImage(userId int64, prompt string) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-	defer cancel()
-
-	// Apply Futurama cartoon style to all generated images
-	styledPrompt := prompt + ". Style: cartoon animation like Futurama TV series, bold outlines, vibrant colors, Matt Groening art style"
-
-	request := NewImageRequest(styledPrompt)
-	jsonBytes, err := json.Marshal(request)
+// GenerateImage generates images using DALL-E API
+func (c *ChatGPT) GenerateImage(chatId, userId int64, options core.ImageOptions) ([]core.GeneratedImage, error) {
+	request, style, err := c.imageRequest(chatId, options)
 	if err != nil {
-		return "", fmt.Errorf("error marshalling image request: %v", err)
+		return nil, err
 	}
-	requestBody := strings.NewReader(string(jsonBytes))
-
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/images/generations", requestBody)
-	if err != nil {
-		return "", fmt.Errorf("making image request: %v", err)
+	if err := c.usage.CheckQuota(chatId, userId, core.QuotaImages, max(request.N, 1)); err != nil {
+		return nil, err
 	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.conf.OpenAIApiKey))
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("getting image response: %v", err)
+	if err := c.moderate(chatId, userId, core.ModerationImage, options.Prompt); err != nil {
+		return nil, err
 	}
 
-	defer func(Body io.ReadCloser) {
-		err = Body.Close()
-		if err != nil {
-			c.log.Error("closing image response body", sl.Err(err))
-		}
-	}(resp.Body)
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("reading image response body: %v", err)
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	defer cancel()
 
-	var imageResponse ImageGenerationResponse
-	err = json.Unmarshal(body, &imageResponse)
+	imageResponse, err := c.provider.GenerateImage(ctx, request)
 	if err != nil {
-		return "", fmt.Errorf("decoding image response: %v", err)
+		c.log.With(
+			slog.Int64("user", chatId),
+		).Error("image generation error", sl.Err(err))
+		return nil, fmt.Errorf("getting image response: %w", err)
 	}
 
 	if imageResponse.Error != nil {
 		c.log.With(
-			slog.Int64("user", userId),
+			slog.Int64("user", chatId),
 			slog.String("code", imageResponse.Error.Code),
 			slog.String("message", imageResponse.Error.Message),
 		).Error("image generation error")
-		return "", fmt.Errorf("image generation: %s", imageResponse.Error.Message)
+		return nil, fmt.Errorf("image generation: %w", newAPIError(0, imageResponse.Error))
 	}
 
 	if len(imageResponse.Data) == 0 {
-		return "", fmt.Errorf("image generation: empty response")
+		return nil, fmt.Errorf("image generation: empty response")
 	}
+	c.usage.RecordImages(chatId, userId, request.Model, len(imageResponse.Data))
 
-	imageURL := imageResponse.Data[0].URL
+	var images []core.GeneratedImage
+	for _, data := range imageResponse.Data {
+		image, err := imageBytes(ctx, data)
+		if err != nil {
+			return nil, fmt.Errorf("image generation: %w", err)
+		}
+		images = append(images, core.GeneratedImage{
+			Data:          image,
+			Prompt:        options.Prompt,
+			RevisedPrompt: data.RevisedPrompt,
+			Style:         style,
+			Model:         request.Model,
+			Path:          c.saveImage(chatId, image),
+		})
+	}
 	c.log.With(
-		slog.Int64("user", userId),
-		slog.String("prompt", prompt),
+		slog.Int64("user", chatId),
+		slog.String("prompt", options.Prompt),
+		slog.String("size", request.Size),
+		slog.Int("n", len(images)),
 	).Info("image generated")
 
-	return imageURL, nil
+	return images, nil
 }
 
-// DetectImageIntent uses GPT to detect if user wants to generate an image
-func (c *ChatGPT) DetectImageIntent(question string) (bool, string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+// imageRequest applies the style preset to the prompt and validates options against the image model;
+// empty options take the chat style and config defaults. Returns the request and the applied style
+func (c *ChatGPT) imageRequest(chatId int64, options core.ImageOptions) (*ImageGenerationRequest, string, error) {
+	style := options.Style
+	if style == "" {
+		style = c.ImageStyle(chatId)
+	}
+	suffix, ok := c.conf.ImageStyle(style)
+	if !ok {
+		return nil, "", &core.OptionError{Option: "style", Value: style, Allowed: c.conf.ImageStyleNames()}
+	}
+	prompt := options.Prompt
+	if suffix != "" {
+		prompt += ". Style: " + suffix
+	}
 
-	detectPrompt := `Analyze the following user message and determine if they want to generate/create an image.
-Respond in JSON format only: {"wants_image": true/false, "image_prompt": "optimized prompt for DALL-E if wants_image is true, otherwise empty string"}
+	model := c.conf.Images.Model
+	spec, known := imageSpecFor(model)
+	size := options.Size
+	if size == "" {
+		size = c.conf.Images.Size
+	}
+	size, err := spec.resolveSize(size)
+	if err != nil {
+		return nil, "", err
+	}
+	quality := options.Quality
+	if quality == "" {
+		quality = c.conf.Images.Quality
+	}
+	n := options.N
+	if n == 0 {
+		n = 1
+	}
+	maxN := defaultMaxImages
+	if known {
+		maxN = spec.maxN
+		if size != "" && !containsString(spec.sizes, size) {
+			return nil, "", &core.OptionError{Option: "size", Value: size, Allowed: spec.allowedSizes()}
+		}
+		if quality != "" && !containsString(spec.qualities, quality) {
+			return nil, "", &core.OptionError{Option: "quality", Value: quality, Allowed: spec.qualities}
+		}
+	}
+	if n < 1 || n > maxN {
+		return nil, "", &core.OptionError{Option: "n", Value: strconv.Itoa(n), Allowed: []string{fmt.Sprintf("1 to %d", maxN)}}
+	}
 
-Rules for detection:
-- "wants_image" should be true if user explicitly asks to create, generate, draw, paint, make, design, or visualize an image/picture/photo/illustration
-- "wants_image" should be true if user asks for visual content like "show me", "I want to see", "create a picture of"
-- "wants_image" should be false for questions about images, image editing, or general conversation
-- If wants_image is true, create an optimized detailed prompt for DALL-E image generation based on user's request
+	return NewImageRequest(prompt, model, size, quality, n), style, nil
+}
 
-User message: ` + question
+// IntentResponse is the structured answer of image intent detection
+type IntentResponse struct {
+	WantsImage  bool   `json:"wants_image" desc:"true if the user asks to create an image"`
+	ImagePrompt string `json:"image_prompt" desc:"optimized prompt for DALL-E if wants_image is true, otherwise empty string"`
+}
 
-	request := NewRequest(detectPrompt, c.conf.Model)
-	jsonBytes, err := json.Marshal(request)
-	if err != nil {
+// DetectImageIntent detects if user wants to generate an image; messages without drawing words are rejected
+// by the local classifier, the rest are decided by GPT
+func (c *ChatGPT) DetectImageIntent(chatId, userId int64, question string) (bool, string) {
+	if !c.ImageIntent(chatId) {
+		c.countIntent(chatId, intentDisabled)
 		return false, ""
 	}
-	requestBody := strings.NewReader(string(jsonBytes))
-
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/chat/completions", requestBody)
-	if err != nil {
+	// the classifier only rejects, keyword hits are confirmed by the model
+	if c.conf.ImageIntent.Classifier && intent.Classify(question) == intent.Reject {
+		c.countIntent(chatId, intentRejected)
 		return false, ""
 	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.conf.OpenAIApiKey))
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
+	if c.imageRouting(chatId) {
+		// the chat model decides with the generate_image tool in the answering call
+		c.countIntent(chatId, intentRouted)
 		return false, ""
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
+	// over quota users get the quota reply from GetResponse, don't spend on detection
+	if c.usage.CheckQuota(chatId, userId, core.QuotaTokens, 1) != nil {
 		return false, ""
 	}
-
-	var chatCompletion ChatCompletion
-	err = json.Unmarshal(body, &chatCompletion)
-	if err != nil || len(chatCompletion.Choices) == 0 {
+	// blocked messages get the refusal from GetResponse, which reuses this decision
+	if c.moderate(chatId, userId, core.ModerationPrompt, question) != nil {
 		return false, ""
 	}
 
-	// Parse the JSON response
-	type IntentResponse struct {
-		WantsImage  bool   `json:"wants_image"`
-		ImagePrompt string `json:"image_prompt"`
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
 
-	var intentResp IntentResponse
-	responseText := chatCompletion.Choices[0].Message.Content
-	// Try to extract JSON from the response
-	responseText = strings.TrimSpace(responseText)
-	if strings.HasPrefix(responseText, "```json") {
-		responseText = strings.TrimPrefix(responseText, "```json")
-		responseText = strings.TrimSuffix(responseText, "```")
-	}
-	responseText = strings.TrimSpace(responseText)
+	detectPrompt := `Analyze the following user message and determine if they want to generate/create an image.
 
-	err = json.Unmarshal([]byte(responseText), &intentResp)
+Rules for detection:
+- "wants_image" should be true if user explicitly asks to create, generate, draw, paint, make, design, or visualize an image/picture/photo/illustration
+- "wants_image" should be true if user asks for visual content like "show me", "I want to see", "create a picture of"
+- "wants_image" should be false for questions about images, image editing, or general conversation
+- If wants_image is true, create an optimized detailed prompt for DALL-E image generation based on user's request
+
+User message: ` + question
+
+	request := NewRequest(detectPrompt, c.conf.ModelFor(core.TaskClassify))
+	var response IntentResponse
+	err := completeStructured(ctx, c.provider, request, "image_intent", &response, func(completion *ChatCompletion) {
+		c.usage.RecordCompletion(chatId, userId, storage.UsageIntent, request.Model, completion)
+	})
 	if err != nil {
-		c.log.With(slog.String("response", responseText)).Debug("failed to parse intent response")
+		c.log.Warn("detecting image intent", sl.Err(err))
+		c.countIntent(chatId, intentFailed)
 		return false, ""
 	}
+	if response.WantsImage {
+		c.countIntent(chatId, intentConfirmed)
+	} else {
+		c.countIntent(chatId, intentDismissed)
+	}
 
-	return intentResp.WantsImage, intentResp.ImagePrompt
+	return response.WantsImage, response.ImagePrompt
 }
 
 // SetPreferencesAnalyzer sets the preferences analyzer for prompt injection
@@ -188,115 +257,205 @@ func (c *ChatGPT) SetPreferencesAnalyzer(pa *PreferencesAnalyzer) {
 	c.prefsAnalyzer = pa
 }
 
-func (c *ChatGPT) GetResponse(userId int64, question string) (string, error) {
+// SetUsageTracker sets the tracker recording consumption of every API call
+func (c *ChatGPT) SetUsageTracker(usage *UsageTracker) {
+	c.usage = usage
+}
+
+// GetUsage returns consumption of a user for the current day and month
+func (c *ChatGPT) GetUsage(userId int64) (today, month core.UsageTotals, err error) {
+	now := time.Now()
+	if today, err = c.usage.Totals(userId, startOfDay(now)); err != nil {
+		return today, month, err
+	}
+	month, err = c.usage.Totals(userId, startOfMonth(now))
+	return today, month, err
+}
+
+func (c *ChatGPT) GetResponse(chatId, userId int64, question string) (string, error) {
+	if err := c.usage.CheckQuota(chatId, userId, core.QuotaTokens, 1); err != nil {
+		return "", err
+	}
+	if err := c.moderate(chatId, userId, core.ModerationPrompt, question); err != nil {
+		return "", err
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
-	prompt := c.composePrompt(userId, question)
+	messages := c.composeMessages(chatId, userId, question)
 
-	request := NewRequest(prompt, c.conf.Model)
-	jsonBytes, err := json.Marshal(request)
+	model := c.modelFor(chatId, chatTask(question), messages)
+	request := NewChatRequest(c.fitHistory(chatId, model, messages), model)
+	chatCompletion, err := c.complete(ctx, chatId, userId, request, nil)
 	if err != nil {
-		return "", fmt.Errorf("error marshalling request: %v", err)
+		return "", fmt.Errorf("getting response: %w", err)
 	}
-	requestBody := strings.NewReader(string(jsonBytes))
+	return c.handleCompletion(chatId, userId, chatCompletion)
+}
 
-	// Create a new request with the ChatGPT API URL
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/chat/completions", requestBody)
-	if err != nil {
-		return "", fmt.Errorf("making request: %v", err)
+// GetResponseStream works like GetResponse but reports the growing answer text to onUpdate while it is generated
+func (c *ChatGPT) GetResponseStream(chatId, userId int64, question string, onUpdate func(partial string)) (string, error) {
+	if err := c.usage.CheckQuota(chatId, userId, core.QuotaTokens, 1); err != nil {
+		return "", err
+	}
+	if err := c.moderate(chatId, userId, core.ModerationPrompt, question); err != nil {
+		return "", err
 	}
 
-	// Add the Authorization header with your ChatGPT API key
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.conf.OpenAIApiKey))
-	req.Header.Set("Content-Type", "application/json")
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	defer cancel()
+
+	messages := c.composeMessages(chatId, userId, question)
 
-	// Send the request and get the response
-	resp, err := c.httpClient.Do(req)
+	var partial strings.Builder
+	model := c.modelFor(chatId, chatTask(question), messages)
+	request := NewChatRequest(c.fitHistory(chatId, model, messages), model)
+	chatCompletion, err := c.complete(ctx, chatId, userId, request, func(delta string) {
+		partial.WriteString(delta)
+		if onUpdate != nil {
+			onUpdate(partial.String())
+		}
+	})
 	if err != nil {
-		return "", fmt.Errorf("getting response: %v", err)
+		return "", fmt.Errorf("getting stream response: %w", err)
 	}
+	return c.handleCompletion(chatId, userId, chatCompletion)
+}
 
-	// Read the response body
-	defer func(Body io.ReadCloser) {
-		err = Body.Close()
+// complete sends the request and runs the tool-call loop: requested tools are executed and their
+// results fed back until the model answers; after MaxRounds tools are disabled to force an answer.
+// Completions are streamed when onDelta is set.
+func (c *ChatGPT) complete(ctx context.Context, chatId, userId int64, request *GPTRequest, onDelta func(delta string)) (*ChatCompletion, error) {
+	if c.conf.Tools.Enabled {
+		request.Tools = c.tools.Definitions()
+	}
+	if c.imageRouting(chatId) {
+		request.Tools = append(request.Tools, c.imageTool())
+	}
+	env := ToolEnv{ChatId: chatId, UserId: userId, Timezone: c.ChatTimezone(chatId)}
+
+	for round := 1; ; round++ {
+		if len(request.Tools) > 0 && round > c.conf.Tools.MaxRounds {
+			request.ToolChoice = "none"
+		}
+
+		var chatCompletion *ChatCompletion
+		var err error
+		if onDelta != nil {
+			chatCompletion, err = c.provider.CompleteStream(ctx, request, onDelta)
+		} else {
+			chatCompletion, err = c.provider.Complete(ctx, request)
+		}
 		if err != nil {
-			c.log.Error("closing response body", sl.Err(err))
+			return nil, err
 		}
-	}(resp.Body)
+		c.usage.RecordCompletion(chatId, userId, storage.UsageChat, request.Model, chatCompletion)
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("reading response body: %v", err)
-	}
-	//c.log.With(
-	//	slog.Int64("user", userId),
-	//	slog.String("body", string(body)),
-	//).Debug("response body")
+		if chatCompletion.Error != nil || len(chatCompletion.Choices) == 0 {
+			return chatCompletion, nil
+		}
+		message := chatCompletion.Choices[0].Message
+		if len(message.ToolCalls) == 0 || request.ToolChoice == "none" {
+			return chatCompletion, nil
+		}
 
-	// Parse the response JSON to get the generated text
-	// Here you'll need to adjust the code to parse the JSON response from ChatGPT and extract the generated text
-	var chatCompletion ChatCompletion
-	err = json.Unmarshal(body, &chatCompletion)
-	if err != nil {
-		return "", fmt.Errorf("decoding response: %v", err)
+		// stream index is not a part of the request schema
+		for i := range message.ToolCalls {
+			message.ToolCalls[i].Index = 0
+		}
+		request.Messages = append(request.Messages, message)
+		for _, call := range message.ToolCalls {
+			var result string
+			var err error
+			if call.Function.Name == imageToolName {
+				if err = c.drawImage(chatId, userId, call); err == nil {
+					// the picture is the answer, the turn ends without another round trip
+					chatCompletion.Choices[0].Message.ToolCalls = nil
+					return chatCompletion, nil
+				}
+				result = toolError(err)
+			} else {
+				result, err = c.tools.Execute(ctx, env, call)
+			}
+			log := c.log.With(
+				slog.Int64("user", chatId),
+				slog.String("tool", call.Function.Name),
+				slog.Int("round", round),
+			)
+			if err != nil {
+				log.Warn("tool call failed", sl.Err(err))
+			} else {
+				log.Debug("tool call")
+			}
+			request.Messages = append(request.Messages, Message{Role: RoleTool, ToolCallID: call.ID, Content: TextContent(result)})
+		}
 	}
+}
+
+// handleCompletion checks a completion for errors and stores the answer in the dialog context
+func (c *ChatGPT) handleCompletion(chatId, userId int64, chatCompletion *ChatCompletion) (string, error) {
 	if chatCompletion.Error != nil {
 		if chatCompletion.Error.Code != "" {
 			c.log.With(
-				slog.Int64("user", userId),
+				slog.Int64("user", chatId),
 				slog.String("code", chatCompletion.Error.Code),
 				slog.String("message", chatCompletion.Error.Message),
 			).Error("chat completion error")
-			return "", fmt.Errorf("chat completion: %s", chatCompletion.Error.Code)
+			return "", fmt.Errorf("chat completion: %w", newAPIError(0, chatCompletion.Error))
 		}
 	}
 	if len(chatCompletion.Choices) == 0 {
 		return "", fmt.Errorf("chat completion: empty choices")
 	}
-	response := chatCompletion.Choices[0].Message.Content
+	response := chatCompletion.Choices[0].Message.Content.String()
 
-	// add bot message to context
-	msg := holder.Message{
-		Text:   response,
-		IsUser: false,
+	// add bot message to context, an answer with a picture may have no text
+	if response != "" {
+		msg := holder.Message{
+			Text:   response,
+			IsUser: false,
+		}
+		c.contextManager.UpdateUserContext(chatId, msg)
+		c.rememberAsync(chatId, userId, false, response, nil)
 	}
-	c.contextManager.UpdateUserContext(userId, msg)
+	c.summarizeAsync(chatId, userId)
 
 	logText := response
 	if len(logText) > 50 {
 		logText = logText[:50] + "..."
 	}
 	c.log.With(
-		slog.Int64("user", userId),
+		slog.Int64("user", chatId),
+		slog.String("model", chatCompletion.Model),
 		slog.String("text", logText),
 	).Info("outgoing message")
 
 	return response, nil
 }
 
-// compose prompt for openai
-func (c *ChatGPT) composePrompt(userId int64, question string) string {
+// compose chat messages for openai; userId is the chat and author is the person who asked
+func (c *ChatGPT) composeMessages(userId, author int64, question string) []Message {
 
 	if strings.HasPrefix(question, "/ask ") {
 		// Send the text after the "/ask " command to the ChatGPT API
-		return strings.TrimPrefix(question, "/ask ")
+		return userMessage(strings.TrimPrefix(question, "/ask "))
 	}
 
 	if strings.HasPrefix(question, "/cat ") {
 		word := strings.TrimPrefix(question, "/cat ")
 		p := LanguageTranslatePrompt("Catalan")
-		return p + word
+		return userMessage(p + word)
 	}
 
 	if strings.HasPrefix(question, "/cas ") {
 		word := strings.TrimPrefix(question, "/cas ")
 		p := LanguageTranslatePrompt("Spanish")
-		return p + word
+		return userMessage(p + word)
 	}
 
 	if strings.HasPrefix(question, "/hello") {
-		return "Answer in Ukrainian: Say one random fact from science."
+		return userMessage("Answer in Ukrainian: Say one random fact from science.")
 	}
 
 	if strings.HasPrefix(question, "/clear") {
@@ -305,13 +464,13 @@ func (c *ChatGPT) composePrompt(userId int64, question string) string {
 			c.prefsAnalyzer.TriggerAnalysisAsync(userId)
 		}
 		c.contextManager.ClearUserContext(userId)
-		return "Let's talk."
+		return userMessage("Let's talk.")
 	}
 
 	if strings.HasPrefix(question, "/topic") {
 		topic := strings.TrimPrefix(question, "/topic ")
 		c.contextManager.SetTopic(userId, topic)
-		return "Let's talk about " + topic + "."
+		return userMessage("Let's talk about " + topic + ".")
 	}
 
 	// Track user message time for preferences analysis
@@ -319,6 +478,13 @@ func (c *ChatGPT) composePrompt(userId int64, question string) string {
 		c.prefsAnalyzer.UpdateLastMessageTime(userId)
 	}
 
+	// recall before the question joins the context, snippets still in the context are not repeated;
+	// memories and documents are searched with the same embedding of the question
+	vector := c.questionVector(userId, author, question)
+	recalled := c.recall(userId, author, question, vector)
+	excerpts := c.documentExcerpts(userId, vector)
+	pages := c.linkedPages(userId, author, question)
+
 	// add user message to context
 	msg := holder.Message{
 		Text:   question,
@@ -326,12 +492,27 @@ func (c *ChatGPT) composePrompt(userId int64, question string) string {
 	}
 	c.contextManager.UpdateUserContext(userId, msg)
 
-	t := c.getContext(userId)
-	if t != "" {
-		question = t + "\nMy next question is:\n" + question
+	messages := c.getContext(userId, recalled, excerpts, pages)
+
+	// the question is normally the last stored turn; add it if storage failed to return it
+	last := len(messages) - 1
+	if last < 0 || messages[last].Role != RoleUser || messages[last].Content.String() != question {
+		messages = append(messages, Message{Role: RoleUser, Content: TextContent(question)})
 	}
 
-	return question
+	return messages
+}
+
+// chatTask tells the task of a message for model selection
+func chatTask(question string) string {
+	if strings.HasPrefix(question, "/cat ") || strings.HasPrefix(question, "/cas ") {
+		return core.TaskTranslate
+	}
+	return core.TaskChat
+}
+
+func userMessage(content string) []Message {
+	return []Message{{Role: RoleUser, Content: TextContent(content)}}
 }
 
 func LanguageTranslatePrompt(language string) string {
@@ -346,38 +527,75 @@ func LanguageTranslatePrompt(language string) string {
 	return p
 }
 
-func (c *ChatGPT) getContext(userId int64) string {
-	t := ""
+// getContext builds the system message from preferences, topic, summary and retrieved sources like recalled
+// memories or linked pages, followed by stored dialog turns
+func (c *ChatGPT) getContext(userId int64, sources ...string) []Message {
+	var messages []Message
+	var system []string
 
 	// Inject user preferences if available
 	if c.prefsAnalyzer != nil {
 		if prefs := c.prefsAnalyzer.GetUserPreferences(userId); prefs != nil {
-			t = c.buildPreferencesPrompt(prefs)
+			system = append(system, c.buildPreferencesPrompt(prefs))
 		}
 	}
 
 	dialogContext := c.contextManager.GetUserContext(userId)
+	if dialogContext != nil && dialogContext.Topic != "" {
+		system = append(system, "Subject: "+dialogContext.Topic)
+	}
+
+	if dialogContext != nil && dialogContext.Summary != "" {
+		system = append(system, "Summary of the earlier conversation: "+dialogContext.Summary)
+	}
+
+	for _, source := range sources {
+		if source != "" {
+			system = append(system, source)
+		}
+	}
+
+	if len(system) > 0 {
+		messages = append(messages, Message{Role: RoleSystem, Content: TextContent(strings.Join(system, "\n"))})
+	}
+
 	if dialogContext != nil {
-		c.log.With(
-			slog.Int64("user", userId),
-			slog.Int("tokens", dialogContext.Tokens),
-		).Info("user context")
-		if dialogContext.Topic != "" {
-			if t != "" {
-				t += "\n"
+		// pictures of the latest turns are attached, older ones are only mentioned
+		images := 0
+		attach := make([]bool, len(dialogContext.Messages))
+		for i := len(dialogContext.Messages) - 1; i >= 0 && images < maxContextImages; i-- {
+			if dialogContext.Messages[i].Image != "" {
+				attach[i] = true
+				images++
 			}
-			t += "Subject: " + dialogContext.Topic
 		}
-		t += "\nPrevious messages of you as Assistant and me as User: "
-		for _, message := range dialogContext.Messages {
-			person := "Assistant"
+		for i, message := range dialogContext.Messages {
+			role := RoleAssistant
 			if message.IsUser {
-				person = "User"
+				role = RoleUser
 			}
-			t += fmt.Sprintf("\n%s: %s", person, message.Text)
+			messages = append(messages, Message{Role: role, Content: c.contextContent(userId, message, attach[i])})
 		}
+		c.log.With(
+			slog.Int64("user", userId),
+			slog.Int("tokens", dialogContext.Tokens),
+			slog.Int("prompt_tokens", c.countTokens(c.ChatModel(userId), messages)),
+		).Info("user context")
+	}
+	return messages
+}
+
+// chat format overhead of every message
+const messageTokens = 3
+
+// countTokens returns prompt tokens of the messages for the model, including the per-message chat format overhead
+func (c *ChatGPT) countTokens(model string, messages []Message) int {
+	encoding := tokenizer.ForModel(model)
+	tokens := messageTokens // every reply is primed with the assistant role
+	for _, message := range messages {
+		tokens += messageTokens + encoding.Count(message.Content.String()) + message.Content.Images()*storage.ImageTokens
 	}
-	return t
+	return tokens
 }
 
 func (c *ChatGPT) buildPreferencesPrompt(prefs *storage.UserPreferences) string {
@@ -0,0 +1,101 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const (
+	PartText  = "text"
+	PartImage = "image_url"
+)
+
+// Content is the text of a message or, for multimodal messages, a list of text and image parts.
+// Text is encoded as a JSON string, parts as an array.
+type Content struct {
+	Text  string
+	Parts []ContentPart
+}
+
+type ContentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+type ImageURL struct {
+	URL    string `json:"url"`              // https URL or base64 data URL
+	Detail string `json:"detail,omitempty"` // "low", "high" or "auto"
+}
+
+// TextContent makes a plain text content
+func TextContent(text string) Content {
+	return Content{Text: text}
+}
+
+// ImageContent makes a content of the text followed by images given as data or https URLs
+func ImageContent(text string, imageURLs ...string) Content {
+	var parts []ContentPart
+	if text != "" {
+		parts = append(parts, ContentPart{Type: PartText, Text: text})
+	}
+	for _, url := range imageURLs {
+		parts = append(parts, ContentPart{Type: PartImage, ImageURL: &ImageURL{URL: url, Detail: "auto"}})
+	}
+	return Content{Parts: parts}
+}
+
+// DataURL encodes image bytes as a base64 data URL
+func DataURL(data []byte) string {
+	return "data:" + http.DetectContentType(data) + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// String returns the text of the content, text parts are joined by new lines
+func (c Content) String() string {
+	if len(c.Parts) == 0 {
+		return c.Text
+	}
+	var texts []string
+	for _, part := range c.Parts {
+		if part.Type == PartText {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// Images returns the number of image parts
+func (c Content) Images() int {
+	n := 0
+	for _, part := range c.Parts {
+		if part.Type == PartImage {
+			n++
+		}
+	}
+	return n
+}
+
+func (c Content) MarshalJSON() ([]byte, error) {
+	if len(c.Parts) > 0 {
+		return json.Marshal(c.Parts)
+	}
+	return json.Marshal(c.Text)
+}
+
+func (c *Content) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		*c = Content{}
+		return nil
+	case len(data) > 0 && data[0] == '[':
+		*c = Content{}
+		return json.Unmarshal(data, &c.Parts)
+	default:
+		*c = Content{}
+		return json.Unmarshal(data, &c.Text)
+	}
+}
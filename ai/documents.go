@@ -0,0 +1,329 @@
+package ai
+
+import (
+	"Brainy/core"
+	"Brainy/lib/pdf"
+	"Brainy/lib/sl"
+	"Brainy/lib/tokenizer"
+	"Brainy/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// embeddingBatch is the number of chunks embedded in one request
+const embeddingBatch = 96
+
+// SetDocumentStorage enables documents as knowledge sources of chats
+func (c *ChatGPT) SetDocumentStorage(store storage.DocumentStorage) {
+	c.documents = store
+}
+
+func (c *ChatGPT) documentsEnabled() bool {
+	return c.conf.Documents.Enabled && c.documents != nil
+}
+
+// hasDocuments tells whether questions in the chat are searched in documents
+func (c *ChatGPT) hasDocuments(chatId int64) bool {
+	if !c.documentsEnabled() {
+		return false
+	}
+	records, err := c.documents.ListDocuments(chatId)
+	if err != nil {
+		c.log.With(slog.Int64("user", chatId)).Error("listing documents", sl.Err(err))
+		return false
+	}
+	return len(records) > 0
+}
+
+// AddDocument downloads the file, extracts its text, embeds it in chunks and attaches it to the chat
+func (c *ChatGPT) AddDocument(chatId, userId int64, fileId, fileName string, size int) (core.Document, error) {
+	if !c.documentsEnabled() {
+		return core.Document{}, fmt.Errorf("documents are disabled")
+	}
+	format := core.DocumentFormat(fileName)
+	if format == "" {
+		return core.Document{}, core.ErrUnsupportedDocument
+	}
+	if err := c.usage.CheckQuota(chatId, userId, core.QuotaTokens, 1); err != nil {
+		return core.Document{}, err
+	}
+	// the space is reserved for the reported size before downloading and corrected to the actual one after,
+	// so that concurrent uploads can't pass the chat limit together; it is returned unless the document is saved
+	if err := c.reserveDocumentSpace(chatId, fileName, size); err != nil {
+		return core.Document{}, err
+	}
+	reserved, saved := size, false
+	defer func() {
+		if !saved && reserved > 0 {
+			c.releaseDocumentSpace(chatId, reserved)
+		}
+	}()
+
+	data, err := c.downloadFile(fileId)
+	if err != nil {
+		return core.Document{}, err
+	}
+	if len(data) != size {
+		c.releaseDocumentSpace(chatId, reserved)
+		reserved = 0
+		if err := c.reserveDocumentSpace(chatId, fileName, len(data)); err != nil {
+			return core.Document{}, err
+		}
+		reserved = len(data)
+	}
+	pages, err := extractDocument(format, data)
+	if err != nil {
+		return core.Document{}, err
+	}
+	chunks := c.chunkDocument(pages, format == core.DocumentPDF, format == core.DocumentCSV)
+	if len(chunks) == 0 {
+		return core.Document{}, core.ErrEmptyDocument
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = chunk.Text
+	}
+	for start := 0; start < len(texts); start += embeddingBatch {
+		end := min(start+embeddingBatch, len(texts))
+		vectors, err := c.embed(ctx, chatId, userId, texts[start:end]...)
+		if err != nil {
+			return core.Document{}, fmt.Errorf("embedding document: %w", err)
+		}
+		for i, vector := range vectors {
+			chunks[start+i].Name = fileName
+			chunks[start+i].Model = c.conf.Memory.Model
+			chunks[start+i].Vector = vector
+		}
+	}
+
+	record := &storage.DocumentRecord{
+		ChatId: chatId,
+		UserId: userId,
+		Name:   fileName,
+		Size:   len(data),
+		Chunks: len(chunks),
+	}
+	if format == core.DocumentPDF {
+		record.Pages = len(pages)
+	}
+	if err := c.documents.SaveDocument(record, chunks); err != nil {
+		return core.Document{}, fmt.Errorf("saving document: %w", err)
+	}
+	saved = true
+
+	c.log.With(
+		slog.Int64("user", chatId),
+		slog.String("name", fileName),
+		slog.Int("size", record.Size),
+		slog.Int("pages", record.Pages),
+		slog.Int("chunks", record.Chunks),
+	).Info("document added")
+	return documentInfo(*record), nil
+}
+
+// reserveDocumentSpace takes size bytes of the chat limit or returns an error when the document does not fit
+func (c *ChatGPT) reserveDocumentSpace(chatId int64, fileName string, size int) error {
+	limit := c.conf.Documents.ChatLimit
+	used, ok, err := c.documents.ReserveSpace(chatId, size, limit)
+	if err != nil {
+		return fmt.Errorf("reserving document space: %w", err)
+	}
+	if !ok {
+		return &core.DocumentLimitError{Name: fileName, Size: size, Used: used, Limit: limit}
+	}
+	return nil
+}
+
+func (c *ChatGPT) releaseDocumentSpace(chatId int64, size int) {
+	if err := c.documents.ReleaseSpace(chatId, size); err != nil {
+		c.log.With(slog.Int64("user", chatId)).Error("releasing document space", sl.Err(err))
+	}
+}
+
+// ListDocuments returns documents of the chat, oldest first
+func (c *ChatGPT) ListDocuments(chatId int64) ([]core.Document, error) {
+	if !c.documentsEnabled() {
+		return nil, nil
+	}
+	records, err := c.documents.ListDocuments(chatId)
+	if err != nil {
+		return nil, fmt.Errorf("listing documents: %w", err)
+	}
+	documents := make([]core.Document, len(records))
+	for i, record := range records {
+		documents[i] = documentInfo(record)
+	}
+	return documents, nil
+}
+
+// RemoveDocument removes a document of the chat with its chunks
+func (c *ChatGPT) RemoveDocument(chatId int64, id string) (bool, error) {
+	if !c.documentsEnabled() {
+		return false, nil
+	}
+	removed, err := c.documents.DeleteDocument(chatId, id)
+	if err != nil {
+		return removed, fmt.Errorf("removing document: %w", err)
+	}
+	if removed {
+		c.log.With(
+			slog.Int64("user", chatId),
+			slog.String("document", id),
+		).Info("document removed")
+	}
+	return removed, nil
+}
+
+func documentInfo(record storage.DocumentRecord) core.Document {
+	return core.Document{
+		Id:        record.Id,
+		Name:      record.Name,
+		Size:      record.Size,
+		Pages:     record.Pages,
+		Chunks:    record.Chunks,
+		CreatedAt: record.CreatedAt,
+	}
+}
+
+// extractDocument returns the text of every page, a text file is a single page
+func extractDocument(format string, data []byte) ([]string, error) {
+	if format == core.DocumentPDF {
+		pages, err := pdf.Pages(data)
+		if errors.Is(err, pdf.ErrNotPDF) || errors.Is(err, pdf.ErrEncrypted) {
+			return nil, fmt.Errorf("%w: %w", core.ErrUnsupportedDocument, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("extracting text: %w", err)
+		}
+		return pages, nil
+	}
+	text := string(data)
+	if !utf8.ValidString(text) {
+		return nil, fmt.Errorf("%w: not UTF-8 text", core.ErrUnsupportedDocument)
+	}
+	return []string{strings.TrimPrefix(text, "\ufeff")}, nil
+}
+
+// chunkDocument splits pages into chunks of about ChunkTokens at line breaks; chunks don't cross pages,
+// so that every chunk cites one page. Chunks of a CSV table repeat its header row
+func (c *ChatGPT) chunkDocument(pages []string, paged, csv bool) []storage.DocumentChunk {
+	limit := max(c.conf.Documents.ChunkTokens, 1)
+	// chunks are sized for the embedding model
+	encoding := tokenizer.ForModel(c.conf.Memory.Model)
+	var chunks []storage.DocumentChunk
+	for i, text := range pages {
+		page := 0
+		if paged {
+			page = i + 1
+		}
+		lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+		header := ""
+		if csv && len(lines) > 1 {
+			header, lines = lines[0]+"\n", lines[1:]
+		}
+
+		var current []string
+		tokens := 0
+		flush := func() {
+			if chunk := strings.TrimSpace(strings.Join(current, "\n")); chunk != "" {
+				chunks = append(chunks, storage.DocumentChunk{Page: page, Text: header + chunk})
+			}
+			current, tokens = nil, 0
+		}
+		for _, line := range lines {
+			for _, part := range splitLine(encoding, line, limit) {
+				count := encoding.Count(part)
+				if tokens+count > limit {
+					flush()
+				}
+				current = append(current, part)
+				tokens += count
+			}
+		}
+		flush()
+	}
+	return chunks
+}
+
+// splitLine splits a line longer than limit tokens at spaces
+func splitLine(encoding *tokenizer.Encoding, line string, limit int) []string {
+	if encoding.Count(line) <= limit {
+		return []string{line}
+	}
+	var parts []string
+	var part strings.Builder
+	tokens := 0
+	for _, word := range strings.Fields(line) {
+		count := encoding.Count(" " + word)
+		if tokens+count > limit && part.Len() > 0 {
+			parts = append(parts, part.String())
+			part.Reset()
+			tokens = 0
+		}
+		if part.Len() > 0 {
+			part.WriteByte(' ')
+		}
+		part.WriteString(word)
+		tokens += count
+	}
+	if part.Len() > 0 {
+		parts = append(parts, part.String())
+	}
+	return parts
+}
+
+// documentExcerpts returns chunks of the chat documents relevant to the question within the token budget,
+// labelled with the file name and page for citations
+func (c *ChatGPT) documentExcerpts(chatId int64, vector []float32) string {
+	if vector == nil || !c.documentsEnabled() {
+		return ""
+	}
+	chunks, err := c.documents.SearchChunks(chatId, c.conf.Memory.Model, vector, c.conf.Documents.TopK)
+	if err != nil {
+		c.log.With(slog.Int64("user", chatId)).Error("searching documents", sl.Err(err))
+		return ""
+	}
+
+	var excerpts []string
+	tokens := 0
+	encoding := c.chatEncoding(chatId)
+	for _, chunk := range chunks {
+		if chunk.Score < c.conf.Documents.MinScore {
+			continue
+		}
+		excerpt := "[" + citation(chunk.DocumentChunk) + "]\n" + chunk.Text
+		excerptTokens := encoding.Count(excerpt)
+		if tokens+excerptTokens > c.conf.Documents.Budget {
+			continue
+		}
+		excerpts = append(excerpts, excerpt)
+		tokens += excerptTokens
+	}
+	if len(excerpts) == 0 {
+		return ""
+	}
+	c.log.With(
+		slog.Int64("user", chatId),
+		slog.Int("excerpts", len(excerpts)),
+		slog.Int("tokens", tokens),
+	).Debug("document excerpts retrieved")
+	return "Excerpts from documents shared in this chat. Use them when they answer the question and cite the source " +
+		"in brackets like [spec.pdf, p. 3]:\n\n" + strings.Join(excerpts, "\n\n")
+}
+
+// citation names the file and the page of a chunk
+func citation(chunk storage.DocumentChunk) string {
+	if chunk.Page > 0 {
+		return fmt.Sprintf("%s, p. %d", chunk.Name, chunk.Page)
+	}
+	return chunk.Name
+}
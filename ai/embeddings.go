@@ -0,0 +1,34 @@
+package ai
+
+import (
+	"context"
+)
+
+// EmbeddingProvider converts texts to vectors for semantic search
+type EmbeddingProvider interface {
+	Embed(ctx context.Context, request *EmbeddingRequest) (*EmbeddingResponse, error)
+}
+
+type EmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type EmbeddingResponse struct {
+	Model string `json:"model"`
+	Data  []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Usage Usage  `json:"usage"`
+	Error *Error `json:"error"`
+}
+
+// Embed sends texts to the embeddings endpoint
+func (p *OpenAIProvider) Embed(ctx context.Context, request *EmbeddingRequest) (*EmbeddingResponse, error) {
+	var response EmbeddingResponse
+	if err := p.postJSON(ctx, "/embeddings", request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
@@ -0,0 +1,81 @@
+package ai
+
+import (
+	"Brainy/core"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APIError is an error returned by the API, classified into one of the core error classes
+type APIError struct {
+	StatusCode int
+	Type       string
+	Code       string
+	Message    string
+	// Param names the request field the error refers to, if any
+	Param string
+	// RetryAfter is the delay requested by the server, zero if not given
+	RetryAfter time.Duration
+	kind       error
+}
+
+func (e *APIError) Error() string {
+	code := e.Code
+	if code == "" {
+		code = e.Type
+	}
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s (%d %s): %s", e.kind, e.StatusCode, code, e.Message)
+	}
+	return fmt.Sprintf("%s (%s): %s", e.kind, code, e.Message)
+}
+
+// Unwrap returns the error class, so errors.Is(err, core.ErrRateLimited) works
+func (e *APIError) Unwrap() error {
+	return e.kind
+}
+
+// Retryable reports whether repeating the same request may succeed
+func (e *APIError) Retryable() bool {
+	return e.kind == core.ErrRateLimited || e.kind == core.ErrServer
+}
+
+// newAPIError classifies an error body and HTTP status; status is zero for errors inside a successful response
+func newAPIError(status int, apiErr *Error) *APIError {
+	e := &APIError{StatusCode: status}
+	if apiErr != nil {
+		e.Type = apiErr.Type
+		e.Code = apiErr.Code
+		e.Message = apiErr.Message
+		e.Param = apiErr.Param
+	}
+	if e.Message == "" && status != 0 {
+		e.Message = http.StatusText(status)
+	}
+	e.kind = classify(status, e.Type, e.Code, e.Message)
+	return e
+}
+
+func classify(status int, errType, code, message string) error {
+	switch {
+	case code == "insufficient_quota" || errType == "insufficient_quota" || code == "billing_hard_limit_reached":
+		return core.ErrQuotaExceeded
+	case code == "context_length_exceeded" || code == "string_above_max_length":
+		return core.ErrContextLength
+	case code == "content_policy_violation" || code == "content_filter" || code == "moderation_blocked" ||
+		strings.Contains(strings.ToLower(message), "safety system"):
+		return core.ErrContentPolicy
+	case code == "rate_limit_exceeded" || errType == "requests" || errType == "tokens" || status == http.StatusTooManyRequests:
+		return core.ErrRateLimited
+	case code == "model_not_found" || status == http.StatusNotFound && strings.Contains(strings.ToLower(message), "model"):
+		return core.ErrUnknownModel
+	case code == "invalid_api_key" || errType == "authentication_error" || status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return core.ErrAuthentication
+	case errType == "server_error" || status >= http.StatusInternalServerError:
+		return core.ErrServer
+	default:
+		return core.ErrInvalidRequest
+	}
+}
@@ -0,0 +1,108 @@
+package ai
+
+import (
+	"Brainy/core"
+	"Brainy/lib/sl"
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// FallbackProvider sends chat completions to the next model of the fallback chain when the requested
+// model fails with a server, quota or unknown-model error; other requests go to the wrapped provider
+type FallbackProvider struct {
+	Provider
+	models []string
+	log    *slog.Logger
+}
+
+// NewFallbackProvider wraps the provider with the fallback chain, without models the provider is returned as is
+func NewFallbackProvider(provider Provider, models []string, log *slog.Logger) Provider {
+	if len(models) == 0 {
+		return provider
+	}
+	return &FallbackProvider{
+		Provider: provider,
+		models:   models,
+		log:      log.With(sl.Module("fallback")),
+	}
+}
+
+// Complete sends the request to the requested model, then to the fallback models in order
+func (p *FallbackProvider) Complete(ctx context.Context, request *GPTRequest) (*ChatCompletion, error) {
+	return p.complete(request, func(attempt *GPTRequest) (*ChatCompletion, error) {
+		return p.Provider.Complete(ctx, attempt)
+	})
+}
+
+// CompleteStream works like Complete; once a part of the answer was streamed the model is not switched
+func (p *FallbackProvider) CompleteStream(ctx context.Context, request *GPTRequest, onDelta func(delta string)) (*ChatCompletion, error) {
+	streamed := false
+	return p.complete(request, func(attempt *GPTRequest) (*ChatCompletion, error) {
+		if streamed {
+			return nil, errStreamed
+		}
+		return p.Provider.CompleteStream(ctx, attempt, func(delta string) {
+			streamed = true
+			onDelta(delta)
+		})
+	})
+}
+
+var errStreamed = errors.New("answer was partly streamed")
+
+// complete tries the models of the chain and sets the model that served the completion; when all models
+// fail the result of the last attempt is returned
+func (p *FallbackProvider) complete(request *GPTRequest, send func(attempt *GPTRequest) (*ChatCompletion, error)) (*ChatCompletion, error) {
+	var completion *ChatCompletion
+	var err error
+	for _, model := range p.chain(request.Model) {
+		attempt := *request
+		attempt.Model = model
+		result, sendErr := send(&attempt)
+		if errors.Is(sendErr, errStreamed) {
+			break
+		}
+		completion, err = result, sendErr
+
+		failure := err
+		if failure == nil && completion.Error != nil {
+			failure = newAPIError(0, completion.Error)
+		}
+		if failure == nil {
+			if completion.Model == "" {
+				completion.Model = model
+			}
+			if model != request.Model {
+				p.log.With(
+					slog.String("requested", request.Model),
+					slog.String("served", completion.Model),
+				).Warn("completion served by fallback model")
+			}
+			return completion, nil
+		}
+		if !fallbackError(failure) {
+			break
+		}
+		p.log.With(
+			slog.String("model", model),
+		).Warn("model failed, trying the next one", sl.Err(failure))
+	}
+	return completion, err
+}
+
+// chain returns the requested model followed by the fallback models
+func (p *FallbackProvider) chain(model string) []string {
+	models := []string{model}
+	for _, fallback := range p.models {
+		if fallback != model {
+			models = append(models, fallback)
+		}
+	}
+	return models
+}
+
+// fallbackError reports whether another model may serve the request
+func fallbackError(err error) bool {
+	return errors.Is(err, core.ErrServer) || errors.Is(err, core.ErrQuotaExceeded) || errors.Is(err, core.ErrUnknownModel)
+}
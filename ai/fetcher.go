@@ -0,0 +1,315 @@
+package ai
+
+import (
+	"Brainy/core"
+	"Brainy/lib/pdf"
+	"Brainy/lib/readable"
+	"Brainy/lib/sl"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+const (
+	maxRedirects   = 5
+	fetchUserAgent = "Mozilla/5.0 (compatible; Brainy/1.0)"
+)
+
+// ranges that are not routed on the internet beyond those netip reports as private, loopback or link-local
+var internalRanges = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64 may reach internal IPv4 addresses
+	netip.MustParsePrefix("2002::/16"),    // 6to4 embeds an IPv4 address
+}
+
+// meta charset declaration within the first bytes of a page
+var metaCharset = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?([\w-]+)`)
+
+// WebPage is the readable content of a fetched page
+type WebPage struct {
+	URL       string // after redirects
+	Title     string
+	Text      string
+	Truncated bool // the page was longer than the size limit
+}
+
+// Fetcher downloads web pages and extracts their readable text. Addresses are checked when connecting,
+// after DNS resolution and on every redirect, so links can't reach internal services
+type Fetcher struct {
+	conf   *core.Config
+	log    *slog.Logger
+	client *http.Client
+	allow  []hostRule
+	deny   []hostRule
+}
+
+// hostRule matches a host with its subdomains, or addresses of a range
+type hostRule struct {
+	host   string
+	prefix netip.Prefix
+}
+
+// NewFetcher creates a fetcher with limits and allow and deny lists from the web section of config
+func NewFetcher(conf *core.Config, log *slog.Logger) *Fetcher {
+	timeout := time.Duration(conf.Web.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	f := &Fetcher{
+		conf:  conf,
+		log:   log.With(sl.Module("fetcher")),
+		allow: parseHostRules(conf.Web.Allow),
+		deny:  parseHostRules(conf.Web.Deny),
+	}
+	f.client = &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// a proxy would connect instead of the checked dialer
+			Proxy:                 nil,
+			DialContext:           f.dialContext,
+			ForceAttemptHTTP2:     true,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return checkScheme(request.URL)
+		},
+	}
+	return f
+}
+
+func parseHostRules(entries []string) []hostRule {
+	var rules []hostRule
+	for _, entry := range entries {
+		entry = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(entry)), "*.")
+		entry = strings.Trim(entry, ".")
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			rules = append(rules, hostRule{prefix: prefix.Masked()})
+		} else if addr, err := netip.ParseAddr(entry); err == nil {
+			addr = addr.Unmap()
+			rules = append(rules, hostRule{prefix: netip.PrefixFrom(addr, addr.BitLen())})
+		} else {
+			rules = append(rules, hostRule{host: entry})
+		}
+	}
+	return rules
+}
+
+func matchRules(rules []hostRule, host string, addr netip.Addr) bool {
+	for _, rule := range rules {
+		if rule.prefix.IsValid() && rule.prefix.Contains(addr) {
+			return true
+		}
+		if rule.host != "" && (host == rule.host || strings.HasSuffix(host, "."+rule.host)) {
+			return true
+		}
+	}
+	return false
+}
+
+func internalAddress(addr netip.Addr) bool {
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return true
+	}
+	for _, prefix := range internalRanges {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// permit checks an address the host resolved to against the deny and allow lists
+func (f *Fetcher) permit(host string, addr netip.Addr) error {
+	if matchRules(f.deny, host, addr) {
+		return fmt.Errorf("%w: %s is denied", core.ErrForbiddenURL, host)
+	}
+	allowed := matchRules(f.allow, host, addr)
+	if len(f.allow) > 0 && !allowed {
+		return fmt.Errorf("%w: %s is not in the allow list", core.ErrForbiddenURL, host)
+	}
+	if internalAddress(addr) && !allowed {
+		return fmt.Errorf("%w: %s resolves to internal address %s", core.ErrForbiddenURL, host, addr)
+	}
+	return nil
+}
+
+// dialContext resolves the host itself and connects only to checked addresses, so DNS answers that change
+// between the check and the connection can't lead elsewhere
+func (f *Fetcher) dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	for i, addr := range addrs {
+		addrs[i] = addr.Unmap()
+		if err := f.permit(host, addrs[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	err = fmt.Errorf("no addresses for %s", host)
+	for _, addr := range addrs {
+		var conn net.Conn
+		conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+func checkScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q", core.ErrForbiddenURL, u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("%w: no host", core.ErrForbiddenURL)
+	}
+	return nil
+}
+
+// Fetch downloads the page and extracts its readable text; HTML, plain text and PDF are read,
+// pages longer than the size limit are cut
+func (f *Fetcher) Fetch(ctx context.Context, pageURL string) (*WebPage, error) {
+	if !strings.Contains(pageURL, "://") {
+		pageURL = "https://" + pageURL
+	}
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing url: %w", err)
+	}
+	if err := checkScheme(u); err != nil {
+		return nil, err
+	}
+	// credentials in links are not sent anywhere
+	u.User = nil
+	u.Fragment = ""
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	request.Header.Set("User-Agent", fetchUserAgent)
+	request.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain;q=0.9,application/pdf;q=0.8,*/*;q=0.5")
+
+	start := time.Now()
+	response, err := f.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("fetching page: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching page: status %d", response.StatusCode)
+	}
+
+	limit := f.conf.Web.MaxBytes
+	if limit <= 0 {
+		limit = 2 << 20
+	}
+	data, err := io.ReadAll(io.LimitReader(response.Body, int64(limit)+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading page: %w", err)
+	}
+	page := &WebPage{URL: response.Request.URL.String()}
+	if len(data) > limit {
+		data, page.Truncated = data[:limit], true
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if mediaType == "" {
+		mediaType = http.DetectContentType(data)
+		mediaType, params, _ = mime.ParseMediaType(mediaType)
+	}
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		content := readable.Extract(decodeText(data, params["charset"], true))
+		page.Title, page.Text = content.Title, content.Text
+	case strings.HasPrefix(mediaType, "text/") || mediaType == "application/json":
+		page.Text = strings.TrimSpace(decodeText(data, params["charset"], false))
+	case mediaType == "application/pdf":
+		if page.Truncated {
+			return nil, fmt.Errorf("%w: PDF larger than %d bytes", core.ErrUnreadablePage, limit)
+		}
+		pages, err := pdf.Pages(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", core.ErrUnreadablePage, err)
+		}
+		page.Text = strings.TrimSpace(strings.Join(pages, "\n\n"))
+	default:
+		return nil, fmt.Errorf("%w: content type %s", core.ErrUnreadablePage, mediaType)
+	}
+	if page.Text == "" {
+		return nil, core.ErrUnreadablePage
+	}
+
+	f.log.With(
+		slog.String("url", page.URL),
+		slog.String("type", mediaType),
+		slog.Int("bytes", len(data)),
+		slog.Bool("truncated", page.Truncated),
+		slog.Duration("duration", time.Since(start)),
+	).Debug("page fetched")
+	return page, nil
+}
+
+// decodeText converts the page to UTF-8 by its declared charset, HTML pages may declare it in a meta tag;
+// undeclared text that is not UTF-8 is read as Windows-1252
+func decodeText(data []byte, charset string, html bool) string {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if charset == "" && html {
+		if match := metaCharset.FindSubmatch(data[:min(len(data), 4096)]); match != nil {
+			charset = string(match[1])
+		}
+	}
+	if charset == "" {
+		if utf8.Valid(data) {
+			return string(data)
+		}
+		charset = "windows-1252"
+	}
+	encoding, err := htmlindex.Get(charset)
+	if err != nil {
+		return strings.ToValidUTF8(string(data), "�")
+	}
+	if name, _ := htmlindex.Name(encoding); name == "utf-8" {
+		return strings.ToValidUTF8(string(data), "�")
+	}
+	decoded, err := encoding.NewDecoder().Bytes(data)
+	if err != nil {
+		return strings.ToValidUTF8(string(data), "�")
+	}
+	return string(decoded)
+}
@@ -0,0 +1,259 @@
+package ai
+
+import (
+	"Brainy/core"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestFetcher(allow, deny []string, configure func(conf *core.Config)) *Fetcher {
+	conf := &core.Config{}
+	conf.Web.Allow, conf.Web.Deny = allow, deny
+	if configure != nil {
+		configure(conf)
+	}
+	return NewFetcher(conf, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestPermit(t *testing.T) {
+	tests := []struct {
+		name    string
+		allow   []string
+		deny    []string
+		host    string
+		addr    string
+		allowed bool
+	}{
+		{"public address", nil, nil, "example.com", "93.184.215.14", true},
+		{"loopback", nil, nil, "localhost", "127.0.0.1", false},
+		{"loopback v6", nil, nil, "ip6-localhost", "::1", false},
+		{"private", nil, nil, "router.lan", "192.168.1.1", false},
+		{"private 10/8", nil, nil, "10.0.0.5", "10.0.0.5", false},
+		{"link-local metadata", nil, nil, "169.254.169.254", "169.254.169.254", false},
+		{"link-local v6", nil, nil, "fe80::1", "fe80::1", false},
+		{"unique local v6", nil, nil, "fd00::1", "fd00::1", false},
+		{"unspecified", nil, nil, "0.0.0.0", "0.0.0.0", false},
+		{"carrier-grade nat", nil, nil, "cgn", "100.64.1.1", false},
+		{"nat64 of internal", nil, nil, "nat64", "64:ff9b::a00:1", false},
+		{"denied host", nil, []string{"example.com"}, "example.com", "93.184.215.14", false},
+		{"denied subdomain", nil, []string{"*.example.com"}, "www.example.com", "93.184.215.14", false},
+		{"deny does not match suffix", nil, []string{"example.com"}, "notexample.com", "93.184.215.14", true},
+		{"denied range", nil, []string{"93.184.0.0/16"}, "example.com", "93.184.215.14", false},
+		{"not in allow list", []string{"wikipedia.org"}, nil, "example.com", "93.184.215.14", false},
+		{"allowed subdomain", []string{"wikipedia.org"}, nil, "en.wikipedia.org", "208.80.154.224", true},
+		{"allowed internal host", []string{"intranet.local"}, nil, "intranet.local", "10.1.2.3", true},
+		{"allowed internal range", []string{"10.1.0.0/16"}, nil, "wiki", "10.1.2.3", true},
+		{"deny wins over allow", []string{"example.com"}, []string{"example.com"}, "example.com", "93.184.215.14", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestFetcher(tt.allow, tt.deny, nil)
+			err := f.permit(tt.host, netip.MustParseAddr(tt.addr))
+			if tt.allowed && err != nil {
+				t.Errorf("permit(%s, %s) = %v, want allowed", tt.host, tt.addr, err)
+			}
+			if !tt.allowed && !errors.Is(err, core.ErrForbiddenURL) {
+				t.Errorf("permit(%s, %s) = %v, want ErrForbiddenURL", tt.host, tt.addr, err)
+			}
+		})
+	}
+}
+
+func fetch(t *testing.T, f *Fetcher, pageURL string) (*WebPage, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return f.Fetch(ctx, pageURL)
+}
+
+// test servers listen on the loopback interface, so only fetchers that allow it reach them
+func TestFetchInternal(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal secret"))
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	port := ":" + serverURL.Port()
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"loopback ip", server.URL},
+		{"localhost", "http://localhost" + port},
+		{"mapped v6", "http://[::ffff:127.0.0.1]" + port},
+		{"loopback v6", "http://[::1]" + port},
+		{"private ip", "http://10.0.0.1/"},
+		{"metadata service", "http://169.254.169.254/latest/meta-data/"},
+		{"unspecified", "http://0.0.0.0" + port},
+		{"file scheme", "file:///etc/passwd"},
+		{"ftp scheme", "ftp://127.0.0.1/"},
+	}
+	f := newTestFetcher(nil, nil, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if page, err := fetch(t, f, tt.url); !errors.Is(err, core.ErrForbiddenURL) {
+				t.Errorf("Fetch(%s) = %v, %v, want ErrForbiddenURL", tt.url, page, err)
+			}
+		})
+	}
+}
+
+func TestFetchLists(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("hello from the test server"))
+	}))
+	defer server.Close()
+	localhost := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	tests := []struct {
+		name    string
+		allow   []string
+		deny    []string
+		url     string
+		allowed bool
+	}{
+		{"allowed address", []string{"127.0.0.1"}, nil, server.URL, true},
+		{"allowed host", []string{"localhost"}, nil, localhost, true},
+		{"allowed range", []string{"127.0.0.0/8"}, nil, localhost, true},
+		{"host not in allow list", []string{"localhost"}, nil, server.URL, false},
+		{"denied host", []string{"127.0.0.1"}, []string{"localhost"}, localhost, false},
+		{"denied address", []string{"localhost"}, []string{"127.0.0.1"}, localhost, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := fetch(t, newTestFetcher(tt.allow, tt.deny, nil), tt.url)
+			if !tt.allowed {
+				if !errors.Is(err, core.ErrForbiddenURL) {
+					t.Errorf("Fetch(%s) = %v, want ErrForbiddenURL", tt.url, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Fetch(%s): %v", tt.url, err)
+			}
+			if page.Text != "hello from the test server" {
+				t.Errorf("text = %q", page.Text)
+			}
+		})
+	}
+}
+
+func TestFetchRedirect(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal secret"))
+	}))
+	defer internal.Close()
+	public := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/internal":
+			http.Redirect(w, r, internal.URL, http.StatusFound)
+		case "/metadata":
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+		case "/scheme":
+			http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		default:
+			w.Write([]byte("public page"))
+		}
+	}))
+	defer public.Close()
+	// only the host name of the public server is allowed, its address is reached by the redirects
+	publicURL := strings.Replace(public.URL, "127.0.0.1", "localhost", 1)
+	f := newTestFetcher([]string{"localhost"}, nil, nil)
+
+	if page, err := fetch(t, f, publicURL+"/page"); err != nil || page.Text != "public page" {
+		t.Fatalf("Fetch() = %v, %v", page, err)
+	}
+	for _, path := range []string{"/internal", "/metadata", "/scheme"} {
+		if page, err := fetch(t, f, publicURL+path); !errors.Is(err, core.ErrForbiddenURL) {
+			t.Errorf("redirect %s = %v, %v, want ErrForbiddenURL", path, page, err)
+		}
+	}
+	if _, err := fetch(t, f, publicURL+"/loop"); err == nil || !strings.Contains(err.Error(), "redirects") {
+		t.Errorf("redirect loop = %v, want stopped", err)
+	}
+}
+
+func TestFetchMaxBytes(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		maxBytes    int
+		want        string
+		truncated   bool
+		err         error
+	}{
+		{"within the limit", "text/plain", "short text", 100, "short text", false, nil},
+		{"cut at the limit", "text/plain", strings.Repeat("a", 1000), 100, strings.Repeat("a", 100), true, nil},
+		{"pdf over the limit", "application/pdf", "%PDF-1.4\n" + strings.Repeat("x", 1000), 100, "", false, core.ErrUnreadablePage},
+		{"html page", "text/html; charset=windows-1252", "<html><head><title>Caf\xe9</title></head><body><p>Men\xfa del d\xeda</p></body></html>",
+			1000, "Menú del día", false, nil},
+		{"binary", "application/octet-stream", "\x00\x01\x02", 100, "", false, core.ErrUnreadablePage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+			f := newTestFetcher([]string{"127.0.0.1"}, nil, func(conf *core.Config) { conf.Web.MaxBytes = tt.maxBytes })
+
+			page, err := fetch(t, f, server.URL)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("Fetch() = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(page.Text, tt.want) || page.Truncated != tt.truncated {
+				t.Errorf("Fetch() = %q truncated %v, want %q truncated %v", page.Text, page.Truncated, tt.want, tt.truncated)
+			}
+			if len(page.Text) > tt.maxBytes*2 {
+				t.Errorf("text of %d bytes passed the limit of %d", len(page.Text), tt.maxBytes)
+			}
+		})
+	}
+}
+
+func TestFetchTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+	f := newTestFetcher([]string{"127.0.0.1"}, nil, func(conf *core.Config) { conf.Web.Timeout = 1 })
+
+	start := time.Now()
+	_, err := fetch(t, f, server.URL)
+	if err == nil {
+		t.Fatal("slow page fetched")
+	}
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) || !urlErr.Timeout() {
+		t.Errorf("Fetch() = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("gave up after %v, timeout is 1s", elapsed)
+	}
+}
@@ -0,0 +1,293 @@
+package ai
+
+import (
+	"Brainy/core"
+	"Brainy/lib/imaging"
+	"Brainy/lib/sl"
+	"Brainy/storage"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dallE2MaxBytes = 4 << 20 // input limit of DALL-E 2 edits and variations
+	dallE2Size     = 1024
+	maxImageBytes  = 50 << 20
+)
+
+// SetImageStorage sets the storage recording sent images and their lineage
+func (c *ChatGPT) SetImageStorage(images storage.ImageStorage) {
+	c.images = images
+}
+
+// EditImage redraws the picture by the instruction; the optional mask is a PNG whose transparent area
+// marks the part to change
+func (c *ChatGPT) EditImage(chatId, userId int64, fileId, maskFileId, instruction string) (core.GeneratedImage, error) {
+	if err := c.usage.CheckQuota(chatId, userId, core.QuotaImages, 1); err != nil {
+		return core.GeneratedImage{}, err
+	}
+	if err := c.moderate(chatId, userId, core.ModerationImage, instruction); err != nil {
+		return core.GeneratedImage{}, err
+	}
+
+	model := c.conf.Images.EditModel
+	image, err := c.downloadFile(fileId)
+	if err != nil {
+		return core.GeneratedImage{}, err
+	}
+	var mask []byte
+	if maskFileId != "" {
+		if mask, err = c.downloadFile(maskFileId); err != nil {
+			return core.GeneratedImage{}, err
+		}
+		if err = imaging.CheckMask(mask); err != nil {
+			return core.GeneratedImage{}, fmt.Errorf("%w: %w", core.ErrInvalidMask, err)
+		}
+	}
+	if strings.HasPrefix(model, "dall-e") {
+		if image, err = imaging.SquarePNG(image, dallE2Size, dallE2MaxBytes); err != nil {
+			return core.GeneratedImage{}, fmt.Errorf("preparing picture: %w", err)
+		}
+		// the mask must cover the same square as the picture
+		if mask != nil {
+			if mask, err = imaging.SquareMask(mask, image); err != nil {
+				return core.GeneratedImage{}, fmt.Errorf("%w: %w", core.ErrInvalidMask, err)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 180*time.Second)
+	defer cancel()
+
+	imageResponse, err := c.provider.EditImage(ctx, &ImageEditRequest{
+		Model:  model,
+		Prompt: instruction,
+		Image:  image,
+		Mask:   mask,
+		N:      1,
+	})
+	if err != nil {
+		return core.GeneratedImage{}, fmt.Errorf("editing image: %w", err)
+	}
+	result, err := c.imageResult(ctx, imageResponse)
+	if err != nil {
+		return core.GeneratedImage{}, fmt.Errorf("editing image: %w", err)
+	}
+	c.usage.RecordImages(chatId, userId, model, 1)
+
+	c.logDerived(chatId, fileId, core.ImageEdit, instruction)
+	return core.GeneratedImage{
+		Data:   result,
+		Prompt: instruction,
+		Model:  model,
+		Path:   c.saveImage(chatId, result),
+	}, nil
+}
+
+// VaryImage creates a variation of the picture
+func (c *ChatGPT) VaryImage(chatId, userId int64, fileId string) (core.GeneratedImage, error) {
+	if err := c.usage.CheckQuota(chatId, userId, core.QuotaImages, 1); err != nil {
+		return core.GeneratedImage{}, err
+	}
+
+	image, err := c.downloadFile(fileId)
+	if err != nil {
+		return core.GeneratedImage{}, err
+	}
+	// variations take a square PNG
+	if image, err = imaging.SquarePNG(image, dallE2Size, dallE2MaxBytes); err != nil {
+		return core.GeneratedImage{}, fmt.Errorf("preparing picture: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	defer cancel()
+
+	model := c.conf.Images.VariationModel
+	imageResponse, err := c.provider.VaryImage(ctx, &ImageVariationRequest{
+		Model: model,
+		Image: image,
+		N:     1,
+		Size:  fmt.Sprintf("%dx%d", dallE2Size, dallE2Size),
+	})
+	if err != nil {
+		return core.GeneratedImage{}, fmt.Errorf("varying image: %w", err)
+	}
+	result, err := c.imageResult(ctx, imageResponse)
+	if err != nil {
+		return core.GeneratedImage{}, fmt.Errorf("varying image: %w", err)
+	}
+	c.usage.RecordImages(chatId, userId, model, 1)
+
+	c.logDerived(chatId, fileId, core.ImageVariation, "")
+	return core.GeneratedImage{
+		Data:  result,
+		Model: model,
+		Path:  c.saveImage(chatId, result),
+	}, nil
+}
+
+// RecordImage stores an image sent to the chat together with the image it was derived from
+func (c *ChatGPT) RecordImage(chatId, userId int64, fileId, parentFileId, operation string, image core.GeneratedImage) {
+	if c.images == nil || fileId == "" {
+		return
+	}
+	err := c.images.SaveImage(&storage.ImageRecord{
+		FileId:        fileId,
+		ParentFileId:  parentFileId,
+		ChatId:        chatId,
+		UserId:        userId,
+		Operation:     operation,
+		Prompt:        image.Prompt,
+		RevisedPrompt: image.RevisedPrompt,
+		Style:         image.Style,
+		Model:         image.Model,
+		Path:          image.Path,
+	})
+	if err != nil {
+		c.log.With(slog.Int64("user", chatId)).Error("saving image record", sl.Err(err))
+	}
+}
+
+// ListImages returns recent images of the user, newest first
+func (c *ChatGPT) ListImages(userId int64, limit int) ([]core.GalleryImage, error) {
+	if c.images == nil {
+		return nil, nil
+	}
+	records, err := c.images.ListImages(userId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("listing images: %w", err)
+	}
+	images := make([]core.GalleryImage, 0, len(records))
+	for i := range records {
+		images = append(images, galleryImage(&records[i]))
+	}
+	return images, nil
+}
+
+// GalleryImage returns an image from the history by its ID
+func (c *ChatGPT) GalleryImage(id string) (*core.GalleryImage, error) {
+	if c.images == nil {
+		return nil, nil
+	}
+	record, err := c.images.GetImageById(id)
+	if err != nil || record == nil {
+		return nil, err
+	}
+	image := galleryImage(record)
+	return &image, nil
+}
+
+func galleryImage(record *storage.ImageRecord) core.GalleryImage {
+	return core.GalleryImage{
+		Id:            record.Id,
+		FileId:        record.FileId,
+		Operation:     record.Operation,
+		Prompt:        record.Prompt,
+		RevisedPrompt: record.RevisedPrompt,
+		Style:         record.Style,
+		Model:         record.Model,
+		Path:          record.Path,
+		CreatedAt:     record.CreatedAt,
+	}
+}
+
+// logDerived logs the new image with the operation that produced its source, if the source was made by the bot
+func (c *ChatGPT) logDerived(chatId int64, fileId, operation, prompt string) {
+	log := c.log.With(
+		slog.Int64("user", chatId),
+		slog.String("operation", operation),
+		slog.String("prompt", prompt),
+	)
+	if c.images != nil {
+		if parent, err := c.images.GetImage(fileId); err == nil && parent != nil {
+			log = log.With(slog.String("parent_operation", parent.Operation), slog.String("parent_prompt", parent.Prompt))
+		}
+	}
+	log.Info("image derived")
+}
+
+// imageResult returns bytes of the first image, decoded from base64 or downloaded from its URL
+func (c *ChatGPT) imageResult(ctx context.Context, imageResponse *ImageGenerationResponse) ([]byte, error) {
+	if imageResponse.Error != nil {
+		return nil, newAPIError(0, imageResponse.Error)
+	}
+	if len(imageResponse.Data) == 0 {
+		return nil, fmt.Errorf("empty response")
+	}
+	return imageBytes(ctx, imageResponse.Data[0])
+}
+
+// imageBytes decodes the base64 image or downloads it from its URL
+func imageBytes(ctx context.Context, data ImageData) ([]byte, error) {
+	if data.B64JSON != "" {
+		image, err := base64.StdEncoding.DecodeString(data.B64JSON)
+		if err != nil {
+			return nil, fmt.Errorf("decoding image: %w", err)
+		}
+		return image, nil
+	}
+	return downloadImage(ctx, data.URL)
+}
+
+func downloadImage(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("making request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("downloading image: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading image: %s", resp.Status)
+	}
+	image, err := io.ReadAll(io.LimitReader(resp.Body, maxImageBytes))
+	if err != nil {
+		return nil, fmt.Errorf("reading image: %w", err)
+	}
+	return image, nil
+}
+
+// saveImage keeps a copy of the image in the configured directory, grouped by chat, and returns its path
+func (c *ChatGPT) saveImage(chatId int64, image []byte) string {
+	if c.conf.Images.SaveDir == "" {
+		return ""
+	}
+	dir := filepath.Join(c.conf.Images.SaveDir, strconv.FormatInt(chatId, 10))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		c.log.Error("creating image directory", sl.Err(err))
+		return ""
+	}
+	name := fmt.Sprintf("%s-%04d%s", time.Now().Format("20060102-150405"), rand.Intn(10000), imageExtension(image))
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, image, 0o644); err != nil {
+		c.log.With(slog.Int64("user", chatId)).Error("saving image", sl.Err(err))
+		return ""
+	}
+	return path
+}
+
+// downloadFile downloads a file sent to the bot
+func (c *ChatGPT) downloadFile(fileId string) ([]byte, error) {
+	if c.files == nil {
+		return nil, fmt.Errorf("file loader is not set")
+	}
+	data, err := c.files.DownloadFile(fileId)
+	if err != nil {
+		return nil, fmt.Errorf("downloading file: %w", err)
+	}
+	return data, nil
+}
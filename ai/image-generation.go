@@ -1,11 +1,16 @@
 package ai
 
+import "strings"
+
 // ImageGenerationRequest represents a request to DALL-E API
 type ImageGenerationRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-	N      int    `json:"n"`
-	Size   string `json:"size"`
+	Model   string `json:"model"`
+	Prompt  string `json:"prompt"`
+	N       int    `json:"n"`
+	Size    string `json:"size,omitempty"`
+	Quality string `json:"quality,omitempty"`
+	// ResponseFormat is "url" or "b64_json" for DALL-E models, GPT image models always return base64
+	ResponseFormat string `json:"response_format,omitempty"`
 }
 
 // ImageGenerationResponse represents the response from DALL-E API
@@ -23,11 +28,34 @@ type ImageData struct {
 }
 
 // NewImageRequest creates a new image generation request
-func NewImageRequest(prompt string) *ImageGenerationRequest {
-	return &ImageGenerationRequest{
-		Model:  "dall-e-3",
-		Prompt: prompt,
-		N:      1,
-		Size:   "1024x1024",
+func NewImageRequest(prompt, model, size, quality string, n int) *ImageGenerationRequest {
+	request := &ImageGenerationRequest{
+		Model:   model,
+		Prompt:  prompt,
+		N:       n,
+		Size:    size,
+		Quality: quality,
+	}
+	if strings.HasPrefix(model, "dall-e") {
+		request.ResponseFormat = "b64_json"
 	}
+	return request
+}
+
+// ImageEditRequest changes an image by the prompt, the transparent area of the mask marks what to redraw
+type ImageEditRequest struct {
+	Model  string
+	Prompt string
+	Image  []byte
+	Mask   []byte // PNG with alpha channel, optional
+	N      int
+	Size   string
+}
+
+// ImageVariationRequest creates variations of a square PNG image
+type ImageVariationRequest struct {
+	Model string
+	Image []byte
+	N     int
+	Size  string
 }
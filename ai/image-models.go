@@ -0,0 +1,76 @@
+package ai
+
+import (
+	"Brainy/core"
+	"strings"
+)
+
+// imageModelSpec lists what an image model accepts; aliases map square, landscape and portrait
+// to the sizes of the model, square-only models have no landscape and portrait
+type imageModelSpec struct {
+	sizes     []string
+	aliases   map[string]string
+	qualities []string
+	maxN      int
+}
+
+var imageModels = map[string]imageModelSpec{
+	"dall-e-2": {
+		sizes:   []string{"1024x1024", "512x512", "256x256"},
+		aliases: map[string]string{"square": "1024x1024"},
+		maxN:    10,
+	},
+	"dall-e-3": {
+		sizes:     []string{"1024x1024", "1792x1024", "1024x1792"},
+		aliases:   map[string]string{"square": "1024x1024", "landscape": "1792x1024", "portrait": "1024x1792"},
+		qualities: []string{"standard", "hd"},
+		maxN:      1,
+	},
+	"gpt-image-1": {
+		sizes:     []string{"1024x1024", "1536x1024", "1024x1536", "auto"},
+		aliases:   map[string]string{"square": "1024x1024", "landscape": "1536x1024", "portrait": "1024x1536"},
+		qualities: []string{"low", "medium", "high", "auto"},
+		maxN:      10,
+	},
+}
+
+// size aliases in the order they are offered
+var sizeAliases = []string{"square", "landscape", "portrait"}
+
+const defaultMaxImages = 10
+
+// imageSpecFor returns the spec of the model matching the longest known prefix, so that
+// versions like gpt-image-1-mini use the gpt-image-1 spec; false for unknown models
+func imageSpecFor(model string) (imageModelSpec, bool) {
+	var found imageModelSpec
+	best := 0
+	for name, spec := range imageModels {
+		if strings.HasPrefix(model, name) && len(name) > best {
+			found, best = spec, len(name)
+		}
+	}
+	return found, best > 0
+}
+
+// resolveSize maps aliases to the model sizes; an alias the model has no size for is an error
+func (s imageModelSpec) resolveSize(size string) (string, error) {
+	alias := strings.ToLower(size)
+	if !containsString(sizeAliases, alias) || s.aliases == nil {
+		return size, nil
+	}
+	if resolved, ok := s.aliases[alias]; ok {
+		return resolved, nil
+	}
+	return "", &core.OptionError{Option: "size", Value: size, Allowed: s.allowedSizes()}
+}
+
+// allowedSizes lists the sizes of the model followed by its aliases
+func (s imageModelSpec) allowedSizes() []string {
+	allowed := append([]string{}, s.sizes...)
+	for _, alias := range sizeAliases {
+		if _, ok := s.aliases[alias]; ok {
+			allowed = append(allowed, alias)
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,66 @@
+package ai
+
+import (
+	"Brainy/core"
+	"errors"
+	"testing"
+)
+
+func TestResolveSize(t *testing.T) {
+	tests := []struct {
+		model string
+		size  string
+		want  string // empty when the model has no such size
+	}{
+		{"dall-e-2", "square", "1024x1024"},
+		{"dall-e-2", "landscape", ""},
+		{"dall-e-2", "portrait", ""},
+		{"dall-e-2", "512x512", "512x512"},
+		{"dall-e-3", "square", "1024x1024"},
+		{"dall-e-3", "landscape", "1792x1024"},
+		{"dall-e-3", "Portrait", "1024x1792"},
+		{"gpt-image-1", "square", "1024x1024"},
+		{"gpt-image-1", "landscape", "1536x1024"},
+		{"gpt-image-1", "portrait", "1024x1536"},
+		{"gpt-image-1", "auto", "auto"},
+		{"gpt-image-1-mini", "landscape", "1536x1024"},
+		{"unknown-model", "landscape", "landscape"},
+		{"dall-e-3", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model+" "+tt.size, func(t *testing.T) {
+			spec, _ := imageSpecFor(tt.model)
+			got, err := spec.resolveSize(tt.size)
+			if tt.want == "" && tt.size != "" {
+				var optionErr *core.OptionError
+				if !errors.As(err, &optionErr) || optionErr.Option != "size" {
+					t.Fatalf("resolveSize(%q) = %q, %v, want *core.OptionError", tt.size, got, err)
+				}
+				if containsString(optionErr.Allowed, tt.size) {
+					t.Errorf("allowed sizes %v offer %s", optionErr.Allowed, tt.size)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("resolveSize(%q) = %q, %v, want %q", tt.size, got, err, tt.want)
+			}
+		})
+	}
+}
+
+// every alias resolves to a size the model accepts
+func TestSizeAliasesValid(t *testing.T) {
+	for model, spec := range imageModels {
+		if _, ok := spec.aliases["square"]; !ok {
+			t.Errorf("%s has no square size", model)
+		}
+		for alias, size := range spec.aliases {
+			if !containsString(sizeAliases, alias) {
+				t.Errorf("%s: unknown alias %s", model, alias)
+			}
+			if !containsString(spec.sizes, size) {
+				t.Errorf("%s: %s maps to %s, not one of %v", model, alias, size, spec.sizes)
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package ai
+
+import (
+	"Brainy/core"
+	"Brainy/holder"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// imageToolName is the tool the chat model calls to answer with a picture
+const imageToolName = "generate_image"
+
+// SetImageSender sets the receiver of pictures the model draws while answering
+func (c *ChatGPT) SetImageSender(sender core.ImageSender) {
+	c.imageSender = sender
+}
+
+// imageRouting tells whether the chat model decides itself between answering and drawing
+func (c *ChatGPT) imageRouting(chatId int64) bool {
+	return c.conf.ImageIntent.Tool && c.imageSender != nil && c.ImageIntent(chatId)
+}
+
+// imageTool describes generate_image with the configured style presets
+func (c *ChatGPT) imageTool() Tool {
+	return Tool{
+		Type: "function",
+		Function: ToolFunction{
+			Name: imageToolName,
+			Description: "Draw a picture and send it to the user. Call it only when the user asks to create, draw or change " +
+				"a picture, including follow-ups to a picture drawn earlier like \"make it blue\"; the picture is the whole answer.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"prompt": map[string]interface{}{
+						"type":        "string",
+						"description": "Detailed description of the whole picture in English; for changes repeat the earlier picture with the change applied",
+					},
+					"style": map[string]interface{}{
+						"type":        "string",
+						"enum":        c.conf.ImageStyleNames(),
+						"description": "Style preset, omit to use the chat style",
+					},
+				},
+				"required": []string{"prompt"},
+			},
+		},
+	}
+}
+
+// drawImage generates the picture requested by the tool call, sends it and records the decision in the context
+// so that follow-ups refer to the picture
+func (c *ChatGPT) drawImage(chatId, userId int64, call ToolCall) error {
+	var args struct {
+		Prompt string `json:"prompt"`
+		Style  string `json:"style"`
+	}
+	if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	args.Prompt = strings.TrimSpace(args.Prompt)
+	if args.Prompt == "" {
+		return fmt.Errorf("prompt is required")
+	}
+
+	images, err := c.GenerateImage(chatId, userId, core.ImageOptions{Prompt: args.Prompt, Style: args.Style})
+	if err != nil {
+		return err
+	}
+	c.imageSender.SendImages(chatId, userId, images)
+	c.countIntent(chatId, intentDrawn)
+
+	note := "[drew a picture: " + args.Prompt
+	if args.Style != "" {
+		note += "; style: " + args.Style
+	}
+	c.contextManager.UpdateUserContext(chatId, holder.Message{Text: note + "]"})
+
+	c.log.With(
+		slog.Int64("user", chatId),
+		slog.String("prompt", args.Prompt),
+		slog.String("style", args.Style),
+	).Info("image routed by the chat model")
+	return nil
+}
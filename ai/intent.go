@@ -0,0 +1,46 @@
+package ai
+
+import (
+	"log/slog"
+	"sync/atomic"
+)
+
+// outcomes of image intent detection, counted for the log
+const (
+	intentDisabled  = iota // detection is off in the chat
+	intentRejected         // the classifier rejected the message
+	intentConfirmed        // borderline message, the model found an image request
+	intentDismissed        // borderline message, the model found no image request
+	intentFailed           // borderline message, the model call failed
+	intentRouted           // borderline message left to the generate_image tool of the chat model
+	intentDrawn            // the chat model called generate_image
+	intentOutcomes
+)
+
+var intentNames = [intentOutcomes]string{"disabled", "rejected", "llm_image", "llm_text", "llm_failed", "routed", "tool_image"}
+
+type intentCounts struct {
+	counts [intentOutcomes]atomic.Int64
+	total  atomic.Int64
+}
+
+// countIntent counts the outcome and logs all counts every ImageIntent.LogEvery decisions
+func (c *ChatGPT) countIntent(chatId int64, outcome int) {
+	c.intents.counts[outcome].Add(1)
+	total := c.intents.total.Add(1)
+	c.log.With(
+		slog.Int64("user", chatId),
+		slog.String("outcome", intentNames[outcome]),
+	).Debug("image intent")
+	if every := int64(c.conf.ImageIntent.LogEvery); every > 0 && total%every == 0 {
+		c.logIntents()
+	}
+}
+
+func (c *ChatGPT) logIntents() {
+	attrs := []any{slog.Int64("total", c.intents.total.Load())}
+	for outcome, name := range intentNames {
+		attrs = append(attrs, slog.Int64(name, c.intents.counts[outcome].Load()))
+	}
+	c.log.With(attrs...).Info("image intent decisions")
+}
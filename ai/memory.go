@@ -0,0 +1,160 @@
+package ai
+
+import (
+	"Brainy/lib/sl"
+	"Brainy/storage"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// SetVectorStorage enables the long-term memory: messages are embedded and stored, relevant ones are
+// recalled into the prompt after they leave the dialog context
+func (c *ChatGPT) SetVectorStorage(store storage.VectorStorage) {
+	c.vectors = store
+}
+
+func (c *ChatGPT) memoryEnabled() bool {
+	return c.conf.Memory.Enabled && c.vectors != nil
+}
+
+// embed returns vectors of the texts in their order and records the embedded tokens; the memory model
+// embeds documents too, so that questions are comparable with both
+func (c *ChatGPT) embed(ctx context.Context, chatId, userId int64, texts ...string) ([][]float32, error) {
+	request := &EmbeddingRequest{Model: c.conf.Memory.Model, Input: texts}
+	response, err := c.provider.Embed(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	if response.Error != nil {
+		return nil, newAPIError(0, response.Error)
+	}
+	if len(response.Data) != len(texts) {
+		return nil, fmt.Errorf("got %d embeddings for %d texts", len(response.Data), len(texts))
+	}
+	vectors := make([][]float32, len(texts))
+	for _, item := range response.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	model := request.Model
+	if response.Model != "" {
+		model = response.Model
+	}
+	c.usage.RecordEmbedding(chatId, userId, model, response.Usage.PromptTokens)
+	return vectors, nil
+}
+
+// remembers tells whether a message is worth storing, short replies carry nothing to recall
+func (c *ChatGPT) remembers(text string) bool {
+	return c.memoryEnabled() && utf8.RuneCountInString(strings.TrimSpace(text)) >= c.conf.Memory.MinLength
+}
+
+// rememberAsync stores the message in the long-term memory, the vector is computed when it is nil
+func (c *ChatGPT) rememberAsync(chatId, userId int64, isUser bool, text string, vector []float32) {
+	if !c.remembers(text) {
+		return
+	}
+	timestamp := time.Now()
+	go func() {
+		log := c.log.With(slog.Int64("user", chatId))
+		if vector == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			vectors, err := c.embed(ctx, chatId, userId, text)
+			if err != nil {
+				log.Error("embedding message", sl.Err(err))
+				return
+			}
+			vector = vectors[0]
+		}
+		err := c.vectors.SaveMemory(&storage.MemoryRecord{
+			ChatId:    chatId,
+			UserId:    userId,
+			IsUser:    isUser,
+			Text:      text,
+			Model:     c.conf.Memory.Model,
+			Vector:    vector,
+			Timestamp: timestamp,
+		})
+		if err != nil {
+			log.Error("saving memory", sl.Err(err))
+		}
+	}()
+}
+
+// questionVector embeds the question when the memory or documents of the chat need it, nil otherwise
+func (c *ChatGPT) questionVector(chatId, userId int64, question string) []float32 {
+	if !c.remembers(question) && !c.hasDocuments(chatId) {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	vectors, err := c.embed(ctx, chatId, userId, question)
+	if err != nil {
+		c.log.With(slog.Int64("user", chatId)).Error("embedding question", sl.Err(err))
+		return nil
+	}
+	return vectors[0]
+}
+
+// recall remembers the question and returns earlier messages of the chat relevant to it that are no longer
+// in the dialog context, formatted for the system message within the token budget
+func (c *ChatGPT) recall(chatId, userId int64, question string, vector []float32) string {
+	if vector == nil || !c.remembers(question) {
+		return ""
+	}
+	log := c.log.With(slog.Int64("user", chatId))
+	memories, err := c.vectors.SearchMemories(chatId, c.conf.Memory.Model, vector, c.conf.Memory.TopK, c.conf.Memory.Window)
+	// the question is stored after the search so that it does not find itself
+	c.rememberAsync(chatId, userId, true, question, vector)
+	if err != nil {
+		log.Error("searching memories", sl.Err(err))
+		return ""
+	}
+
+	inContext := make(map[string]bool)
+	if dialogContext := c.contextManager.GetUserContext(chatId); dialogContext != nil {
+		for _, message := range dialogContext.Messages {
+			inContext[message.Text] = true
+		}
+	}
+
+	var snippets []string
+	tokens := 0
+	encoding := c.chatEncoding(chatId)
+	for _, memory := range memories {
+		if memory.Score < c.conf.Memory.MinScore || inContext[memory.Text] {
+			continue
+		}
+		snippet := memorySnippet(memory.MemoryRecord)
+		snippetTokens := encoding.Count(snippet)
+		if tokens+snippetTokens > c.conf.Memory.Budget {
+			continue
+		}
+		snippets = append(snippets, snippet)
+		tokens += snippetTokens
+	}
+	if len(snippets) == 0 {
+		return ""
+	}
+	log.With(
+		slog.Int("snippets", len(snippets)),
+		slog.Int("tokens", tokens),
+	).Debug("memories recalled")
+	return "Earlier messages of this conversation that may be relevant:\n" + strings.Join(snippets, "\n")
+}
+
+func memorySnippet(record storage.MemoryRecord) string {
+	author := "assistant"
+	if record.IsUser {
+		author = "user"
+	}
+	return "- " + record.Timestamp.Format("2006-01-02") + ", " + author + ": " + record.Text
+}
@@ -0,0 +1,126 @@
+package ai
+
+import (
+	"Brainy/core"
+	"Brainy/lib/tokenizer"
+	"Brainy/storage"
+	"log/slog"
+)
+
+// fitModel adapts the request to what the model supports according to the registry: the temperature and
+// the answer token limit are set, unsupported tools, schemas and pictures are left out.
+// Requests to models missing from the registry are sent as they are
+func (p *OpenAIProvider) fitModel(request *GPTRequest) *GPTRequest {
+	if p.conf == nil {
+		return request
+	}
+	spec, ok := p.conf.ModelSpec(request.Model)
+	if !ok {
+		return request
+	}
+	fitted := *request
+
+	fitted.Temperature = nil
+	if spec.Temperature {
+		temperature := p.conf.Models.Temperature
+		fitted.Temperature = &temperature
+	}
+	fitted.MaxTokens, fitted.MaxCompletionTokens = 0, 0
+	if spec.MaxOutput > 0 {
+		if spec.MaxTokens {
+			fitted.MaxTokens = spec.MaxOutput
+		} else {
+			fitted.MaxCompletionTokens = spec.MaxOutput
+		}
+	}
+	if !spec.Tools {
+		fitted.Tools, fitted.ToolChoice = nil, ""
+	}
+	if !spec.JSONSchema && fitted.ResponseFormat != nil && fitted.ResponseFormat.JSONSchema != nil {
+		// the schema goes into the prompt, as for providers that reject response_format
+		fitted.Messages = append(append([]Message{}, fitted.Messages...), Message{
+			Role:    RoleUser,
+			Content: TextContent(schemaInstruction(fitted.ResponseFormat.JSONSchema.Schema)),
+		})
+		fitted.ResponseFormat = nil
+	}
+	if !spec.Vision {
+		fitted.Messages = withoutPictures(fitted.Messages)
+	}
+	return &fitted
+}
+
+// withoutPictures replaces attached pictures with a mention
+func withoutPictures(messages []Message) []Message {
+	result := make([]Message, len(messages))
+	for i, message := range messages {
+		if message.Content.Images() > 0 {
+			message.Content = TextContent("[picture] " + message.Content.String())
+		}
+		result[i] = message
+	}
+	return result
+}
+
+// modelFor returns the model of the task, the chat model for answers. Messages with pictures go to the vision
+// model unless the chat chose a model that sees them
+func (c *ChatGPT) modelFor(chatId int64, task string, messages []Message) string {
+	model := c.conf.ModelFor(task)
+	chosen := false
+	if task == core.TaskChat {
+		if selected := c.chatSettings(chatId).Model; selected != "" {
+			model, chosen = selected, true
+		}
+	}
+	if c.conf.VisionModel == "" || !hasPictures(messages) {
+		return model
+	}
+	if spec, ok := c.conf.ModelSpec(model); chosen && ok && spec.Vision {
+		return model
+	}
+	return c.conf.VisionModel
+}
+
+func hasPictures(messages []Message) bool {
+	for _, message := range messages {
+		if message.Content.Images() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// fitHistory drops the oldest dialog turns until the prompt fits the context window of the model with room
+// left for the answer; the system message and the last turn are kept. Models missing from the registry
+// or without a context size are not clamped
+func (c *ChatGPT) fitHistory(chatId int64, model string, messages []Message) []Message {
+	spec, ok := c.conf.ModelSpec(model)
+	if !ok || spec.Context <= 0 {
+		return messages
+	}
+	budget := spec.Context - spec.MaxOutput
+	tokens := c.countTokens(model, messages)
+	if tokens <= budget {
+		return messages
+	}
+
+	first := 0
+	if len(messages) > 0 && messages[0].Role == RoleSystem {
+		first = 1
+	}
+	encoding := tokenizer.ForModel(model)
+	dropped := 0
+	for first+dropped < len(messages)-1 && tokens > budget {
+		message := messages[first+dropped]
+		tokens -= messageTokens + encoding.Count(message.Content.String()) + message.Content.Images()*storage.ImageTokens
+		dropped++
+	}
+	c.log.With(
+		slog.Int64("user", chatId),
+		slog.String("model", model),
+		slog.Int("dropped", dropped),
+		slog.Int("prompt_tokens", tokens),
+		slog.Int("budget", budget),
+	).Info("history clamped to the model context")
+	return append(messages[:first:first], messages[first+dropped:]...)
+}
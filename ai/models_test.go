@@ -0,0 +1,65 @@
+package ai
+
+import (
+	"Brainy/core"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestFitHistory(t *testing.T) {
+	conf := &core.Config{}
+	c := &ChatGPT{conf: conf, log: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	turn := func(role, text string) Message { return Message{Role: role, Content: TextContent(text)} }
+	long := strings.Repeat("word ", 12)
+	system := turn(RoleSystem, "Be brief.")
+	history := []Message{
+		system,
+		turn(RoleUser, "first "+long),
+		turn(RoleAssistant, "second "+long),
+		turn(RoleUser, "third "+long),
+		turn(RoleAssistant, "fourth"),
+		turn(RoleUser, "question"),
+	}
+	// the window of the small model holds the system message and the last three turns besides the answer
+	conf.Models.Registry = map[string]core.ModelSpec{
+		"small":    {Context: c.countTokens("small", append([]Message{system}, history[3:]...)) + 20, MaxOutput: 20},
+		"no-limit": {},
+	}
+
+	tests := []struct {
+		name     string
+		model    string
+		messages []Message
+		want     []string // first words of the kept messages
+	}{
+		{"oldest turns dropped", "small", history, []string{"Be", "third", "fourth", "question"}},
+		{"fits as is", "small", []Message{system, turn(RoleUser, "hi")}, []string{"Be", "hi"}},
+		{"last turn kept", "small", []Message{system, turn(RoleUser, long+long)}, []string{"Be", "word"}},
+		{"without system message", "small", history[1:], []string{"third", "fourth", "question"}},
+		{"unknown model", "other", history, []string{"Be", "first", "second", "third", "fourth", "question"}},
+		{"no context size", "no-limit", history, []string{"Be", "first", "second", "third", "fourth", "question"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := c.fitHistory(1, tt.model, tt.messages)
+			var words []string
+			for _, message := range got {
+				words = append(words, strings.Fields(message.Content.String())[0])
+			}
+			if strings.Join(words, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("kept %v, want %v", words, tt.want)
+			}
+			if spec, ok := conf.ModelSpec(tt.model); ok && spec.Context > 0 && len(got) > 2 {
+				if tokens := c.countTokens(tt.model, got); tokens > spec.Context-spec.MaxOutput {
+					t.Errorf("%d prompt tokens exceed the budget of %d", tokens, spec.Context-spec.MaxOutput)
+				}
+			}
+		})
+	}
+	if history[1].Content.String() != "first "+long {
+		t.Error("the messages of the caller were changed")
+	}
+}
@@ -0,0 +1,299 @@
+package ai
+
+import (
+	"Brainy/core"
+	"Brainy/lib/sl"
+	"Brainy/storage"
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// a repeated check of the same text, like intent detection followed by the answer, reuses the decision
+	moderationReuse = time.Minute
+	// blocked text kept in the audit log
+	auditTextLimit = 1000
+	// decisions counted in the report
+	moderationReportDays = 30
+	moderationRecent     = 5
+)
+
+// categories of the moderation endpoint that thresholds can be set for
+var moderationCategories = []string{
+	"harassment", "harassment/threatening", "hate", "hate/threatening", "illicit", "illicit/violent",
+	"self-harm", "self-harm/instructions", "self-harm/intent", "sexual", "sexual/minors", "violence", "violence/graphic",
+}
+
+// ModerationProvider classifies content by the usage policy categories
+type ModerationProvider interface {
+	Moderate(ctx context.Context, request *ModerationRequest) (*ModerationResponse, error)
+}
+
+type ModerationRequest struct {
+	Model string `json:"model,omitempty"`
+	Input string `json:"input"`
+}
+
+type ModerationResponse struct {
+	Id      string `json:"id"`
+	Model   string `json:"model"`
+	Results []struct {
+		Flagged        bool               `json:"flagged"`
+		Categories     map[string]bool    `json:"categories"`
+		CategoryScores map[string]float64 `json:"category_scores"`
+	} `json:"results"`
+	Error *Error `json:"error"`
+}
+
+// Moderate sends content to the moderations endpoint
+func (p *OpenAIProvider) Moderate(ctx context.Context, request *ModerationRequest) (*ModerationResponse, error) {
+	var response ModerationResponse
+	if err := p.postJSON(ctx, "/moderations", request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// moderationVerdict is the last decision of a chat
+type moderationVerdict struct {
+	kind string
+	text string
+	err  error
+	at   time.Time
+}
+
+// SetModerationStorage sets the audit log of moderation decisions
+func (c *ChatGPT) SetModerationStorage(store storage.ModerationStorage) {
+	c.moderation = store
+}
+
+// moderate checks content before it goes to a paid call: the keyword policy first, then the moderation endpoint.
+// Blocked content returns *core.ModerationError; every decision is written to the audit log
+func (c *ChatGPT) moderate(chatId, userId int64, kind, text string) error {
+	if !c.conf.Moderation.Enabled || strings.TrimSpace(text) == "" {
+		return nil
+	}
+	if last, ok := c.moderated.Load(chatId); ok {
+		verdict := last.(moderationVerdict)
+		if verdict.kind == kind && verdict.text == text && time.Since(verdict.at) < moderationReuse {
+			return verdict.err
+		}
+	}
+
+	record := &storage.ModerationRecord{ChatId: chatId, UserId: userId, Kind: kind, Source: "keywords"}
+	if categories := c.keywordCategories(text); len(categories) > 0 {
+		record.Blocked, record.Categories = true, categories
+	} else if c.conf.Moderation.Endpoint {
+		record.Source = "endpoint"
+		scores, err := c.moderationScores(chatId, text)
+		if err != nil {
+			c.log.With(slog.Int64("user", chatId)).Warn("moderation endpoint", sl.Err(err))
+			record.Error = err.Error()
+			record.Blocked = c.conf.Moderation.FailClosed
+		} else {
+			record.Scores = scores
+			thresholds := c.moderationThresholds(chatId)
+			for category, score := range scores {
+				if score >= c.categoryThreshold(thresholds, category) {
+					record.Categories = append(record.Categories, category)
+				}
+			}
+			sort.Strings(record.Categories)
+			record.Blocked = len(record.Categories) > 0
+		}
+	}
+
+	var err error
+	if record.Blocked {
+		record.Text = auditText(text)
+		err = &core.ModerationError{Kind: kind, Categories: record.Categories}
+		c.log.With(
+			slog.Int64("user", chatId),
+			slog.Int64("author", userId),
+			slog.String("kind", kind),
+			slog.String("source", record.Source),
+			slog.Any("categories", record.Categories),
+		).Info("content blocked")
+	}
+	c.auditAsync(record)
+	c.moderated.Store(chatId, moderationVerdict{kind: kind, text: text, err: err, at: time.Now()})
+	return err
+}
+
+// moderationScores returns category scores of the moderation endpoint
+func (c *ChatGPT) moderationScores(chatId int64, text string) (map[string]float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	response, err := c.provider.Moderate(ctx, &ModerationRequest{Model: c.conf.Moderation.Model, Input: text})
+	if err != nil {
+		return nil, err
+	}
+	if response.Error != nil {
+		return nil, newAPIError(0, response.Error)
+	}
+	if len(response.Results) == 0 {
+		return nil, fmt.Errorf("empty moderation results")
+	}
+	c.log.With(
+		slog.Int64("user", chatId),
+		slog.String("model", response.Model),
+		slog.Bool("flagged", response.Results[0].Flagged),
+	).Debug("content moderated")
+	return response.Results[0].CategoryScores, nil
+}
+
+// keywordCategories returns categories of the local policy whose words or phrases occur in the text
+func (c *ChatGPT) keywordCategories(text string) []string {
+	text = strings.ToLower(text)
+	var categories []string
+	for category, phrases := range c.conf.Moderation.Keywords {
+		for _, phrase := range phrases {
+			if phrase = strings.ToLower(strings.TrimSpace(phrase)); phrase != "" && containsPhrase(text, phrase) {
+				categories = append(categories, category)
+				break
+			}
+		}
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// containsPhrase finds the phrase as whole words, so "ass" does not match "class"
+func containsPhrase(text, phrase string) bool {
+	for offset := 0; offset < len(text); {
+		i := strings.Index(text[offset:], phrase)
+		if i < 0 {
+			return false
+		}
+		start, end := offset+i, offset+i+len(phrase)
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !isWordRune(before) && !isWordRune(after) {
+			return true
+		}
+		_, size := utf8.DecodeRuneInString(text[start:])
+		offset = start + size
+	}
+	return false
+}
+
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// moderationThresholds returns category thresholds of the config overridden by the chat settings
+func (c *ChatGPT) moderationThresholds(chatId int64) map[string]float64 {
+	thresholds := maps.Clone(c.conf.Moderation.Thresholds)
+	if thresholds == nil {
+		thresholds = make(map[string]float64)
+	}
+	maps.Copy(thresholds, c.chatSettings(chatId).ModerationThresholds)
+	return thresholds
+}
+
+// categoryThreshold looks up the category, then its parent like "violence" for "violence/graphic", then the default
+func (c *ChatGPT) categoryThreshold(thresholds map[string]float64, category string) float64 {
+	if threshold, ok := thresholds[category]; ok {
+		return threshold
+	}
+	if parent, _, found := strings.Cut(category, "/"); found {
+		if threshold, ok := thresholds[parent]; ok {
+			return threshold
+		}
+	}
+	return c.defaultThreshold()
+}
+
+func (c *ChatGPT) defaultThreshold() float64 {
+	if c.conf.Moderation.Threshold > 0 {
+		return c.conf.Moderation.Threshold
+	}
+	return 0.5
+}
+
+// auditAsync writes the decision to the audit log without delaying the answer
+func (c *ChatGPT) auditAsync(record *storage.ModerationRecord) {
+	if c.moderation == nil {
+		return
+	}
+	record.Timestamp = time.Now()
+	go func() {
+		if err := c.moderation.SaveDecision(record); err != nil {
+			c.log.With(slog.Int64("user", record.ChatId)).Error("saving moderation decision", sl.Err(err))
+		}
+	}()
+}
+
+func auditText(text string) string {
+	if utf8.RuneCountInString(text) <= auditTextLimit {
+		return text
+	}
+	return string([]rune(text)[:auditTextLimit]) + "…"
+}
+
+// ModerationReport returns thresholds and recent decisions of the chat
+func (c *ChatGPT) ModerationReport(chatId int64) (core.ModerationReport, error) {
+	report := core.ModerationReport{
+		Enabled:        c.conf.Moderation.Enabled,
+		Endpoint:       c.conf.Moderation.Endpoint,
+		Threshold:      c.defaultThreshold(),
+		Thresholds:     c.conf.Moderation.Thresholds,
+		ChatThresholds: c.chatSettings(chatId).ModerationThresholds,
+	}
+	if c.moderation == nil {
+		return report, nil
+	}
+	var err error
+	report.Checked, report.Blocked, err = c.moderation.CountDecisions(chatId, time.Now().AddDate(0, 0, -moderationReportDays))
+	if err != nil {
+		return report, fmt.Errorf("counting moderation decisions: %w", err)
+	}
+	records, err := c.moderation.ListDecisions(chatId, true, moderationRecent)
+	if err != nil {
+		return report, fmt.Errorf("listing moderation decisions: %w", err)
+	}
+	for _, record := range records {
+		report.Recent = append(report.Recent, core.ModerationDecision{
+			UserId:     record.UserId,
+			Kind:       record.Kind,
+			Source:     record.Source,
+			Categories: record.Categories,
+			Text:       record.Text,
+			CreatedAt:  record.Timestamp,
+		})
+	}
+	return report, nil
+}
+
+// SetModerationThreshold sets the score of a category that blocks content in the chat, a negative one resets it
+func (c *ChatGPT) SetModerationThreshold(chatId int64, category string, threshold float64) error {
+	if !slices.Contains(moderationCategories, category) {
+		return &core.OptionError{Option: "category", Value: category, Allowed: moderationCategories}
+	}
+	if threshold > 1 {
+		return &core.OptionError{Option: "threshold", Value: strconv.FormatFloat(threshold, 'f', -1, 64), Allowed: []string{"0 to 1", "default"}}
+	}
+	return c.updateSettings(chatId, func(settings *storage.ChatSettings) {
+		// the stored map may be shared with the storage
+		thresholds := maps.Clone(settings.ModerationThresholds)
+		if thresholds == nil {
+			thresholds = make(map[string]float64)
+		}
+		if threshold < 0 {
+			delete(thresholds, category)
+		} else {
+			thresholds[category] = threshold
+		}
+		settings.ModerationThresholds = thresholds
+	})
+}
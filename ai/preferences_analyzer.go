@@ -5,11 +5,8 @@ import (
 	"Brainy/lib/sl"
 	"Brainy/storage"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
-	"net/http"
 	"strings"
 	"sync"
 	"time"
@@ -27,7 +24,8 @@ type PreferencesAnalyzer struct {
 	log              *slog.Logger
 	contextStorage   storage.ContextStorage
 	prefsStorage     storage.PreferencesStorage
-	httpClient       *http.Client
+	provider         CompletionProvider
+	usage            *UsageTracker
 	stopChan         chan struct{}
 	wg               sync.WaitGroup
 	analysisInFlight sync.Map // map[int64]bool to prevent concurrent analysis for same user
@@ -39,19 +37,23 @@ func NewPreferencesAnalyzer(
 	log *slog.Logger,
 	contextStorage storage.ContextStorage,
 	prefsStorage storage.PreferencesStorage,
+	provider CompletionProvider,
 ) *PreferencesAnalyzer {
 	return &PreferencesAnalyzer{
 		conf:           conf,
 		log:            log.With(sl.Module("prefs-analyzer")),
 		contextStorage: contextStorage,
 		prefsStorage:   prefsStorage,
-		httpClient: &http.Client{
-			Timeout: 120 * time.Second,
-		},
-		stopChan: make(chan struct{}),
+		provider:       provider,
+		stopChan:       make(chan struct{}),
 	}
 }
 
+// SetUsageTracker sets the tracker recording consumption of analysis calls
+func (pa *PreferencesAnalyzer) SetUsageTracker(usage *UsageTracker) {
+	pa.usage = usage
+}
+
 // StartBackgroundAnalysis starts the background analysis ticker
 func (pa *PreferencesAnalyzer) StartBackgroundAnalysis() {
 	pa.wg.Add(1)
@@ -144,16 +146,13 @@ func (pa *PreferencesAnalyzer) AnalyzeUser(userId int64) error {
 	analysisPrompt := pa.buildAnalysisPrompt(userMessages)
 
 	// Call OpenAI for analysis
-	analysis, err := pa.callOpenAI(analysisPrompt)
+	analysis, err := pa.callOpenAI(userId, analysisPrompt)
 	if err != nil {
 		return fmt.Errorf("calling OpenAI: %w", err)
 	}
 
-	// Parse and save preferences
-	prefs, err := pa.parseAnalysisResponse(userId, analysis)
-	if err != nil {
-		return fmt.Errorf("parsing analysis: %w", err)
-	}
+	// Build and save preferences
+	prefs := pa.buildPreferences(userId, analysis)
 
 	if err := pa.prefsStorage.SaveUserPreferences(prefs); err != nil {
 		return fmt.Errorf("saving preferences: %w", err)
@@ -175,81 +174,32 @@ User Messages:
 %s
 
 Based on these messages, provide a JSON response with the following fields:
-{
-  "preferred_language": "the language the user writes in most (e.g., English, Ukrainian, Spanish)",
-  "formality": "formal, informal, or neutral based on how they communicate",
-  "verbosity": "verbose, concise, or balanced based on their message length and detail",
-  "favorite_topics": ["list", "of", "topics", "they", "discuss", "frequently"],
-  "technical_level": "beginner, intermediate, or expert based on technical vocabulary usage",
-  "humor_preference": "none, occasional, or frequent based on humor in their messages",
-  "response_length": "short, medium, or long based on the detail they seem to expect"
+- preferred_language: the language the user writes in most (e.g., English, Ukrainian, Spanish)
+- formality: formal, informal, or neutral based on how they communicate
+- verbosity: verbose, concise, or balanced based on their message length and detail
+- favorite_topics: list of topics they discuss frequently
+- technical_level: beginner, intermediate, or expert based on technical vocabulary usage
+- humor_preference: none, occasional, or frequent based on humor in their messages
+- response_length: short, medium, or long based on the detail they seem to expect`, messagesText)
 }
 
-Respond ONLY with the JSON object, no other text.`, messagesText)
-}
-
-func (pa *PreferencesAnalyzer) callOpenAI(prompt string) (string, error) {
+// callOpenAI requests the analysis as structured output validated against the PreferencesAnalysis schema
+func (pa *PreferencesAnalyzer) callOpenAI(userId int64, prompt string) (*storage.PreferencesAnalysis, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
 	defer cancel()
 
-	request := NewRequest(prompt, pa.conf.Model)
-	jsonBytes, err := json.Marshal(request)
-	if err != nil {
-		return "", err
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/chat/completions", strings.NewReader(string(jsonBytes)))
-	if err != nil {
-		return "", err
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", pa.conf.OpenAIApiKey))
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := pa.httpClient.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			pa.log.Warn("closing body", slog.String("error", err.Error()))
-		}
-	}(resp.Body)
-
-	body, err := io.ReadAll(resp.Body)
+	request := NewRequest(prompt, pa.conf.ModelFor(core.TaskAnalysis))
+	var analysis storage.PreferencesAnalysis
+	err := completeStructured(ctx, pa.provider, request, "preferences_analysis", &analysis, func(completion *ChatCompletion) {
+		pa.usage.RecordCompletion(userId, userId, storage.UsageAnalysis, request.Model, completion)
+	})
 	if err != nil {
-		return "", err
-	}
-
-	var chatCompletion ChatCompletion
-	if err := json.Unmarshal(body, &chatCompletion); err != nil {
-		return "", err
-	}
-
-	if chatCompletion.Error != nil && chatCompletion.Error.Code != "" {
-		return "", fmt.Errorf("OpenAI error: %s", chatCompletion.Error.Message)
+		return nil, err
 	}
-
-	if len(chatCompletion.Choices) == 0 {
-		return "", fmt.Errorf("empty choices in response")
-	}
-
-	return chatCompletion.Choices[0].Message.Content, nil
+	return &analysis, nil
 }
 
-func (pa *PreferencesAnalyzer) parseAnalysisResponse(userId int64, response string) (*storage.UserPreferences, error) {
-	// Clean up response (remove markdown code blocks if present)
-	response = strings.TrimPrefix(response, "```json")
-	response = strings.TrimPrefix(response, "```")
-	response = strings.TrimSuffix(response, "```")
-	response = strings.TrimSpace(response)
-
-	var analysis storage.PreferencesAnalysis
-	if err := json.Unmarshal([]byte(response), &analysis); err != nil {
-		return nil, fmt.Errorf("parsing JSON: %w (response: %s)", err, response)
-	}
-
+func (pa *PreferencesAnalyzer) buildPreferences(userId int64, analysis *storage.PreferencesAnalysis) *storage.UserPreferences {
 	// Get existing preferences to preserve metadata
 	existing, _ := pa.prefsStorage.GetUserPreferences(userId)
 
@@ -272,7 +222,7 @@ func (pa *PreferencesAnalyzer) parseAnalysisResponse(userId int64, response stri
 		prefs.CreatedAt = time.Now()
 	}
 
-	return prefs, nil
+	return prefs
 }
 
 // GetUserPreferences returns preferences for prompt injection
@@ -0,0 +1,368 @@
+package ai
+
+import (
+	"Brainy/core"
+	"Brainy/lib/sl"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBaseURL     = "https://api.openai.com/v1"
+	defaultMaxAttempts = 4
+	baseBackoff        = 500 * time.Millisecond
+	maxBackoff         = 30 * time.Second
+)
+
+// CompletionProvider sends chat completion requests to an OpenAI-compatible backend
+type CompletionProvider interface {
+	Complete(ctx context.Context, request *GPTRequest) (*ChatCompletion, error)
+}
+
+// ImageProvider sends image generation requests to an OpenAI-compatible backend
+type ImageProvider interface {
+	GenerateImage(ctx context.Context, request *ImageGenerationRequest) (*ImageGenerationResponse, error)
+	EditImage(ctx context.Context, request *ImageEditRequest) (*ImageGenerationResponse, error)
+	VaryImage(ctx context.Context, request *ImageVariationRequest) (*ImageGenerationResponse, error)
+}
+
+// Provider combines all backend capabilities used by the bot
+type Provider interface {
+	CompletionProvider
+	StreamProvider
+	ImageProvider
+	TranscriptionProvider
+	SpeechProvider
+	EmbeddingProvider
+	ModerationProvider
+}
+
+// OpenAIProvider talks to the OpenAI HTTP API or any compatible gateway
+type OpenAIProvider struct {
+	conf        *core.Config
+	baseURL     string
+	audioURL    string // base URL of the transcription endpoint
+	apiKey      string
+	headers     map[string]string
+	maxAttempts int
+	log         *slog.Logger
+	httpClient  *http.Client
+}
+
+// NewOpenAIProvider creates a provider using base URL, headers and timeout from config
+func NewOpenAIProvider(conf *core.Config, log *slog.Logger) *OpenAIProvider {
+	baseURL := strings.TrimSuffix(conf.Provider.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	timeout := time.Duration(conf.Provider.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 120 * time.Second
+	}
+	audioURL := strings.TrimSuffix(conf.Transcription.BaseURL, "/")
+	if audioURL == "" {
+		audioURL = baseURL
+	}
+	maxAttempts := conf.Provider.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	return &OpenAIProvider{
+		conf:        conf,
+		baseURL:     baseURL,
+		audioURL:    audioURL,
+		apiKey:      conf.OpenAIApiKey,
+		headers:     conf.Provider.Headers,
+		maxAttempts: maxAttempts,
+		log:         log.With(sl.Module("provider")),
+		httpClient: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+// Complete sends a chat completion request and decodes the response
+func (p *OpenAIProvider) Complete(ctx context.Context, request *GPTRequest) (*ChatCompletion, error) {
+	var chatCompletion ChatCompletion
+	if err := p.postJSON(ctx, "/chat/completions", p.fitModel(request), &chatCompletion); err != nil {
+		return nil, err
+	}
+	return &chatCompletion, nil
+}
+
+// GenerateImage sends an image generation request and decodes the response
+func (p *OpenAIProvider) GenerateImage(ctx context.Context, request *ImageGenerationRequest) (*ImageGenerationResponse, error) {
+	var imageResponse ImageGenerationResponse
+	if err := p.postJSON(ctx, "/images/generations", request, &imageResponse); err != nil {
+		return nil, err
+	}
+	return &imageResponse, nil
+}
+
+// EditImage uploads the image and optional mask to the image edits endpoint
+func (p *OpenAIProvider) EditImage(ctx context.Context, request *ImageEditRequest) (*ImageGenerationResponse, error) {
+	fields := imageFields(request.Model, request.N, request.Size)
+	fields["prompt"] = request.Prompt
+	files := []formFile{{Field: "image", Name: "image" + imageExtension(request.Image), Data: request.Image}}
+	if len(request.Mask) > 0 {
+		files = append(files, formFile{Field: "mask", Name: "mask.png", Data: request.Mask})
+	}
+
+	var imageResponse ImageGenerationResponse
+	if err := p.postMultipart(ctx, p.baseURL+"/images/edits", fields, files, &imageResponse); err != nil {
+		return nil, err
+	}
+	return &imageResponse, nil
+}
+
+// VaryImage uploads the image to the image variations endpoint
+func (p *OpenAIProvider) VaryImage(ctx context.Context, request *ImageVariationRequest) (*ImageGenerationResponse, error) {
+	fields := imageFields(request.Model, request.N, request.Size)
+	files := []formFile{{Field: "image", Name: "image.png", Data: request.Image}}
+
+	var imageResponse ImageGenerationResponse
+	if err := p.postMultipart(ctx, p.baseURL+"/images/variations", fields, files, &imageResponse); err != nil {
+		return nil, err
+	}
+	return &imageResponse, nil
+}
+
+// imageFields returns common form fields of image uploads; DALL-E models are asked for base64
+// data, GPT image models always return it and reject the parameter
+func imageFields(model string, n int, size string) map[string]string {
+	fields := map[string]string{"model": model}
+	if n > 0 {
+		fields["n"] = strconv.Itoa(n)
+	}
+	if size != "" {
+		fields["size"] = size
+	}
+	if strings.HasPrefix(model, "dall-e") {
+		fields["response_format"] = "b64_json"
+	}
+	return fields
+}
+
+func imageExtension(data []byte) string {
+	switch http.DetectContentType(data) {
+	case "image/png":
+		return ".png"
+	case "image/webp":
+		return ".webp"
+	default:
+		return ".jpg"
+	}
+}
+
+// postJSON marshals the request, posts it to the given API path and decodes the response into out
+func (p *OpenAIProvider) postJSON(ctx context.Context, path string, request, out interface{}) error {
+	jsonBytes, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("marshalling request: %w", err)
+	}
+
+	resp, err := p.do(ctx, p.baseURL+path, "application/json", jsonBytes)
+	if err != nil {
+		return err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			p.log.Error("closing response body", sl.Err(err))
+		}
+	}(resp.Body)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response body: %w", err)
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
+
+// formFile is a file part of a multipart request
+type formFile struct {
+	Field string
+	Name  string
+	Data  []byte
+}
+
+// postMultipart uploads fields and files as multipart form data to the URL and decodes the response into out
+func (p *OpenAIProvider) postMultipart(ctx context.Context, url string, fields map[string]string, files []formFile, out interface{}) error {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			return fmt.Errorf("writing field %s: %w", name, err)
+		}
+	}
+	for _, file := range files {
+		// image endpoints check the content type of the part
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, file.Field, file.Name))
+		header.Set("Content-Type", http.DetectContentType(file.Data))
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return fmt.Errorf("creating part %s: %w", file.Field, err)
+		}
+		if _, err := part.Write(file.Data); err != nil {
+			return fmt.Errorf("writing part %s: %w", file.Field, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("closing multipart body: %w", err)
+	}
+
+	resp, err := p.do(ctx, url, writer.FormDataContentType(), body.Bytes())
+	if err != nil {
+		return err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			p.log.Error("closing response body", sl.Err(err))
+		}
+	}(resp.Body)
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
+
+// do sends a POST request to the given URL, retrying rate limits, server and transport errors
+// with exponential backoff until the attempt budget or the context deadline runs out
+func (p *OpenAIProvider) do(ctx context.Context, url, contentType string, body []byte) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := p.send(ctx, url, contentType, body)
+		if err == nil {
+			return resp, nil
+		}
+		if attempt >= p.maxAttempts || !retryable(err) || ctx.Err() != nil {
+			return nil, err
+		}
+
+		wait := backoff(attempt, err)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return nil, err
+		}
+		p.log.With(
+			slog.String("url", url),
+			slog.Int("attempt", attempt),
+			slog.Duration("wait", wait),
+		).Warn("retrying request", sl.Err(err))
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, err
+		}
+	}
+}
+
+// send makes a single request; responses with error status are returned as *APIError
+func (p *OpenAIProvider) send(ctx context.Context, url, contentType string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("making request: %w", err)
+	}
+
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", p.apiKey))
+	}
+	req.Header.Set("Content-Type", contentType)
+	for key, value := range p.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sending request: %w", err)
+	}
+	if resp.StatusCode < http.StatusBadRequest {
+		return resp, nil
+	}
+
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			p.log.Error("closing error body", sl.Err(err))
+		}
+	}(resp.Body)
+
+	var errorBody struct {
+		Error *Error `json:"error"`
+	}
+	raw, _ := io.ReadAll(resp.Body)
+	_ = json.Unmarshal(raw, &errorBody)
+
+	apiErr := newAPIError(resp.StatusCode, errorBody.Error)
+	apiErr.RetryAfter = retryAfter(resp.Header)
+	return nil, apiErr
+}
+
+func retryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// backoff returns exponential delay with jitter, or the server requested delay if it is longer
+func backoff(attempt int, err error) time.Duration {
+	wait := baseBackoff << (attempt - 1)
+	if wait > maxBackoff || wait <= 0 {
+		wait = maxBackoff
+	}
+	wait = wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > wait {
+		wait = apiErr.RetryAfter
+	}
+	return wait
+}
+
+// retryAfter reads the delay from Retry-After or, when a limit is exhausted, from x-ratelimit-reset-* headers
+func retryAfter(header http.Header) time.Duration {
+	if ms, err := strconv.Atoi(header.Get("retry-after-ms")); err == nil && ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		if at, err := http.ParseTime(value); err == nil {
+			return time.Until(at)
+		}
+	}
+
+	var wait time.Duration
+	for _, limit := range []string{"requests", "tokens"} {
+		if header.Get("x-ratelimit-remaining-"+limit) != "0" {
+			continue
+		}
+		if reset, err := time.ParseDuration(header.Get("x-ratelimit-reset-" + limit)); err == nil && reset > wait {
+			wait = reset
+		}
+	}
+	return wait
+}
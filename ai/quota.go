@@ -0,0 +1,115 @@
+package ai
+
+import (
+	"Brainy/core"
+	"Brainy/lib/sl"
+	"log/slog"
+	"time"
+)
+
+// CheckQuota returns *core.QuotaError when userId or the group chatId has less than amount of the resource
+// left; admins are exempt. It is called before every paid request with the images it asks for, or one for
+// tokens, whose consumption is known and counted only after the request is made.
+func (u *UsageTracker) CheckQuota(chatId, userId int64, resource string, amount int) error {
+	if u == nil || !u.conf.Quotas.Enabled || u.conf.IsAdmin(userId) {
+		return nil
+	}
+
+	now := time.Now()
+	if err := u.checkScope("user", u.conf.UserLimits(userId), resource, amount, now, func(since time.Time) (core.UsageTotals, error) {
+		return u.Totals(userId, since)
+	}); err != nil {
+		return err
+	}
+
+	// in private chats the chat is the user
+	if chatId == userId {
+		return nil
+	}
+	return u.checkScope("chat", u.conf.ChatLimits(chatId), resource, amount, now, func(since time.Time) (core.UsageTotals, error) {
+		return u.ChatTotals(chatId, since)
+	})
+}
+
+func (u *UsageTracker) checkScope(
+	scope string,
+	limits core.QuotaLimits,
+	resource string,
+	amount int,
+	now time.Time,
+	totals func(since time.Time) (core.UsageTotals, error),
+) error {
+	if limits == (core.QuotaLimits{}) {
+		return nil
+	}
+
+	// do not block users because the ledger is unavailable
+	day, err := totals(startOfDay(now))
+	if err != nil {
+		u.log.With(slog.String("scope", scope)).Error("reading usage for quota", sl.Err(err))
+		return nil
+	}
+	month, err := totals(startOfMonth(now))
+	if err != nil {
+		u.log.With(slog.String("scope", scope)).Error("reading usage for quota", sl.Err(err))
+		return nil
+	}
+	return quotaError(scope, limits, resource, amount, now, day, month)
+}
+
+func quotaError(scope string, limits core.QuotaLimits, resource string, amount int, now time.Time, day, month core.UsageTotals) error {
+	amount = max(amount, 1)
+	left := core.QuotaLeft{
+		DailyTokens:   remaining(limits.DailyTokens, day.Tokens()),
+		MonthlyTokens: remaining(limits.MonthlyTokens, month.Tokens()),
+		DailyImages:   remaining(limits.DailyImages, day.Images),
+		MonthlyImages: remaining(limits.MonthlyImages, month.Images),
+	}
+
+	type check struct {
+		period  string
+		limit   int
+		used    int
+		resetAt time.Time
+	}
+	nextDay := startOfDay(now).AddDate(0, 0, 1)
+	nextMonth := startOfMonth(now).AddDate(0, 1, 0)
+
+	// monthly first: when both are exhausted, the later reset is the one that matters
+	checks := []check{
+		{"monthly", limits.MonthlyTokens, month.Tokens(), nextMonth},
+		{"daily", limits.DailyTokens, day.Tokens(), nextDay},
+	}
+	if resource == core.QuotaImages {
+		checks = []check{
+			{"monthly", limits.MonthlyImages, month.Images, nextMonth},
+			{"daily", limits.DailyImages, day.Images, nextDay},
+		}
+	}
+
+	for _, c := range checks {
+		if c.limit > 0 && c.used+amount > c.limit {
+			return &core.QuotaError{
+				Scope:     scope,
+				Resource:  resource,
+				Period:    c.period,
+				Limit:     c.limit,
+				Used:      c.used,
+				Requested: amount,
+				ResetAt:   c.resetAt,
+				Left:      left,
+			}
+		}
+	}
+	return nil
+}
+
+func remaining(limit, used int) int {
+	if limit <= 0 {
+		return -1
+	}
+	if used >= limit {
+		return 0
+	}
+	return limit - used
+}
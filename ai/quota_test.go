@@ -0,0 +1,72 @@
+package ai
+
+import (
+	"Brainy/core"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQuotaError(t *testing.T) {
+	now := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	limits := core.QuotaLimits{DailyTokens: 1000, MonthlyTokens: 5000, DailyImages: 10, MonthlyImages: 20}
+
+	tests := []struct {
+		name       string
+		resource   string
+		amount     int
+		day, month core.UsageTotals
+		period     string // empty when the request fits
+	}{
+		{"tokens left", core.QuotaTokens, 1, core.UsageTotals{PromptTokens: 999}, core.UsageTotals{PromptTokens: 999}, ""},
+		{"tokens used up", core.QuotaTokens, 1, core.UsageTotals{PromptTokens: 600, CompletionTokens: 400}, core.UsageTotals{PromptTokens: 1000}, "daily"},
+		{"monthly tokens first", core.QuotaTokens, 1, core.UsageTotals{PromptTokens: 1000}, core.UsageTotals{PromptTokens: 5000}, "monthly"},
+		{"last image", core.QuotaImages, 1, core.UsageTotals{Images: 9}, core.UsageTotals{Images: 9}, ""},
+		{"exactly the rest", core.QuotaImages, 4, core.UsageTotals{Images: 6}, core.UsageTotals{Images: 6}, ""},
+		{"more than left", core.QuotaImages, 4, core.UsageTotals{Images: 9}, core.UsageTotals{Images: 9}, "daily"},
+		{"more than left this month", core.QuotaImages, 2, core.UsageTotals{Images: 0}, core.UsageTotals{Images: 19}, "monthly"},
+		{"images used up", core.QuotaImages, 1, core.UsageTotals{Images: 10}, core.UsageTotals{Images: 10}, "daily"},
+		{"zero amount counts as one", core.QuotaImages, 0, core.UsageTotals{Images: 10}, core.UsageTotals{Images: 10}, "daily"},
+		{"tokens do not limit images", core.QuotaImages, 1, core.UsageTotals{PromptTokens: 1000}, core.UsageTotals{PromptTokens: 5000}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := quotaError("user", limits, tt.resource, tt.amount, now, tt.day, tt.month)
+			if tt.period == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var quotaErr *core.QuotaError
+			if !errors.As(err, &quotaErr) {
+				t.Fatalf("error = %v, want *core.QuotaError", err)
+			}
+			if quotaErr.Period != tt.period || quotaErr.Resource != tt.resource || quotaErr.Requested != max(tt.amount, 1) {
+				t.Errorf("got %s %s requested %d, want %s %s requested %d",
+					quotaErr.Period, quotaErr.Resource, quotaErr.Requested, tt.period, tt.resource, max(tt.amount, 1))
+			}
+		})
+	}
+}
+
+func TestQuotaErrorUnlimited(t *testing.T) {
+	used := core.UsageTotals{PromptTokens: 1 << 30, Images: 1000}
+	if err := quotaError("chat", core.QuotaLimits{DailyTokens: 10}, core.QuotaImages, 10, time.Now(), used, used); err != nil {
+		t.Errorf("images without limits: %v", err)
+	}
+}
+
+func TestCheckQuotaExempt(t *testing.T) {
+	conf := &core.Config{Admins: []int64{7}}
+	conf.Quotas.Enabled = true
+	conf.Quotas.Default = core.QuotaLimits{DailyImages: 1}
+	tracker := &UsageTracker{conf: conf}
+	if err := tracker.CheckQuota(7, 7, core.QuotaImages, 4); err != nil {
+		t.Errorf("admin: %v", err)
+	}
+	var none *UsageTracker
+	if err := none.CheckQuota(1, 1, core.QuotaImages, 4); err != nil {
+		t.Errorf("without tracker: %v", err)
+	}
+}
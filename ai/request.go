@@ -1,20 +1,77 @@
 package ai
 
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
 type GPTRequest struct {
 	Model    string    `json:"model"`
 	Messages []Message `json:"messages"`
-	//Temperature float64   `json:"temperature"`
+	Tools    []Tool    `json:"tools,omitempty"`
+	// ToolChoice is "auto", "none" or "required"
+	ToolChoice string `json:"tool_choice,omitempty"`
+	Stream     bool   `json:"stream,omitempty"`
+	// StreamOptions asks the API to send token usage in the final streamed chunk
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+	// ResponseFormat constrains the answer to JSON matching a schema
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+	// Temperature, MaxTokens and MaxCompletionTokens are set by the model registry
+	Temperature         *float64 `json:"temperature,omitempty"`
+	MaxTokens           int      `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int      `json:"max_completion_tokens,omitempty"`
+}
+
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"`
+	Content    Content    `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// Tool describes a function the model may call
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+type ToolFunction struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Parameters  interface{} `json:"parameters,omitempty"` // JSON schema
+}
+
+// ToolCall is a function call requested by the model
+type ToolCall struct {
+	// Index identifies the call across streamed chunks
+	Index    int          `json:"index,omitempty"`
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON encoded
 }
 
 func NewRequest(content string, model string) *GPTRequest {
 	return &GPTRequest{
 		Model:    model,
-		Messages: []Message{{Role: "user", Content: content}},
-		//Temperature: 0.7,
+		Messages: []Message{{Role: RoleUser, Content: TextContent(content)}},
+	}
+}
+
+// NewChatRequest creates a request from a prepared sequence of role-based messages
+func NewChatRequest(messages []Message, model string) *GPTRequest {
+	return &GPTRequest{
+		Model:    model,
+		Messages: messages,
 	}
 }
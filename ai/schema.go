@@ -0,0 +1,140 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// SchemaFor builds a strict JSON schema from a struct: properties come from json tags, every
+// property is required, "desc" tags become descriptions and "enum" tags (comma separated) allowed values
+func SchemaFor(v interface{}) map[string]interface{} {
+	return schemaOf(reflect.TypeOf(v))
+}
+
+func schemaOf(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		required := []string{}
+		for _, field := range structFields(t) {
+			property := schemaOf(field.Type)
+			if desc := field.Tag.Get("desc"); desc != "" {
+				property["description"] = desc
+			}
+			if enum := enumOf(field); len(enum) > 0 {
+				property["enum"] = enum
+			}
+			properties[field.name] = property
+			required = append(required, field.name)
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	default:
+		return map[string]interface{}{"type": "string"}
+	}
+}
+
+type schemaField struct {
+	reflect.StructField
+	name string
+}
+
+// structFields returns exported fields with their JSON names
+func structFields(t reflect.Type) []schemaField {
+	var fields []schemaField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, schemaField{StructField: field, name: name})
+	}
+	return fields
+}
+
+func enumOf(field schemaField) []string {
+	tag := field.Tag.Get("enum")
+	if tag == "" {
+		return nil
+	}
+	return strings.Split(tag, ",")
+}
+
+// decodeStructured extracts a JSON object from the model answer, decodes it into out and checks that
+// all properties are present and enum values are allowed
+func decodeStructured(content string, out interface{}) error {
+	text := extractJSON(content)
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(text), &raw); err != nil {
+		return fmt.Errorf("parsing JSON: %w", err)
+	}
+	if err := json.Unmarshal([]byte(text), out); err != nil {
+		return fmt.Errorf("decoding JSON: %w", err)
+	}
+
+	t := reflect.TypeOf(out).Elem()
+	value := reflect.ValueOf(out).Elem()
+	for _, field := range structFields(t) {
+		if _, ok := raw[field.name]; !ok {
+			return fmt.Errorf("missing property %q", field.name)
+		}
+		enum := enumOf(field)
+		if len(enum) == 0 || field.Type.Kind() != reflect.String {
+			continue
+		}
+		got := value.FieldByIndex(field.Index).String()
+		if !containsString(enum, got) {
+			return fmt.Errorf("property %q has value %q, expected one of %s", field.name, got, strings.Join(enum, ", "))
+		}
+	}
+	return nil
+}
+
+// extractJSON strips markdown fences and text around the outermost JSON object
+func extractJSON(content string) string {
+	text := strings.TrimSpace(content)
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimPrefix(text, "```")
+	text = strings.TrimSuffix(text, "```")
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start >= 0 && end > start {
+		text = text[start : end+1]
+	}
+	return strings.TrimSpace(text)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
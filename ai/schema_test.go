@@ -0,0 +1,108 @@
+package ai
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type schemaSample struct {
+	Name    string   `json:"name" desc:"the name"`
+	Mood    string   `json:"mood" enum:"happy,sad"`
+	Count   int      `json:"count"`
+	Score   float64  `json:"score"`
+	Enabled bool     `json:"enabled,omitempty"`
+	Tags    []string `json:"tags"`
+	Skipped string   `json:"-"`
+	hidden  string
+	Plain   string
+}
+
+func TestSchemaFor(t *testing.T) {
+	schema := SchemaFor(&schemaSample{})
+	if schema["type"] != "object" || schema["additionalProperties"] != false {
+		t.Fatalf("schema = %v, want a closed object", schema)
+	}
+	wantRequired := []string{"name", "mood", "count", "score", "enabled", "tags", "Plain"}
+	if !reflect.DeepEqual(schema["required"], wantRequired) {
+		t.Errorf("required = %v, want %v", schema["required"], wantRequired)
+	}
+
+	properties := schema["properties"].(map[string]interface{})
+	tests := map[string]map[string]interface{}{
+		"name":    {"type": "string", "description": "the name"},
+		"mood":    {"type": "string", "enum": []string{"happy", "sad"}},
+		"count":   {"type": "integer"},
+		"score":   {"type": "number"},
+		"enabled": {"type": "boolean"},
+		"tags":    {"type": "array", "items": map[string]interface{}{"type": "string"}},
+		"Plain":   {"type": "string"},
+	}
+	for name, want := range tests {
+		if got := properties[name]; !reflect.DeepEqual(got, want) {
+			t.Errorf("property %s = %v, want %v", name, got, want)
+		}
+	}
+	if len(properties) != len(tests) {
+		t.Errorf("properties = %v, want only %d", properties, len(tests))
+	}
+}
+
+func TestDecodeStructured(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string // empty when valid
+	}{
+		{"plain", `{"wants_image": true, "image_prompt": "a cat"}`, ""},
+		{"fenced", "```json\n{\"wants_image\": false, \"image_prompt\": \"\"}\n```", ""},
+		{"surrounding text", `Sure! {"wants_image": false, "image_prompt": ""} Hope it helps.`, ""},
+		{"missing property", `{"wants_image": true}`, `missing property "image_prompt"`},
+		{"wrong type", `{"wants_image": "yes", "image_prompt": ""}`, "decoding JSON"},
+		{"not json", `I think the user wants a picture`, "parsing JSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out IntentResponse
+			err := decodeStructured(tt.content, &out)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDecodeStructuredEnum(t *testing.T) {
+	var out struct {
+		Mood string `json:"mood" enum:"happy,sad"`
+	}
+	if err := decodeStructured(`{"mood": "sad"}`, &out); err != nil || out.Mood != "sad" {
+		t.Errorf("allowed value: %v, %q", err, out.Mood)
+	}
+	if err := decodeStructured(`{"mood": "angry"}`, &out); err == nil || !strings.Contains(err.Error(), "expected one of happy, sad") {
+		t.Errorf("value outside enum: %v", err)
+	}
+}
+
+func TestResponseFormatUnsupported(t *testing.T) {
+	tests := []struct {
+		err  *APIError
+		want bool
+	}{
+		{newAPIError(400, &Error{Message: "Invalid parameter: 'response_format' of type 'json_schema' is not supported with this model."}), true},
+		{newAPIError(400, &Error{Param: "response_format", Message: "unsupported"}), true},
+		{newAPIError(400, &Error{Message: "Invalid value for 'temperature'"}), false},
+		{newAPIError(500, &Error{Message: "response_format failed", Type: "server_error"}), false},
+	}
+	for _, tt := range tests {
+		if got := responseFormatUnsupported(tt.err); got != tt.want {
+			t.Errorf("responseFormatUnsupported(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package ai
+
+import (
+	"Brainy/core"
+	"Brainy/lib/sl"
+	"Brainy/storage"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// SetSettingsStorage sets the storage of per-chat settings
+func (c *ChatGPT) SetSettingsStorage(settings storage.SettingsStorage) {
+	c.settings = settings
+}
+
+// chatSettings returns settings of the chat, defaults if none are saved
+func (c *ChatGPT) chatSettings(chatId int64) storage.ChatSettings {
+	if c.settings == nil {
+		return storage.ChatSettings{ChatId: chatId}
+	}
+	settings, err := c.settings.GetChatSettings(chatId)
+	if err != nil {
+		c.log.With(slog.Int64("user", chatId)).Error("getting chat settings", sl.Err(err))
+	}
+	if settings == nil {
+		return storage.ChatSettings{ChatId: chatId}
+	}
+	return *settings
+}
+
+// updateSettings applies the change to the chat settings and saves them
+func (c *ChatGPT) updateSettings(chatId int64, change func(settings *storage.ChatSettings)) error {
+	if c.settings == nil {
+		return fmt.Errorf("settings storage is not set")
+	}
+	settings := c.chatSettings(chatId)
+	change(&settings)
+	if err := c.settings.SaveChatSettings(&settings); err != nil {
+		return fmt.Errorf("saving chat settings: %w", err)
+	}
+	return nil
+}
+
+// VoiceReplies tells whether answers in the chat are sent as voice notes
+func (c *ChatGPT) VoiceReplies(chatId int64) bool {
+	return c.conf.Speech.Enabled && c.chatSettings(chatId).VoiceReplies
+}
+
+// SetVoiceReplies turns voice answers in the chat on or off
+func (c *ChatGPT) SetVoiceReplies(chatId int64, enabled bool) error {
+	return c.updateSettings(chatId, func(settings *storage.ChatSettings) {
+		settings.VoiceReplies = enabled
+	})
+}
+
+// ImageIntent tells whether messages in the chat are checked for image requests
+func (c *ChatGPT) ImageIntent(chatId int64) bool {
+	return c.conf.ImageIntent.Enabled && !c.chatSettings(chatId).NoImageIntent
+}
+
+// SetImageIntent turns detection of image requests in the chat on or off
+func (c *ChatGPT) SetImageIntent(chatId int64, enabled bool) error {
+	return c.updateSettings(chatId, func(settings *storage.ChatSettings) {
+		settings.NoImageIntent = !enabled
+	})
+}
+
+// ImageStyle returns the style preset of generated images in the chat
+func (c *ChatGPT) ImageStyle(chatId int64) string {
+	if style := c.chatSettings(chatId).ImageStyle; style != "" {
+		return style
+	}
+	return c.conf.Images.Style
+}
+
+// ImageStyles returns names of the configured style presets
+func (c *ChatGPT) ImageStyles() []string {
+	return c.conf.ImageStyleNames()
+}
+
+// SetImageStyle sets the style preset of generated images in the chat
+func (c *ChatGPT) SetImageStyle(chatId int64, style string) error {
+	if _, ok := c.conf.ImageStyle(style); !ok {
+		return &core.OptionError{Option: "style", Value: style, Allowed: c.conf.ImageStyleNames()}
+	}
+	return c.updateSettings(chatId, func(settings *storage.ChatSettings) {
+		settings.ImageStyle = style
+	})
+}
+
+// Models returns the models chats can choose
+func (c *ChatGPT) Models() []string {
+	return c.conf.ModelNames()
+}
+
+// ChatModel returns the model answering in the chat
+func (c *ChatGPT) ChatModel(chatId int64) string {
+	if model := c.chatSettings(chatId).Model; model != "" {
+		return model
+	}
+	return c.conf.ModelFor(core.TaskChat)
+}
+
+// SetChatModel sets the model answering in the chat, an empty name returns to the configured model
+func (c *ChatGPT) SetChatModel(chatId int64, model string) error {
+	if _, ok := c.conf.Models.Registry[model]; model != "" && !ok {
+		return &core.OptionError{Option: "model", Value: model, Allowed: c.conf.ModelNames()}
+	}
+	return c.updateSettings(chatId, func(settings *storage.ChatSettings) {
+		settings.Model = model
+	})
+}
+
+// ChatTimezone returns the timezone of the chat, the configured one if none is set
+func (c *ChatGPT) ChatTimezone(chatId int64) string {
+	if timezone := c.chatSettings(chatId).Timezone; timezone != "" {
+		return timezone
+	}
+	return c.conf.Timezone
+}
+
+// SetChatTimezone sets the timezone of the chat, an empty name returns to the configured timezone
+func (c *ChatGPT) SetChatTimezone(chatId int64, timezone string) error {
+	if timezone != "" {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return fmt.Errorf("%w %q", core.ErrUnknownTimezone, timezone)
+		}
+		timezone = location.String()
+	}
+	return c.updateSettings(chatId, func(settings *storage.ChatSettings) {
+		settings.Timezone = timezone
+	})
+}
@@ -0,0 +1,132 @@
+package ai
+
+import (
+	"Brainy/lib/sl"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// StreamProvider sends chat completion requests with server-sent events streaming
+type StreamProvider interface {
+	// CompleteStream calls onDelta with every content fragment as it arrives and
+	// returns the completion assembled from all received chunks
+	CompleteStream(ctx context.Context, request *GPTRequest, onDelta func(delta string)) (*ChatCompletion, error)
+}
+
+// CompleteStream sends a streaming chat completion request and assembles the response
+func (p *OpenAIProvider) CompleteStream(ctx context.Context, request *GPTRequest, onDelta func(delta string)) (*ChatCompletion, error) {
+	streamRequest := *p.fitModel(request)
+	streamRequest.Stream = true
+	streamRequest.StreamOptions = &StreamOptions{IncludeUsage: true}
+
+	jsonBytes, err := json.Marshal(&streamRequest)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling request: %w", err)
+	}
+
+	resp, err := p.do(ctx, p.baseURL+"/chat/completions", "application/json", jsonBytes)
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			p.log.Error("closing stream body", sl.Err(err))
+		}
+	}(resp.Body)
+
+	return readStream(resp.Body, onDelta)
+}
+
+// readStream parses "data: {...}" events until the "[DONE]" marker
+func readStream(body io.Reader, onDelta func(delta string)) (*ChatCompletion, error) {
+	chatCompletion := &ChatCompletion{Object: "chat.completion"}
+	var content strings.Builder
+	var role, finishReason string
+	var toolCalls []ToolCall
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk ChatCompletionChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("decoding stream chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			chatCompletion.Error = chunk.Error
+			return chatCompletion, nil
+		}
+
+		chatCompletion.ID = chunk.ID
+		chatCompletion.Created = chunk.Created
+		chatCompletion.Model = chunk.Model
+		if chunk.Usage != nil {
+			chatCompletion.Usage = *chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Index != 0 {
+				continue
+			}
+			if choice.Delta.Role != "" {
+				role = choice.Delta.Role
+			}
+			if choice.FinishReason != "" {
+				finishReason = choice.FinishReason
+			}
+			if delta := choice.Delta.Content.String(); delta != "" {
+				content.WriteString(delta)
+				if onDelta != nil {
+					onDelta(delta)
+				}
+			}
+			toolCalls = mergeToolCalls(toolCalls, choice.Delta.ToolCalls)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading stream: %w", err)
+	}
+
+	if role == "" {
+		role = RoleAssistant
+	}
+	if content.Len() > 0 || len(toolCalls) > 0 || finishReason != "" {
+		chatCompletion.Choices = []Choice{{
+			Message:      Message{Role: role, Content: TextContent(content.String()), ToolCalls: toolCalls},
+			FinishReason: finishReason,
+		}}
+	}
+	return chatCompletion, nil
+}
+
+// mergeToolCalls appends streamed tool call fragments; the first fragment of a call carries
+// its id and name, the following ones add pieces of the arguments
+func mergeToolCalls(calls []ToolCall, deltas []ToolCall) []ToolCall {
+	for _, delta := range deltas {
+		i := delta.Index
+		for len(calls) <= i {
+			calls = append(calls, ToolCall{Index: len(calls), Type: "function"})
+		}
+		if delta.ID != "" {
+			calls[i].ID = delta.ID
+		}
+		if delta.Type != "" {
+			calls[i].Type = delta.Type
+		}
+		calls[i].Function.Name += delta.Function.Name
+		calls[i].Function.Arguments += delta.Function.Arguments
+	}
+	return calls
+}
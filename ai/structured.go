@@ -0,0 +1,112 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ResponseFormat asks the model for a JSON answer matching a schema
+type ResponseFormat struct {
+	Type       string      `json:"type"` // "json_schema" or "json_object"
+	JSONSchema *JSONSchema `json:"json_schema,omitempty"`
+}
+
+type JSONSchema struct {
+	Name   string                 `json:"name"`
+	Schema map[string]interface{} `json:"schema"`
+	Strict bool                   `json:"strict"`
+}
+
+// completeStructured sends the request with a strict JSON schema made from out and decodes the answer
+// into out. Providers rejecting response_format get the schema in the prompt instead, and an answer
+// that does not match the schema gets one repair round. Every completion is passed to record.
+func completeStructured(
+	ctx context.Context,
+	provider CompletionProvider,
+	request *GPTRequest,
+	name string,
+	out interface{},
+	record func(completion *ChatCompletion),
+) error {
+	schema := SchemaFor(out)
+
+	structured := *request
+	structured.ResponseFormat = &ResponseFormat{
+		Type:       "json_schema",
+		JSONSchema: &JSONSchema{Name: name, Schema: schema, Strict: true},
+	}
+	chatCompletion, err := provider.Complete(ctx, &structured)
+	if err != nil && responseFormatUnsupported(err) {
+		structured.ResponseFormat = nil
+		structured.Messages = append(append([]Message{}, request.Messages...), Message{
+			Role:    RoleUser,
+			Content: TextContent(schemaInstruction(schema)),
+		})
+		chatCompletion, err = provider.Complete(ctx, &structured)
+	}
+	content, err := completionContent(chatCompletion, err, record)
+	if err != nil {
+		return err
+	}
+
+	decodeErr := decodeStructured(content, out)
+	if decodeErr == nil {
+		return nil
+	}
+
+	// repair pass: show the model its answer and what is wrong with it
+	repair := NewRequest(repairPrompt(content, schema, decodeErr), request.Model)
+	chatCompletion, err = provider.Complete(ctx, repair)
+	content, err = completionContent(chatCompletion, err, record)
+	if err != nil {
+		return fmt.Errorf("repairing structured output: %w (invalid output: %v)", err, decodeErr)
+	}
+	if err := decodeStructured(content, out); err != nil {
+		return fmt.Errorf("invalid structured output after repair: %w", err)
+	}
+	return nil
+}
+
+// completionContent returns the answer text, recording usage of successful calls
+func completionContent(chatCompletion *ChatCompletion, err error, record func(completion *ChatCompletion)) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	if record != nil {
+		record(chatCompletion)
+	}
+	if chatCompletion.Error != nil {
+		return "", newAPIError(0, chatCompletion.Error)
+	}
+	if len(chatCompletion.Choices) == 0 {
+		return "", fmt.Errorf("empty choices in response")
+	}
+	return chatCompletion.Choices[0].Message.Content.String(), nil
+}
+
+// responseFormatUnsupported detects providers that reject structured outputs
+func responseFormatUnsupported(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Retryable() {
+		return false
+	}
+	text := strings.ToLower(apiErr.Param + " " + apiErr.Message)
+	return strings.Contains(text, "response_format") || strings.Contains(text, "json_schema")
+}
+
+func schemaInstruction(schema map[string]interface{}) string {
+	encoded, _ := json.Marshal(schema)
+	return "Respond ONLY with a JSON object matching this JSON schema, no other text:\n" + string(encoded)
+}
+
+func repairPrompt(content string, schema map[string]interface{}, problem error) string {
+	return fmt.Sprintf(`The following answer must be a JSON object matching the JSON schema below, but it is invalid: %v
+
+Answer:
+%s
+
+%s`, problem, content, schemaInstruction(schema))
+}
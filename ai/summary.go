@@ -0,0 +1,107 @@
+package ai
+
+import (
+	"Brainy/core"
+	"Brainy/lib/sl"
+	"Brainy/lib/tokenizer"
+	"Brainy/storage"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// summarizeAsync condenses the oldest turns into the dialog summary once the context passes the
+// configured threshold, before the storage token limit starts dropping them
+func (c *ChatGPT) summarizeAsync(chatId, userId int64) {
+	if !c.conf.Summary.Enabled {
+		return
+	}
+	dialogContext := c.contextManager.GetUserContext(chatId)
+	if dialogContext == nil || dialogContext.Tokens <= c.conf.Summary.Threshold {
+		return
+	}
+	if _, running := c.summarizing.LoadOrStore(chatId, true); running {
+		return
+	}
+
+	go func() {
+		defer c.summarizing.Delete(chatId)
+		if err := c.summarize(chatId, userId, dialogContext); err != nil {
+			c.log.With(slog.Int64("user", chatId)).Error("summarizing context", sl.Err(err))
+		}
+	}()
+}
+
+func (c *ChatGPT) summarize(chatId, userId int64, dialogContext *storage.DialogContext) error {
+	// recent turns up to Keep tokens stay verbatim
+	split := len(dialogContext.Messages)
+	kept := 0
+	for split > 0 && kept+dialogContext.Messages[split-1].Tokens <= c.conf.Summary.Keep {
+		split--
+		kept += dialogContext.Messages[split].Tokens
+	}
+	if split == 0 {
+		return nil
+	}
+	older := dialogContext.Messages[:split]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	defer cancel()
+
+	request := NewRequest(buildSummaryPrompt(dialogContext.Summary, older), c.conf.ModelFor(core.TaskSummary))
+	chatCompletion, err := c.provider.Complete(ctx, request)
+	if err != nil {
+		return err
+	}
+	c.usage.RecordCompletion(chatId, userId, storage.UsageSummarize, request.Model, chatCompletion)
+	if chatCompletion.Error != nil {
+		return newAPIError(0, chatCompletion.Error)
+	}
+	if len(chatCompletion.Choices) == 0 {
+		return fmt.Errorf("empty choices in response")
+	}
+	summary := strings.TrimSpace(chatCompletion.Choices[0].Message.Content.String())
+	if summary == "" {
+		return fmt.Errorf("empty summary")
+	}
+
+	c.contextManager.SetSummary(chatId, dialogContext.Generation, summary, older[len(older)-1].Timestamp)
+	c.log.With(
+		slog.Int64("user", chatId),
+		slog.String("model", chatCompletion.Model),
+		slog.Int("messages", len(older)),
+		slog.Int("summary_tokens", tokenizer.ForModel(request.Model).Count(summary)),
+	).Info("context summarized")
+	return nil
+}
+
+func buildSummaryPrompt(previous string, messages []storage.Message) string {
+	var dialog strings.Builder
+	for _, message := range messages {
+		if message.IsUser {
+			dialog.WriteString("User: ")
+		} else {
+			dialog.WriteString("Assistant: ")
+		}
+		if message.Image != "" {
+			dialog.WriteString("[picture] ")
+		}
+		dialog.WriteString(message.Text)
+		dialog.WriteString("\n")
+	}
+	if previous == "" {
+		previous = "(none)"
+	}
+
+	return fmt.Sprintf(`Update the summary of a conversation between a user and an assistant with the new turns below.
+Keep facts, names, decisions, open questions and user requests that may matter later; drop small talk.
+Write in the language of the conversation, at most 200 words, and respond ONLY with the summary text.
+
+Current summary:
+%s
+
+New turns:
+%s`, previous, dialog.String())
+}
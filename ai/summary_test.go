@@ -0,0 +1,102 @@
+package ai
+
+import (
+	"Brainy/core"
+	"Brainy/holder"
+	"Brainy/storage"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSummaryChat returns a chat whose summary calls wait for release before answering
+func newSummaryChat(t *testing.T) (c *ChatGPT, started <-chan struct{}, release chan<- struct{}) {
+	startedCh, releaseCh := make(chan struct{}, 1), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startedCh <- struct{}{}
+		<-releaseCh
+		w.Write([]byte(`{"model":"gpt-5-nano","choices":[{"message":{"role":"assistant","content":"they talked about cats"}}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	conf := &core.Config{Model: "gpt-5-nano"}
+	conf.Provider.BaseURL = server.URL
+	conf.Provider.MaxAttempts = 1
+	conf.Summary.Enabled, conf.Summary.Threshold, conf.Summary.Keep = true, 20, 5
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	count := func(text string) int { return len(strings.Fields(text)) }
+	c = NewChat(conf, log, storage.NewMemoryStorage(count, 1000), NewOpenAIProvider(conf, log))
+	for i := 0; i < 6; i++ {
+		c.contextManager.UpdateUserContext(1, holder.Message{Text: "tell me more about cats please", IsUser: i%2 == 0})
+	}
+	return c, startedCh, releaseCh
+}
+
+func waitSummarized(t *testing.T, c *ChatGPT, chatId int64) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, running := c.summarizing.Load(chatId); !running {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("summarization did not finish")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSummarizeWhileChatting(t *testing.T) {
+	c, started, release := newSummaryChat(t)
+	c.summarizeAsync(1, 1)
+	<-started
+	// turns added meanwhile stay after the summary
+	c.contextManager.UpdateUserContext(1, holder.Message{Text: "and dogs?", IsUser: true})
+	close(release)
+	waitSummarized(t, c, 1)
+
+	dialog := c.contextManager.GetUserContext(1)
+	if dialog.Summary != "they talked about cats" {
+		t.Errorf("summary = %q", dialog.Summary)
+	}
+	if last := dialog.Messages[len(dialog.Messages)-1]; last.Text != "and dogs?" {
+		t.Errorf("last message = %q, want the one added while summarizing", last.Text)
+	}
+	if len(dialog.Messages) >= 7 {
+		t.Errorf("%d messages left, older ones should be summarized", len(dialog.Messages))
+	}
+}
+
+func TestSummarizeAfterClear(t *testing.T) {
+	c, started, release := newSummaryChat(t)
+	c.summarizeAsync(1, 1)
+	<-started
+	c.ClearContext(1)
+	c.contextManager.UpdateUserContext(1, holder.Message{Text: "a new topic", IsUser: true})
+	close(release)
+	waitSummarized(t, c, 1)
+
+	dialog := c.contextManager.GetUserContext(1)
+	if dialog.Summary != "" {
+		t.Errorf("summary of the cleared dialog came back: %q", dialog.Summary)
+	}
+	if len(dialog.Messages) != 1 || dialog.Messages[0].Text != "a new topic" {
+		t.Errorf("messages = %+v, want only the new one", dialog.Messages)
+	}
+}
+
+func TestSummarizeAfterClearWithoutNewTurns(t *testing.T) {
+	c, started, release := newSummaryChat(t)
+	c.summarizeAsync(1, 1)
+	<-started
+	c.ClearContext(1)
+	close(release)
+	waitSummarized(t, c, 1)
+
+	if dialog := c.contextManager.GetUserContext(1); dialog != nil {
+		t.Errorf("cleared context came back: %+v", dialog)
+	}
+}
@@ -0,0 +1,107 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// ToolEnv tells a tool who called it and where
+type ToolEnv struct {
+	ChatId   int64
+	UserId   int64
+	Timezone string // IANA timezone of the chat, empty for the default
+}
+
+// ToolHandler executes a tool with JSON arguments from the model and returns the result for the model
+type ToolHandler func(ctx context.Context, env ToolEnv, arguments json.RawMessage) (interface{}, error)
+
+type registeredTool struct {
+	definition Tool
+	handler    ToolHandler
+}
+
+// ToolRegistry holds Go functions the model can call
+type ToolRegistry struct {
+	tools map[string]registeredTool
+	order []string
+	mutex sync.RWMutex
+}
+
+// NewToolRegistry creates an empty tool registry
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{
+		tools: make(map[string]registeredTool),
+	}
+}
+
+// Register adds a tool; parameters is the JSON schema of the arguments object.
+// Registering a name again replaces the tool.
+func (r *ToolRegistry) Register(name, description string, parameters map[string]interface{}, handler ToolHandler) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.tools[name]; !exists {
+		r.order = append(r.order, name)
+	}
+	r.tools[name] = registeredTool{
+		definition: Tool{
+			Type: "function",
+			Function: ToolFunction{
+				Name:        name,
+				Description: description,
+				Parameters:  parameters,
+			},
+		},
+		handler: handler,
+	}
+}
+
+// Definitions returns tools in registration order for a request
+func (r *ToolRegistry) Definitions() []Tool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	tools := make([]Tool, 0, len(r.order))
+	for _, name := range r.order {
+		tools = append(tools, r.tools[name].definition)
+	}
+	return tools
+}
+
+// Execute runs a tool call and returns JSON for the tool message; failures are reported
+// to the model as {"error": "..."} so it can correct itself
+func (r *ToolRegistry) Execute(ctx context.Context, env ToolEnv, call ToolCall) (string, error) {
+	r.mutex.RLock()
+	tool, ok := r.tools[call.Function.Name]
+	r.mutex.RUnlock()
+
+	if !ok {
+		err := fmt.Errorf("unknown tool %q", call.Function.Name)
+		return toolError(err), err
+	}
+
+	arguments := json.RawMessage(call.Function.Arguments)
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+	result, err := tool.handler(ctx, env, arguments)
+	if err != nil {
+		return toolError(err), err
+	}
+
+	if text, ok := result.(string); ok {
+		return text, nil
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return toolError(err), err
+	}
+	return string(encoded), nil
+}
+
+func toolError(err error) string {
+	encoded, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return string(encoded)
+}
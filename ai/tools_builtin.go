@@ -0,0 +1,112 @@
+package ai
+
+import (
+	"Brainy/lib/calc"
+	"Brainy/lib/units"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// RegisterBuiltinTools adds tools that work offline: current time, calculator and unit conversion;
+// the time is told in the timezone of the chat from the tool env, defaultTimezone if it has none
+func RegisterBuiltinTools(registry *ToolRegistry, defaultTimezone string) {
+	registry.Register(
+		"get_current_time",
+		"Get the current date and time. Use it for any question about today, now, dates or time.",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"timezone": map[string]interface{}{
+					"type":        "string",
+					"description": "IANA timezone like Europe/Kyiv; omit to use the timezone of the chat",
+				},
+			},
+		},
+		func(ctx context.Context, env ToolEnv, arguments json.RawMessage) (interface{}, error) {
+			var args struct {
+				Timezone string `json:"timezone"`
+			}
+			if err := json.Unmarshal(arguments, &args); err != nil {
+				return nil, fmt.Errorf("invalid arguments: %w", err)
+			}
+			if args.Timezone == "" {
+				args.Timezone = env.Timezone
+			}
+			if args.Timezone == "" {
+				args.Timezone = defaultTimezone
+			}
+			location, err := time.LoadLocation(args.Timezone)
+			if err != nil {
+				return nil, fmt.Errorf("unknown timezone %q", args.Timezone)
+			}
+			now := time.Now().In(location)
+			return map[string]string{
+				"datetime": now.Format(time.RFC3339),
+				"date":     now.Format("2006-01-02"),
+				"time":     now.Format("15:04:05"),
+				"weekday":  now.Weekday().String(),
+				"timezone": location.String(),
+			}, nil
+		},
+	)
+
+	registry.Register(
+		"calculate",
+		"Evaluate an arithmetic expression exactly. Supports + - * / % ^, parentheses, pi, e and "+
+			"functions sqrt, abs, sin, cos, tan, asin, acos, atan, ln, log, log2, exp, floor, ceil, round, pow, min, max.",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"expression": map[string]interface{}{
+					"type":        "string",
+					"description": "expression to evaluate, e.g. (2+3)^2 / sqrt(16)",
+				},
+			},
+			"required": []string{"expression"},
+		},
+		func(ctx context.Context, env ToolEnv, arguments json.RawMessage) (interface{}, error) {
+			var args struct {
+				Expression string `json:"expression"`
+			}
+			if err := json.Unmarshal(arguments, &args); err != nil {
+				return nil, fmt.Errorf("invalid arguments: %w", err)
+			}
+			value, err := calc.Eval(args.Expression)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{"expression": args.Expression, "result": value}, nil
+		},
+	)
+
+	registry.Register(
+		"convert_units",
+		"Convert a value between units of length, mass, volume, time, area, speed, data size or temperature.",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"value": map[string]interface{}{"type": "number"},
+				"from":  map[string]interface{}{"type": "string", "description": "source unit, e.g. km, lb, °F, gal"},
+				"to":    map[string]interface{}{"type": "string", "description": "target unit"},
+			},
+			"required": []string{"value", "from", "to"},
+		},
+		func(ctx context.Context, env ToolEnv, arguments json.RawMessage) (interface{}, error) {
+			var args struct {
+				Value float64 `json:"value"`
+				From  string  `json:"from"`
+				To    string  `json:"to"`
+			}
+			if err := json.Unmarshal(arguments, &args); err != nil {
+				return nil, fmt.Errorf("invalid arguments: %w", err)
+			}
+			result, err := units.Convert(args.Value, args.From, args.To)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{"value": args.Value, "from": args.From, "to": args.To, "result": result}, nil
+		},
+	)
+}
@@ -0,0 +1,309 @@
+package ai
+
+import (
+	"Brainy/core"
+	"Brainy/storage"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"strings"
+	"testing"
+)
+
+func toolCall(name, arguments string) ToolCall {
+	return ToolCall{ID: "call_" + name, Type: "function", Function: FunctionCall{Name: name, Arguments: arguments}}
+}
+
+func TestToolRegistryExecute(t *testing.T) {
+	registry := NewToolRegistry()
+	registry.Register("echo", "returns the text", nil, func(ctx context.Context, env ToolEnv, arguments json.RawMessage) (interface{}, error) {
+		var args struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(arguments, &args); err != nil {
+			return nil, err
+		}
+		return args.Text, nil
+	})
+	registry.Register("fail", "always fails", nil, func(ctx context.Context, env ToolEnv, arguments json.RawMessage) (interface{}, error) {
+		return nil, errors.New("out of order")
+	})
+	registry.Register("channel", "returns what JSON can't encode", nil, func(ctx context.Context, env ToolEnv, arguments json.RawMessage) (interface{}, error) {
+		return make(chan int), nil
+	})
+	registry.Register("env", "returns the caller", nil, func(ctx context.Context, env ToolEnv, arguments json.RawMessage) (interface{}, error) {
+		return env, nil
+	})
+
+	tests := []struct {
+		name      string
+		call      ToolCall
+		want      string // result for the model
+		failed    bool
+		errorPart string
+	}{
+		{"text result", toolCall("echo", `{"text":"hi"}`), "hi", false, ""},
+		{"missing arguments are an empty object", toolCall("echo", ""), "", false, ""},
+		{"structured result", toolCall("env", "{}"), `{"ChatId":1,"UserId":2,"Timezone":"Europe/Kyiv"}`, false, ""},
+		{"invalid arguments", toolCall("echo", `{"text":`), "", true, "unexpected end of JSON input"},
+		{"wrong argument type", toolCall("echo", `{"text":5}`), "", true, "cannot unmarshal number"},
+		{"unknown tool", toolCall("missing", "{}"), "", true, `unknown tool "missing"`},
+		{"handler error", toolCall("fail", "{}"), "", true, "out of order"},
+		{"result not encodable", toolCall("channel", "{}"), "", true, "unsupported type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := registry.Execute(context.Background(), ToolEnv{ChatId: 1, UserId: 2, Timezone: "Europe/Kyiv"}, tt.call)
+			if !tt.failed {
+				if err != nil || result != tt.want {
+					t.Errorf("Execute() = %q, %v, want %q", result, err, tt.want)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Execute() = %q, want an error", result)
+			}
+			// the model gets the error as JSON to correct itself
+			var reported map[string]string
+			if json.Unmarshal([]byte(result), &reported) != nil || !strings.Contains(reported["error"], tt.errorPart) {
+				t.Errorf("Execute() = %q, want an error with %q", result, tt.errorPart)
+			}
+		})
+	}
+}
+
+func TestToolRegistryDefinitions(t *testing.T) {
+	registry := NewToolRegistry()
+	handler := func(ctx context.Context, env ToolEnv, arguments json.RawMessage) (interface{}, error) {
+		return nil, nil
+	}
+	registry.Register("b", "first", nil, handler)
+	registry.Register("a", "second", nil, handler)
+	registry.Register("b", "replaced", nil, handler)
+
+	tools := registry.Definitions()
+	if len(tools) != 2 || tools[0].Function.Name != "b" || tools[1].Function.Name != "a" {
+		t.Fatalf("Definitions() = %+v, want b and a in registration order", tools)
+	}
+	if tools[0].Function.Description != "replaced" {
+		t.Errorf("description = %q, a registered name is replaced in place", tools[0].Function.Description)
+	}
+}
+
+// executeBuiltin runs a builtin tool and returns its decoded result, or the error reported to the model
+func executeBuiltin(t *testing.T, env ToolEnv, name, arguments string) (map[string]interface{}, string) {
+	t.Helper()
+	registry := NewToolRegistry()
+	RegisterBuiltinTools(registry, "UTC")
+	result, err := registry.Execute(context.Background(), env, toolCall(name, arguments))
+	var decoded map[string]interface{}
+	if jsonErr := json.Unmarshal([]byte(result), &decoded); jsonErr != nil {
+		t.Fatalf("result %q is not JSON: %v", result, jsonErr)
+	}
+	if err != nil {
+		return nil, decoded["error"].(string)
+	}
+	return decoded, ""
+}
+
+func TestCalculateTool(t *testing.T) {
+	tests := []struct {
+		arguments string
+		want      float64
+		err       string
+	}{
+		{`{"expression":"2+3*4"}`, 14, ""},
+		{`{"expression":"(2+3)^2 / sqrt(16)"}`, 6.25, ""},
+		{`{"expression":"max(1, 7, 3) % 4"}`, 3, ""},
+		{`{"expression":"2 * pi"}`, 2 * math.Pi, ""},
+		{`{"expression":"1/0"}`, 0, "division by zero"},
+		{`{"expression":"exp(1000)"}`, 0, "not a finite number"},
+		{`{"expression":"2 +"}`, 0, "unexpected"},
+		{`{"expression":"bogus(1)"}`, 0, "bogus"},
+		{`{}`, 0, "unexpected"},
+		{`{"expression":12}`, 0, "cannot unmarshal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arguments, func(t *testing.T) {
+			result, reported := executeBuiltin(t, ToolEnv{}, "calculate", tt.arguments)
+			if tt.err != "" {
+				if !strings.Contains(reported, tt.err) {
+					t.Errorf("error = %q, want one with %q", reported, tt.err)
+				}
+				return
+			}
+			if reported != "" {
+				t.Fatalf("error = %q", reported)
+			}
+			if got, _ := result["result"].(float64); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("result = %v, want %v", result["result"], tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertUnitsTool(t *testing.T) {
+	tests := []struct {
+		arguments string
+		want      float64
+		err       string
+	}{
+		{`{"value":1,"from":"km","to":"m"}`, 1000, ""},
+		{`{"value":10,"from":"lb","to":"kg"}`, 4.5359237, ""},
+		{`{"value":100,"from":"°C","to":"F"}`, 212, ""},
+		{`{"value":0,"from":"K","to":"celsius"}`, -273.15, ""},
+		{`{"value":1,"from":"parsec","to":"m"}`, 0, `unknown unit "parsec"`},
+		{`{"value":1,"from":"kg","to":"m"}`, 0, "cannot convert mass to length"},
+		{`{"value":"one","from":"km","to":"m"}`, 0, "cannot unmarshal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arguments, func(t *testing.T) {
+			result, reported := executeBuiltin(t, ToolEnv{}, "convert_units", tt.arguments)
+			if tt.err != "" {
+				if !strings.Contains(reported, tt.err) {
+					t.Errorf("error = %q, want one with %q", reported, tt.err)
+				}
+				return
+			}
+			if reported != "" {
+				t.Fatalf("error = %q", reported)
+			}
+			if got, _ := result["result"].(float64); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("result = %v, want %v", result["result"], tt.want)
+			}
+		})
+	}
+}
+
+func TestCurrentTimeTool(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       ToolEnv
+		arguments string
+		want      string
+		err       string
+	}{
+		{"default timezone", ToolEnv{}, "{}", "UTC", ""},
+		{"timezone of the chat", ToolEnv{Timezone: "Europe/Kyiv"}, "{}", "Europe/Kyiv", ""},
+		{"asked timezone", ToolEnv{Timezone: "Europe/Kyiv"}, `{"timezone":"Asia/Tokyo"}`, "Asia/Tokyo", ""},
+		{"unknown timezone", ToolEnv{}, `{"timezone":"Mars/Olympus"}`, "", `unknown timezone "Mars/Olympus"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, reported := executeBuiltin(t, tt.env, "get_current_time", tt.arguments)
+			if tt.err != "" {
+				if reported != tt.err {
+					t.Errorf("error = %q, want %q", reported, tt.err)
+				}
+				return
+			}
+			if reported != "" || result["timezone"] != tt.want {
+				t.Errorf("timezone = %v (%q), want %s", result["timezone"], reported, tt.want)
+			}
+		})
+	}
+}
+
+// scriptedProvider asks for the time in the first round and for a calculation in the next ones,
+// until tools are switched off
+type scriptedProvider struct {
+	Provider
+	requests []GPTRequest // copies of the requests as they were sent
+}
+
+func (p *scriptedProvider) Complete(ctx context.Context, request *GPTRequest) (*ChatCompletion, error) {
+	sent := *request
+	sent.Messages = append([]Message(nil), request.Messages...)
+	p.requests = append(p.requests, sent)
+
+	message := Message{Role: RoleAssistant}
+	switch {
+	case request.ToolChoice == "none":
+		message.Content = TextContent("done")
+	case len(p.requests) == 1:
+		message.ToolCalls = []ToolCall{toolCall("get_current_time", "{}")}
+	default:
+		message.ToolCalls = []ToolCall{toolCall("calculate", `{"expression":"6*7"}`)}
+	}
+	return &ChatCompletion{Model: request.Model, Choices: []Choice{{Message: message}}}, nil
+}
+
+func TestCompleteToolRounds(t *testing.T) {
+	conf := &core.Config{Model: "gpt-5-nano", Timezone: "UTC"}
+	conf.Tools.Enabled, conf.Tools.MaxRounds = true, 2
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	provider := &scriptedProvider{}
+	c := NewChat(conf, log, storage.NewMemoryStorage(func(text string) int { return len(text) }, 1000), provider)
+	c.SetSettingsStorage(storage.NewMemorySettingsStorage())
+	if err := c.SetChatTimezone(1, "America/New_York"); err != nil {
+		t.Fatal(err)
+	}
+
+	completion, err := c.complete(context.Background(), 1, 2, NewRequest("what time is it?", "gpt-5-nano"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if answer := completion.Choices[0].Message.Content.String(); answer != "done" {
+		t.Errorf("answer = %q, want done", answer)
+	}
+	// two rounds with tools, then the model must answer
+	if len(provider.requests) != 3 {
+		t.Fatalf("%d requests, want 3", len(provider.requests))
+	}
+	for i, request := range provider.requests {
+		if wantChoice := map[bool]string{true: "none"}[i == 2]; request.ToolChoice != wantChoice {
+			t.Errorf("request %d tool choice = %q, want %q", i+1, request.ToolChoice, wantChoice)
+		}
+	}
+
+	// every call is answered by a tool message after the assistant message asking for it
+	last := provider.requests[2].Messages
+	var results []Message
+	for i, message := range last {
+		if message.Role != RoleTool {
+			continue
+		}
+		if previous := last[i-1]; previous.Role != RoleAssistant || previous.ToolCalls[0].ID != message.ToolCallID {
+			t.Errorf("tool message %d does not follow its call", i)
+		}
+		results = append(results, message)
+	}
+	if len(results) != 2 {
+		t.Fatalf("%d tool messages, want 2", len(results))
+	}
+	var now map[string]string
+	if err := json.Unmarshal([]byte(results[0].Content.String()), &now); err != nil || now["timezone"] != "America/New_York" {
+		t.Errorf("time result = %s, want it in the timezone of the chat", results[0].Content.String())
+	}
+	if got := results[1].Content.String(); got != `{"expression":"6*7","result":42}` {
+		t.Errorf("calculation result = %s", got)
+	}
+}
+
+func TestSetChatTimezone(t *testing.T) {
+	conf := &core.Config{Timezone: "Europe/Kyiv"}
+	c := &ChatGPT{conf: conf, log: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	c.SetSettingsStorage(storage.NewMemorySettingsStorage())
+
+	if err := c.SetChatTimezone(1, "Mars/Olympus"); !errors.Is(err, core.ErrUnknownTimezone) {
+		t.Errorf("SetChatTimezone(Mars/Olympus) = %v, want ErrUnknownTimezone", err)
+	}
+	if err := c.SetChatTimezone(1, "Asia/Tokyo"); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.ChatTimezone(1); got != "Asia/Tokyo" {
+		t.Errorf("ChatTimezone() = %q, want Asia/Tokyo", got)
+	}
+	if got := c.ChatTimezone(2); got != "Europe/Kyiv" {
+		t.Errorf("ChatTimezone() of another chat = %q, want the configured one", got)
+	}
+	if err := c.SetChatTimezone(1, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.ChatTimezone(1); got != "Europe/Kyiv" {
+		t.Errorf("ChatTimezone() after reset = %q, want the configured one", got)
+	}
+}
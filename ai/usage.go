@@ -0,0 +1,146 @@
+package ai
+
+import (
+	"Brainy/core"
+	"Brainy/lib/sl"
+	"Brainy/storage"
+	"log/slog"
+	"time"
+)
+
+// UsageTracker records token and image consumption of API calls in the usage ledger
+type UsageTracker struct {
+	conf    *core.Config
+	log     *slog.Logger
+	storage storage.UsageStorage
+}
+
+// NewUsageTracker creates a new usage tracker
+func NewUsageTracker(conf *core.Config, log *slog.Logger, store storage.UsageStorage) *UsageTracker {
+	return &UsageTracker{
+		conf:    conf,
+		log:     log.With(sl.Module("usage")),
+		storage: store,
+	}
+}
+
+// RecordCompletion records usage of a chat completion made by userId in chatId; model is the
+// requested model, used when the response does not name the model that served it
+func (u *UsageTracker) RecordCompletion(chatId, userId int64, callType, model string, completion *ChatCompletion) {
+	if u == nil || completion == nil {
+		return
+	}
+	if completion.Model != "" {
+		model = completion.Model
+	}
+	u.record(&storage.UsageRecord{
+		UserId:           userId,
+		ChatId:           chatId,
+		Model:            model,
+		CallType:         callType,
+		PromptTokens:     completion.Usage.PromptTokens,
+		CompletionTokens: completion.Usage.CompletionTokens,
+	})
+}
+
+// RecordImages records images generated by userId in chatId
+func (u *UsageTracker) RecordImages(chatId, userId int64, model string, images int) {
+	if u == nil || images == 0 {
+		return
+	}
+	u.record(&storage.UsageRecord{
+		UserId:   userId,
+		ChatId:   chatId,
+		Model:    model,
+		CallType: storage.UsageImage,
+		Images:   images,
+	})
+}
+
+// RecordEmbedding records tokens embedded for the long-term memory of chatId
+func (u *UsageTracker) RecordEmbedding(chatId, userId int64, model string, tokens int) {
+	if u == nil || tokens == 0 {
+		return
+	}
+	u.record(&storage.UsageRecord{
+		UserId:       userId,
+		ChatId:       chatId,
+		Model:        model,
+		CallType:     storage.UsageEmbedding,
+		PromptTokens: tokens,
+	})
+}
+
+// RecordAudio records a transcription or speech call; every call is recorded, so requests are counted
+// even when the endpoint bills by duration and reports no tokens
+func (u *UsageTracker) RecordAudio(chatId, userId int64, callType, model string, inputTokens, outputTokens int) {
+	if u == nil {
+		return
+	}
+	u.record(&storage.UsageRecord{
+		UserId:           userId,
+		ChatId:           chatId,
+		Model:            model,
+		CallType:         callType,
+		PromptTokens:     inputTokens,
+		CompletionTokens: outputTokens,
+	})
+}
+
+func (u *UsageTracker) record(record *storage.UsageRecord) {
+	if err := u.storage.RecordUsage(record); err != nil {
+		u.log.With(
+			slog.Int64("user", record.UserId),
+			slog.String("call", record.CallType),
+		).Error("recording usage", sl.Err(err))
+	}
+}
+
+// Totals returns consumption and cost of a user in all chats since the given time
+func (u *UsageTracker) Totals(userId int64, since time.Time) (core.UsageTotals, error) {
+	if u == nil {
+		return core.UsageTotals{}, nil
+	}
+	summary, err := u.storage.GetUsageSummary(userId, since)
+	if err != nil {
+		return core.UsageTotals{}, err
+	}
+	return u.totals(summary), nil
+}
+
+// ChatTotals returns consumption and cost of all users in a chat since the given time
+func (u *UsageTracker) ChatTotals(chatId int64, since time.Time) (core.UsageTotals, error) {
+	if u == nil {
+		return core.UsageTotals{}, nil
+	}
+	summary, err := u.storage.GetChatUsageSummary(chatId, since)
+	if err != nil {
+		return core.UsageTotals{}, err
+	}
+	return u.totals(summary), nil
+}
+
+func (u *UsageTracker) totals(summary []storage.UsageSummary) core.UsageTotals {
+	var totals core.UsageTotals
+	for _, s := range summary {
+		totals.PromptTokens += s.PromptTokens
+		totals.CompletionTokens += s.CompletionTokens
+		totals.Images += s.Images
+		totals.Requests += s.Requests
+		if price, ok := u.conf.PriceFor(s.Model); ok {
+			totals.Cost += float64(s.PromptTokens)*price.Prompt/1e6 +
+				float64(s.CompletionTokens)*price.Completion/1e6 +
+				float64(s.Images)*price.Image
+		}
+	}
+	return totals
+}
+
+// startOfDay and startOfMonth return period boundaries in server local time
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func startOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,94 @@
+package ai
+
+import (
+	"Brainy/core"
+	"Brainy/holder"
+	"Brainy/lib/sl"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	// maxContextImages is how many of the latest pictures in the context are sent to the model,
+	// older ones are replaced by a text marker
+	maxContextImages     = 3
+	defaultPhotoQuestion = "What is in this picture?"
+)
+
+// SetFileLoader sets the loader of pictures sent to the bot
+func (c *ChatGPT) SetFileLoader(files core.FileLoader) {
+	c.files = files
+}
+
+// GetPhotoResponse answers the caption as a question about the picture; the picture is kept
+// in the dialog context, so follow-up questions can refer to it
+func (c *ChatGPT) GetPhotoResponse(chatId, userId int64, fileId, caption string) (string, error) {
+	if err := c.usage.CheckQuota(chatId, userId, core.QuotaTokens, 1); err != nil {
+		return "", err
+	}
+	if err := c.moderate(chatId, userId, core.ModerationPrompt, caption); err != nil {
+		return "", err
+	}
+	if caption == "" {
+		caption = defaultPhotoQuestion
+	}
+
+	image, err := c.loadImage(fileId)
+	if err != nil {
+		return "", err
+	}
+
+	if c.prefsAnalyzer != nil {
+		c.prefsAnalyzer.UpdateLastMessageTime(chatId)
+	}
+	c.contextManager.UpdateUserContext(chatId, holder.Message{
+		Text:   caption,
+		IsUser: true,
+		Image:  fileId,
+	})
+
+	messages := c.getContext(chatId)
+	// the picture is normally the last stored turn; add it if storage failed to return it
+	last := len(messages) - 1
+	if last < 0 || messages[last].Content.Images() == 0 {
+		messages = append(messages, Message{Role: RoleUser, Content: ImageContent(caption, image)})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	defer cancel()
+
+	model := c.modelFor(chatId, core.TaskChat, messages)
+	request := NewChatRequest(c.fitHistory(chatId, model, messages), model)
+	chatCompletion, err := c.complete(ctx, chatId, userId, request, nil)
+	if err != nil {
+		return "", fmt.Errorf("getting photo response: %w", err)
+	}
+	return c.handleCompletion(chatId, userId, chatCompletion)
+}
+
+// loadImage downloads a picture and returns it as a data URL, Telegram file links contain
+// the bot token and must not be passed to the model
+func (c *ChatGPT) loadImage(fileId string) (string, error) {
+	data, err := c.downloadFile(fileId)
+	if err != nil {
+		return "", err
+	}
+	return DataURL(data), nil
+}
+
+// contextContent makes the content of a stored turn; attach tells whether its picture is sent to the model
+func (c *ChatGPT) contextContent(userId int64, message holder.Message, attach bool) Content {
+	if message.Image == "" {
+		return TextContent(message.Text)
+	}
+	if attach {
+		image, err := c.loadImage(message.Image)
+		if err == nil {
+			return ImageContent(message.Text, image)
+		}
+		c.log.With(slog.Int64("user", userId)).Warn("loading context picture", sl.Err(err))
+	}
+	return TextContent("[picture] " + message.Text)
+}
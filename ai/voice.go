@@ -0,0 +1,110 @@
+package ai
+
+import (
+	"Brainy/core"
+	"Brainy/lib/tokenizer"
+	"Brainy/storage"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// maxSpeechInput is the longest text the speech endpoint accepts, in characters
+const maxSpeechInput = 4096
+
+var markdownMarkup = strings.NewReplacer("**", "", "__", "", "```", "", "`", "", "#", "", "*", "", "_", " ")
+
+// Transcribe converts a voice note or audio file sent to the bot to text
+func (c *ChatGPT) Transcribe(chatId, userId int64, fileId, fileName string) (string, error) {
+	if !c.conf.Transcription.Enabled {
+		return "", fmt.Errorf("transcription is disabled")
+	}
+	if err := c.usage.CheckQuota(chatId, userId, core.QuotaTokens, 1); err != nil {
+		return "", err
+	}
+	audio, err := c.downloadFile(fileId)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	defer cancel()
+
+	transcription, err := c.provider.Transcribe(ctx, &TranscriptionRequest{
+		Model:    c.conf.Transcription.Model,
+		FileName: fileName,
+		Audio:    audio,
+		Language: c.conf.Transcription.Language,
+	})
+	if err != nil {
+		return "", fmt.Errorf("transcribing audio: %w", err)
+	}
+	if transcription.Error != nil {
+		return "", fmt.Errorf("transcription: %w", newAPIError(0, transcription.Error))
+	}
+
+	text := strings.TrimSpace(transcription.Text)
+	inputTokens, outputTokens := 0, 0
+	if usage := transcription.Usage; usage != nil && usage.Type == "tokens" {
+		inputTokens, outputTokens = usage.InputTokens, usage.OutputTokens
+	} else {
+		// billed by duration: the transcript still counts against token quotas
+		outputTokens = tokenizer.ForModel(c.conf.Transcription.Model).Count(text)
+	}
+	c.usage.RecordAudio(chatId, userId, storage.UsageTranscription, c.conf.Transcription.Model, inputTokens, outputTokens)
+	c.log.With(
+		slog.Int64("user", chatId),
+		slog.Int("bytes", len(audio)),
+		slog.Int("chars", len(text)),
+	).Info("audio transcribed")
+	return text, nil
+}
+
+// Synthesize converts an answer to speech in the configured voice and format
+func (c *ChatGPT) Synthesize(chatId, userId int64, text string) ([]byte, error) {
+	if !c.conf.Speech.Enabled {
+		return nil, fmt.Errorf("speech is disabled")
+	}
+	if err := c.usage.CheckQuota(chatId, userId, core.QuotaTokens, 1); err != nil {
+		return nil, err
+	}
+	input := speakable(text)
+	if input == "" {
+		return nil, fmt.Errorf("nothing to say")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	defer cancel()
+
+	audio, err := c.provider.Speak(ctx, &SpeechRequest{
+		Model:          c.conf.Speech.Model,
+		Input:          input,
+		Voice:          c.conf.Speech.Voice,
+		ResponseFormat: c.conf.Speech.Format,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("synthesizing speech: %w", err)
+	}
+	// speech is billed by the input text
+	c.usage.RecordAudio(chatId, userId, storage.UsageSpeech, c.conf.Speech.Model,
+		tokenizer.ForModel(c.conf.Speech.Model).Count(input), 0)
+
+	c.log.With(
+		slog.Int64("user", chatId),
+		slog.Int("chars", len([]rune(input))),
+		slog.Int("bytes", len(audio)),
+	).Info("speech synthesized")
+	return audio, nil
+}
+
+// speakable removes markdown markup that would be read aloud and cuts the text to the endpoint limit
+func speakable(text string) string {
+	text = markdownMarkup.Replace(text)
+	runes := []rune(strings.TrimSpace(text))
+	if len(runes) > maxSpeechInput {
+		runes = runes[:maxSpeechInput]
+	}
+	return string(runes)
+}
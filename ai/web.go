@@ -0,0 +1,164 @@
+package ai
+
+import (
+	"Brainy/core"
+	"Brainy/holder"
+	"Brainy/lib/sl"
+	"Brainy/lib/tokenizer"
+	"Brainy/storage"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SetFetcher enables reading of web pages linked in messages and /tldr
+func (c *ChatGPT) SetFetcher(fetcher *Fetcher) {
+	c.fetcher = fetcher
+}
+
+func (c *ChatGPT) webEnabled() bool {
+	return c.conf.Web.Enabled && c.fetcher != nil
+}
+
+// SummarizePage reads a web page and returns its short summary; the request and the summary join the dialog
+// context, so the page can be discussed further
+func (c *ChatGPT) SummarizePage(chatId, userId int64, pageURL string) (string, error) {
+	if !c.webEnabled() {
+		return "", fmt.Errorf("reading web pages is disabled")
+	}
+	if err := c.usage.CheckQuota(chatId, userId, core.QuotaTokens, 1); err != nil {
+		return "", err
+	}
+	if err := c.moderate(chatId, userId, core.ModerationPrompt, pageURL); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	page, err := c.fetcher.Fetch(ctx, pageURL)
+	if err != nil {
+		return "", err
+	}
+	model := c.conf.ModelFor(core.TaskSummary)
+	content := pageContent(tokenizer.ForModel(model), page, c.conf.Web.MaxTokens)
+	// the text that goes to the model is checked like a message
+	if err := c.moderate(chatId, userId, core.ModerationPage, content); err != nil {
+		return "", err
+	}
+	request := NewRequest(buildPageSummaryPrompt(content), model)
+	chatCompletion, err := c.provider.Complete(ctx, request)
+	if err != nil {
+		return "", fmt.Errorf("summarizing page: %w", err)
+	}
+	c.usage.RecordCompletion(chatId, userId, storage.UsageSummarize, request.Model, chatCompletion)
+	if chatCompletion.Error != nil {
+		return "", fmt.Errorf("summarizing page: %w", newAPIError(0, chatCompletion.Error))
+	}
+	if len(chatCompletion.Choices) == 0 {
+		return "", fmt.Errorf("summarizing page: empty choices")
+	}
+	summary := strings.TrimSpace(chatCompletion.Choices[0].Message.Content.String())
+	if summary == "" {
+		return "", fmt.Errorf("summarizing page: empty summary")
+	}
+
+	c.contextManager.UpdateUserContext(chatId, holder.Message{Text: "Summarize " + page.URL, IsUser: true})
+	c.contextManager.UpdateUserContext(chatId, holder.Message{Text: summary, IsUser: false})
+	c.log.With(
+		slog.Int64("user", chatId),
+		slog.String("url", page.URL),
+		slog.String("model", chatCompletion.Model),
+	).Info("page summarized")
+	return summary, nil
+}
+
+func buildPageSummaryPrompt(content string) string {
+	return fmt.Sprintf(`Summarize the web page below: start with its gist in one or two sentences, then list the key points
+briefly. Write in the language of the page and respond ONLY with the summary.
+
+%s`, content)
+}
+
+// linkedPages reads pages linked in the question for the system message; pages that could not be read
+// or were blocked by moderation are mentioned so that the model does not make up their content
+func (c *ChatGPT) linkedPages(chatId, userId int64, question string) string {
+	if !c.webEnabled() || c.conf.Web.Links <= 0 {
+		return ""
+	}
+	links := core.Links(question)
+	if len(links) == 0 {
+		return ""
+	}
+	links = links[:min(len(links), c.conf.Web.Links)]
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.conf.Web.Timeout+5)*time.Second)
+	defer cancel()
+
+	pages := make([]string, len(links))
+	encoding := c.chatEncoding(chatId)
+	var wg sync.WaitGroup
+	for i, link := range links {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			page, err := c.fetcher.Fetch(ctx, link)
+			if err != nil {
+				c.log.With(
+					slog.Int64("user", chatId),
+					slog.String("url", link),
+				).Warn("reading linked page", sl.Err(err))
+				pages[i] = "[" + link + "]\nThe page could not be read."
+				return
+			}
+			// linked pages share the token budget
+			content := pageContent(encoding, page, c.conf.Web.MaxTokens/len(links))
+			if err := c.moderate(chatId, userId, core.ModerationPage, content); err != nil {
+				pages[i] = "[" + link + "]\nThe page is left out by moderation."
+				return
+			}
+			pages[i] = content
+		}()
+	}
+	wg.Wait()
+	return "Web pages linked in the message, use them to answer:\n\n" + strings.Join(pages, "\n\n")
+}
+
+// pageContent labels the page text with its address and title, cut to the token limit
+func pageContent(encoding *tokenizer.Encoding, page *WebPage, limit int) string {
+	label := "[" + page.URL + "]"
+	if page.Title != "" {
+		label += " " + page.Title
+	}
+	text, cut := truncateTokens(encoding, page.Text, limit)
+	if cut || page.Truncated {
+		text += "\n(the rest of the page is left out)"
+	}
+	return label + "\n" + text
+}
+
+// truncateTokens cuts the text to at most limit tokens, at a line or word boundary when one is near
+func truncateTokens(encoding *tokenizer.Encoding, text string, limit int) (string, bool) {
+	if limit <= 0 || encoding.Count(text) <= limit {
+		return text, false
+	}
+	// a token is rarely longer than a few characters, longer prefixes need not be counted
+	runes := []rune(text)
+	lo, hi := 0, min(len(runes), limit*8)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if encoding.Count(string(runes[:mid])) <= limit {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	cut := string(runes[:lo])
+	if i := strings.LastIndexAny(cut, "\n "); i > len(cut)*3/4 {
+		cut = cut[:i]
+	}
+	return strings.TrimSpace(cut), true
+}
@@ -0,0 +1,138 @@
+package ai
+
+import (
+	"Brainy/core"
+	"Brainy/storage"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// the test server answers the API and serves the pages; content with "forbidden" is flagged for violence,
+// the text of /page/dark has it
+func newPageChat(t *testing.T) (c *ChatGPT, audit *storage.MemoryModerationStorage, baseURL string, completions *atomic.Int32) {
+	completions = &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/moderations":
+			var request ModerationRequest
+			json.NewDecoder(r.Body).Decode(&request)
+			score := 0.0
+			if strings.Contains(request.Input, "forbidden") {
+				score = 0.9
+			}
+			fmt.Fprintf(w, `{"model":"omni-moderation-latest","results":[{"flagged":%v,"category_scores":{"violence":%v}}]}`, score > 0, score)
+		case r.URL.Path == "/chat/completions":
+			completions.Add(1)
+			w.Write([]byte(`{"model":"gpt-5-nano","choices":[{"message":{"role":"assistant","content":"a page about gardens"}}]}`))
+		case r.URL.Path == "/page/clean":
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte("This page says nice things about gardens."))
+		case r.URL.Path == "/page/dark":
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte("This page says forbidden things about gardens."))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	conf := &core.Config{Model: "gpt-5-nano"}
+	conf.Provider.BaseURL = server.URL
+	conf.Provider.MaxAttempts = 1
+	conf.Web.Enabled, conf.Web.MaxTokens, conf.Web.Allow = true, 1000, []string{"127.0.0.1"}
+	conf.Moderation.Enabled, conf.Moderation.Endpoint = true, true
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	count := func(text string) int { return len(strings.Fields(text)) }
+	c = NewChat(conf, log, storage.NewMemoryStorage(count, 1000), NewOpenAIProvider(conf, log))
+	c.SetFetcher(NewFetcher(conf, log))
+	audit = storage.NewMemoryModerationStorage()
+	c.SetModerationStorage(audit)
+	return c, audit, server.URL, completions
+}
+
+func TestSummarizePageModeration(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		kind      string // kind of the blocked content, empty when the page is summarized
+		decisions int    // written to the audit log
+	}{
+		{"clean page", "/page/clean", "", 2},
+		{"flagged page text", "/page/dark", core.ModerationPage, 2},
+		{"flagged link", "/page/clean?forbidden", core.ModerationPrompt, 1},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, audit, baseURL, completions := newPageChat(t)
+			chatId := int64(i + 1)
+
+			summary, err := c.SummarizePage(chatId, 7, baseURL+tt.path)
+			if tt.kind == "" {
+				if err != nil || summary != "a page about gardens" {
+					t.Fatalf("SummarizePage() = %q, %v", summary, err)
+				}
+			} else {
+				var moderationErr *core.ModerationError
+				if !errors.As(err, &moderationErr) || moderationErr.Kind != tt.kind {
+					t.Fatalf("SummarizePage() = %q, %v, want blocked %s", summary, err, tt.kind)
+				}
+				if completions.Load() != 0 {
+					t.Error("blocked content reached the model")
+				}
+			}
+
+			waitDecisions(t, audit, chatId, tt.decisions, min(len(tt.kind), 1))
+		})
+	}
+}
+
+// waitDecisions waits for the decisions written to the audit log in the background
+func waitDecisions(t *testing.T, audit *storage.MemoryModerationStorage, chatId int64, wantTotal, wantBlocked int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		total, blocked, err := audit.CountDecisions(chatId, time.Now().Add(-time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total == wantTotal {
+			if blocked != wantBlocked {
+				t.Errorf("%d blocked decisions, want %d", blocked, wantBlocked)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d decisions in the audit log, want %d", total, wantTotal)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestLinkedPagesModeration(t *testing.T) {
+	c, audit, baseURL, completions := newPageChat(t)
+	c.conf.Web.Links = 2
+
+	pages := c.linkedPages(1, 7, "compare "+baseURL+"/page/clean with "+baseURL+"/page/dark please")
+	if !strings.Contains(pages, "nice things about gardens") {
+		t.Errorf("clean page missing from %q", pages)
+	}
+	if strings.Contains(pages, "forbidden") {
+		t.Errorf("flagged page text reached the prompt: %q", pages)
+	}
+	if !strings.Contains(pages, "["+baseURL+"/page/dark]\nThe page is left out by moderation.") {
+		t.Errorf("flagged page is not mentioned as left out: %q", pages)
+	}
+	if completions.Load() != 0 {
+		t.Error("reading linked pages called the model")
+	}
+	waitDecisions(t, audit, 1, 2, 1)
+}
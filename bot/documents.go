@@ -0,0 +1,146 @@
+package bot
+
+import (
+	"Brainy/core"
+	"Brainy/lib/sl"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const callbackRemoveDocument = "doc:del:"
+
+// addDocument attaches a document to the chat; a caption is answered as a question once the document is read
+func (t *TgBot) addDocument(chatId, userId int64, document *tgbotapi.Document, caption string) {
+	t.sendChatAction(chatId, tgbotapi.ChatTyping)
+	doc, err := t.chat.AddDocument(chatId, userId, document.FileID, document.FileName, document.FileSize)
+	if err != nil {
+		t.log.With(
+			slog.Int64("id", chatId),
+			slog.String("name", document.FileName),
+		).Error("adding document", sl.Err(err))
+		t.plainResponse(chatId, errorText(err, "Sorry, I couldn't read this document."))
+		return
+	}
+
+	text := fmt.Sprintf("Added %s: %s. Ask me about it, /docs lists the documents of this chat.", doc.Name, documentSummary(doc))
+	t.plainResponse(chatId, text)
+	if caption != "" {
+		t.SendResponse(chatId, userId, caption)
+	}
+}
+
+// sendDocuments handles /docs: lists documents of the chat with buttons to remove them, "clear" removes all
+func (t *TgBot) sendDocuments(chatId int64, argument string) {
+	if !t.conf.Documents.Enabled {
+		t.plainResponse(chatId, "Documents are turned off.")
+		return
+	}
+	documents, err := t.chat.ListDocuments(chatId)
+	if err != nil {
+		t.log.With(
+			slog.Int64("id", chatId),
+		).Error("listing documents", sl.Err(err))
+		t.plainResponse(chatId, errorResponse)
+		return
+	}
+
+	if argument == "clear" {
+		removed := 0
+		for _, doc := range documents {
+			ok, err := t.chat.RemoveDocument(chatId, doc.Id)
+			if err != nil {
+				t.log.With(
+					slog.Int64("id", chatId),
+				).Error("removing document", sl.Err(err))
+				t.plainResponse(chatId, errorResponse)
+				return
+			}
+			if ok {
+				removed++
+			}
+		}
+		t.plainResponse(chatId, fmt.Sprintf("Documents removed: %d.", removed))
+		return
+	}
+
+	if len(documents) == 0 {
+		t.plainResponse(chatId, "There are no documents in this chat. Send a text, Markdown, CSV or PDF file to ask questions about it.")
+		return
+	}
+	var lines []string
+	var rows [][]tgbotapi.InlineKeyboardButton
+	used := 0
+	for i, doc := range documents {
+		n := i + 1
+		used += doc.Size
+		lines = append(lines, fmt.Sprintf("%d. %s — %s, %s", n, doc.Name, documentSummary(doc), doc.CreatedAt.Format("02 Jan 15:04")))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d. Remove", n), callbackRemoveDocument+doc.Id),
+		))
+	}
+	text := "Documents of this chat:\n" + strings.Join(lines, "\n")
+	if limit := t.conf.Documents.ChatLimit; limit > 0 {
+		text += fmt.Sprintf("\n\nUsed %s of %s. Use /docs clear to remove all.", formatSize(used), formatSize(limit))
+	}
+
+	// file names are user text, so the list is sent without markdown
+	msg := tgbotapi.NewMessage(chatId, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err = t.api.Send(msg); err != nil {
+		t.log.With(
+			slog.Int64("id", chatId),
+		).Error("sending documents", sl.Err(err))
+	}
+}
+
+// removeDocument handles the remove button of the /docs list
+func (t *TgBot) removeDocument(query *tgbotapi.CallbackQuery, id string) {
+	chatId := query.Message.Chat.ID
+	removed, err := t.chat.RemoveDocument(chatId, id)
+	if err != nil {
+		t.log.With(
+			slog.Int64("id", chatId),
+		).Error("removing document", sl.Err(err))
+		t.answerCallback(query.ID, errorResponse)
+		return
+	}
+	if !removed {
+		t.answerCallback(query.ID, "This document is already removed.")
+		return
+	}
+	t.answerCallback(query.ID, "Document removed.")
+}
+
+// documentSummary describes the size of a document for replies
+func documentSummary(doc core.Document) string {
+	summary := plural(doc.Chunks, "part") + ", " + formatSize(doc.Size)
+	if doc.Pages > 0 {
+		return plural(doc.Pages, "page") + ", " + summary
+	}
+	return summary
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+func formatSize(bytes int) string {
+	switch {
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+	case bytes >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(bytes)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", bytes)
+}
+
+func documentLimitText(e *core.DocumentLimitError) string {
+	return fmt.Sprintf("%s is too large: this chat has %s of documents out of %s, the file needs %s more. Remove some with /docs.",
+		e.Name, formatSize(e.Used), formatSize(e.Limit), formatSize(e.Size))
+}
@@ -0,0 +1,153 @@
+package bot
+
+import (
+	"Brainy/core"
+	"Brainy/lib/sl"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const (
+	galleryLimit       = 10
+	galleryPromptChars = 80
+	callbackResend     = "img:send:"
+	callbackRegenerate = "img:redo:"
+)
+
+// sendGallery lists recent images of the user with buttons to send them again or draw them anew
+func (t *TgBot) sendGallery(chatId, userId int64) {
+	images, err := t.chat.ListImages(userId, galleryLimit)
+	if err != nil {
+		t.log.With(
+			slog.Int64("id", chatId),
+		).Error("listing images", sl.Err(err))
+		t.plainResponse(chatId, errorResponse)
+		return
+	}
+	if len(images) == 0 {
+		t.plainResponse(chatId, "You have no images yet. Use /imagine to draw one.")
+		return
+	}
+
+	var lines []string
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, image := range images {
+		n := i + 1
+		lines = append(lines, fmt.Sprintf("%d. %s, %s: %s", n, image.CreatedAt.Format("02 Jan 15:04"), image.Operation, galleryPrompt(image)))
+		if image.Id == "" {
+			// recorded before the gallery existed
+			continue
+		}
+		buttons := []tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d. Send again", n), callbackResend+image.Id),
+		}
+		if image.Operation == core.ImageGenerate {
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d. Regenerate", n), callbackRegenerate+image.Id))
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(buttons...))
+	}
+
+	// prompts are user text, so the list is sent without markdown
+	msg := tgbotapi.NewMessage(chatId, "Your recent images:\n"+strings.Join(lines, "\n"))
+	if len(rows) > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	}
+	if _, err = t.api.Send(msg); err != nil {
+		t.log.With(
+			slog.Int64("id", chatId),
+		).Error("sending gallery", sl.Err(err))
+	}
+}
+
+// handleCallback handles buttons of the gallery and the document list; regeneration is charged to the user who pressed the button
+func (t *TgBot) handleCallback(query *tgbotapi.CallbackQuery) {
+	if id, ok := strings.CutPrefix(query.Data, callbackRemoveDocument); ok && query.Message != nil {
+		t.removeDocument(query, id)
+		return
+	}
+	var action, id string
+	for _, prefix := range []string{callbackResend, callbackRegenerate} {
+		if strings.HasPrefix(query.Data, prefix) {
+			action, id = prefix, strings.TrimPrefix(query.Data, prefix)
+		}
+	}
+	if action == "" || query.Message == nil {
+		t.answerCallback(query.ID, "")
+		return
+	}
+	chatId, userId := query.Message.Chat.ID, int64(query.From.ID)
+
+	image, err := t.chat.GalleryImage(id)
+	if err != nil {
+		t.log.With(
+			slog.Int64("id", chatId),
+		).Error("getting image", sl.Err(err))
+	}
+	if image == nil {
+		t.answerCallback(query.ID, "This image is no longer available.")
+		return
+	}
+	t.answerCallback(query.ID, "")
+
+	t.log.With(
+		slog.Int64("id", chatId),
+		slog.String("image", image.Id),
+		slog.String("action", action),
+	).Info("gallery")
+	if action == callbackRegenerate {
+		t.SendImageResponse(chatId, userId, core.ImageOptions{Prompt: image.Prompt, Style: image.Style})
+		return
+	}
+	t.resendImage(chatId, image)
+}
+
+// resendImage sends the image by its Telegram file ID, or uploads the local copy if Telegram no longer has it
+func (t *TgBot) resendImage(chatId int64, image *core.GalleryImage) {
+	t.sendChatAction(chatId, tgbotapi.ChatUploadPhoto)
+	msg := tgbotapi.NewPhotoShare(chatId, image.FileId)
+	msg.Caption = imageCaption(image.RevisedPrompt)
+	_, err := t.api.Send(msg)
+	if err == nil {
+		return
+	}
+	log := t.log.With(
+		slog.Int64("id", chatId),
+		slog.String("image", image.Id),
+	)
+	log.Warn("sending image by file id", sl.Err(err))
+
+	if image.Path != "" {
+		data, err := os.ReadFile(image.Path)
+		if err == nil {
+			upload := tgbotapi.NewPhotoUpload(chatId, tgbotapi.FileBytes{Name: imageFileName(0, data), Bytes: data})
+			upload.Caption = msg.Caption
+			if _, err = t.api.Send(upload); err == nil {
+				return
+			}
+		}
+		log.Error("uploading saved image", sl.Err(err))
+	}
+	t.plainResponse(chatId, "Sorry, I couldn't find this image anymore.")
+}
+
+func (t *TgBot) answerCallback(queryId, text string) {
+	if _, err := t.api.AnswerCallbackQuery(tgbotapi.NewCallback(queryId, text)); err != nil {
+		t.log.Warn("answering callback", sl.Err(err))
+	}
+}
+
+// galleryPrompt returns a short description of the image for the list
+func galleryPrompt(image core.GalleryImage) string {
+	prompt := []rune(image.Prompt)
+	if len(prompt) == 0 {
+		return "(no prompt)"
+	}
+	if len(prompt) > galleryPromptChars {
+		return string(prompt[:galleryPromptChars-1]) + "…"
+	}
+	return image.Prompt
+}
@@ -0,0 +1,149 @@
+package bot
+
+import (
+	"Brainy/core"
+	"Brainy/lib/sl"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const imagePolicyResponse = "The image service refused this description under its content policy. Try describing it differently."
+
+// moderationText explains why content was blocked without repeating it
+func moderationText(err *core.ModerationError) string {
+	if len(err.Categories) == 0 {
+		return "Sorry, I can't check this message right now, so I won't pass it on. Please try again later."
+	}
+	categories := strings.ReplaceAll(strings.Join(err.Categories, ", "), "/", " / ")
+	switch err.Kind {
+	case core.ModerationImage:
+		return "Sorry, I won't draw that: the description was flagged for " + categories + "."
+	case core.ModerationPage:
+		return "Sorry, I won't summarize this page: its text was flagged for " + categories + "."
+	}
+	return "Sorry, I can't help with that: the message was flagged for " + categories + "."
+}
+
+// imageErrorText tells a refusal of the image service apart from other failures of drawing
+func imageErrorText(err error) string {
+	var moderationErr *core.ModerationError
+	if errors.Is(err, core.ErrContentPolicy) && !errors.As(err, &moderationErr) {
+		return imagePolicyResponse
+	}
+	return errorText(err, imageErrorResponse)
+}
+
+// sendModeration handles /moderation for admins: without arguments it shows the report of the chat,
+// "category threshold" sets a threshold for the chat, "category default" resets it
+func (t *TgBot) sendModeration(chatId, userId int64, arguments string) {
+	if !t.conf.IsAdmin(userId) {
+		t.plainResponse(chatId, "Only bot admins can see and change moderation.")
+		return
+	}
+	fields := strings.Fields(arguments)
+	if len(fields) == 0 {
+		report, err := t.chat.ModerationReport(chatId)
+		if err != nil {
+			t.log.With(
+				slog.Int64("id", chatId),
+			).Error("moderation report", sl.Err(err))
+			t.plainResponse(chatId, errorResponse)
+			return
+		}
+		// blocked messages are user text, so the report is sent without markdown
+		msg := tgbotapi.NewMessage(chatId, moderationReportText(report))
+		if _, err = t.api.Send(msg); err != nil {
+			t.log.With(
+				slog.Int64("id", chatId),
+			).Error("sending moderation report", sl.Err(err))
+		}
+		return
+	}
+	if len(fields) != 2 {
+		t.plainResponse(chatId, "Use /moderation to see the report, /moderation category 0.3 to set a threshold for this chat, /moderation category default to reset it.")
+		return
+	}
+
+	category := strings.ToLower(fields[0])
+	threshold := -1.0
+	if fields[1] != "default" {
+		value, err := strconv.ParseFloat(strings.ReplaceAll(fields[1], ",", "."), 64)
+		if err != nil || value < 0 {
+			t.plainResponse(chatId, "The threshold is a score from 0 to 1, lower blocks more. Use default to reset it.")
+			return
+		}
+		threshold = value
+	}
+	if err := t.chat.SetModerationThreshold(chatId, category, threshold); err != nil {
+		t.log.With(
+			slog.Int64("id", chatId),
+		).Error("setting moderation threshold", sl.Err(err))
+		t.plainResponse(chatId, errorText(err, errorResponse))
+		return
+	}
+	t.log.With(
+		slog.Int64("id", chatId),
+		slog.Int64("admin", userId),
+		slog.String("category", category),
+		slog.Float64("threshold", threshold),
+	).Info("moderation threshold changed")
+	if threshold < 0 {
+		t.plainResponse(chatId, "The threshold of "+category+" is reset in this chat.")
+		return
+	}
+	t.plainResponse(chatId, fmt.Sprintf("Content of this chat scored %s or more for %s is now blocked.", formatScore(threshold), category))
+}
+
+func moderationReportText(report core.ModerationReport) string {
+	if !report.Enabled {
+		return "Moderation is turned off."
+	}
+	var b strings.Builder
+	if report.Endpoint {
+		fmt.Fprintf(&b, "Messages, image descriptions and read web pages are checked by keywords and the moderation service, categories scored %s or more are blocked.\n", formatScore(report.Threshold))
+	} else {
+		b.WriteString("Messages, image descriptions and read web pages are checked by keywords.\n")
+	}
+	if thresholds := thresholdsText(report.Thresholds); thresholds != "" {
+		b.WriteString("Configured thresholds: " + thresholds + ".\n")
+	}
+	if thresholds := thresholdsText(report.ChatThresholds); thresholds != "" {
+		b.WriteString("Thresholds of this chat: " + thresholds + ".\n")
+	}
+	fmt.Fprintf(&b, "\nLast 30 days: %d checked, %d blocked.", report.Checked, report.Blocked)
+	if len(report.Recent) > 0 {
+		b.WriteString("\n\nRecently blocked:")
+		for _, decision := range report.Recent {
+			text := []rune(strings.Join(strings.Fields(decision.Text), " "))
+			if len(text) > 100 {
+				text = append(text[:100], '…')
+			}
+			categories := strings.Join(decision.Categories, ", ")
+			if categories == "" {
+				categories = "not checked"
+			}
+			fmt.Fprintf(&b, "\n- %s, user %d, %s by %s: %s\n  %s", decision.CreatedAt.Format("02 Jan 15:04"),
+				decision.UserId, decision.Kind, decision.Source, categories, string(text))
+		}
+	}
+	return b.String()
+}
+
+func thresholdsText(thresholds map[string]float64) string {
+	var parts []string
+	for _, category := range slices.Sorted(maps.Keys(thresholds)) {
+		parts = append(parts, category+" "+formatScore(thresholds[category]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatScore(score float64) string {
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
@@ -3,9 +3,16 @@ package bot
 import (
 	"Brainy/core"
 	"Brainy/lib/sl"
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,6 +26,55 @@ var smileEmojis = []string{
 
 const errorResponse = "Sorry, I'm not feeling well today. Please try again later."
 
+const imageErrorResponse = "Sorry, I couldn't generate the image. Please try again with a different description."
+
+const voiceErrorResponse = "Sorry, I couldn't make out the audio. Please try again or type your message."
+
+// audio file extensions by MIME type, the transcription endpoint detects format by extension
+var audioExtensions = map[string]string{
+	"audio/ogg":    "ogg",
+	"audio/opus":   "ogg",
+	"audio/mpeg":   "mp3",
+	"audio/mp3":    "mp3",
+	"audio/mp4":    "m4a",
+	"audio/x-m4a":  "m4a",
+	"audio/m4a":    "m4a",
+	"audio/aac":    "m4a",
+	"audio/wav":    "wav",
+	"audio/x-wav":  "wav",
+	"audio/webm":   "webm",
+	"audio/flac":   "flac",
+	"audio/x-flac": "flac",
+}
+
+// error replies by error class, checked in order
+var errorResponses = []struct {
+	err  error
+	text string
+}{
+	{core.ErrRateLimited, "I'm getting too many requests right now. Please try again in a minute."},
+	{core.ErrQuotaExceeded, "My usage quota is exhausted for now. Please try again later."},
+	{core.ErrContentPolicy, "Sorry, I can't help with that: the request was rejected by the content policy."},
+	{core.ErrContextLength, "Our conversation got too long for me. Use /clear to start a new topic."},
+	{core.ErrServer, "The AI service is having problems at the moment. Please try again later."},
+	{core.ErrUnknownModel, "The AI model I use is not available right now. Please try again later."},
+	{core.ErrUnsupportedDocument, "I can read plain text, Markdown, CSV and PDF files, but not this one."},
+	{core.ErrEmptyDocument, "I found no text in this document. Scanned PDFs are pictures of pages, send the text instead."},
+	{core.ErrForbiddenURL, "I can't open this address: internal and blocked hosts are off limits."},
+	{core.ErrUnreadablePage, "I found no text to read on this page."},
+	{core.ErrInvalidMask, "The mask must be a PNG with a transparent area over the part to change."},
+	{core.ErrUnknownTimezone, "I don't know this timezone. Use a name like Europe/Kyiv or America/New_York."},
+}
+
+const (
+	streamPlaceholder  = "…"
+	streamEditInterval = 1500 * time.Millisecond
+	maxMessageLength   = 4096
+	maxDownloadSize    = 20 << 20 // Bot API limit for file downloads
+	maxCaption         = 1024     // characters in a photo caption
+	maxMediaGroup      = 10       // photos in one album
+)
+
 type TgBot struct {
 	conf        *core.Config
 	log         *slog.Logger
@@ -65,6 +121,10 @@ func (t *TgBot) Start() error {
 	for {
 		select {
 		case update := <-updates:
+			if update.CallbackQuery != nil {
+				go t.handleCallback(update.CallbackQuery)
+				continue
+			}
 			if update.Message == nil {
 				continue
 			}
@@ -72,8 +132,87 @@ func (t *TgBot) Start() error {
 			incoming := update.Message
 			chat := incoming.Chat
 			question := incoming.Text
+			userId := senderId(incoming)
+
+			// commands in captions of photos and documents are not parsed by the Bot API
+			command, arguments := incoming.Command(), incoming.CommandArguments()
+			if !incoming.IsCommand() {
+				command, arguments = captionCommand(incoming.Caption)
+			}
 
-			if !incoming.IsCommand() && !chat.IsPrivate() && !t.isMentioned(incoming.Text) && !t.isReplyToBot(incoming) {
+			// documents dropped into a group are read without a mention
+			document := incoming.Document
+			if document != nil && (!t.conf.Documents.Enabled || core.DocumentFormat(document.FileName) == "") {
+				document = nil
+			}
+
+			if command == "" && document == nil && !chat.IsPrivate() && !t.isMentioned(incoming.Text+incoming.Caption) && !t.isReplyToBot(incoming) {
+				continue
+			}
+
+			if command == "edit" || command == "vary" {
+				// the picture is replied to or attached; a PNG document attached to a reply is the mask
+				source, mask := photoFileId(incoming.ReplyToMessage), ""
+				if attached := photoFileId(incoming); source == "" {
+					source = attached
+				} else if incoming.Document != nil && incoming.Document.MimeType == "image/png" {
+					mask = attached
+				}
+				if source == "" {
+					t.plainResponse(chat.ID, "Reply to a photo with /edit and a description of the change, or with /vary.")
+					continue
+				}
+				if command == "vary" {
+					go t.SendImageVariation(chat.ID, userId, source)
+					continue
+				}
+				instruction := strings.TrimSpace(arguments)
+				if instruction == "" {
+					t.plainResponse(chat.ID, "Please describe the change. Example: /edit make it a winter scene")
+					continue
+				}
+				go t.SendImageEdit(chat.ID, userId, source, mask, instruction)
+				continue
+			}
+
+			if incoming.Voice != nil || incoming.Audio != nil {
+				fileId, mimeType := "", ""
+				if incoming.Voice != nil {
+					fileId, mimeType = incoming.Voice.FileID, incoming.Voice.MimeType
+				} else {
+					fileId, mimeType = incoming.Audio.FileID, incoming.Audio.MimeType
+				}
+				t.log.With(
+					slog.String("user", chat.UserName),
+					slog.Int64("id", chat.ID),
+				).Info("incoming audio")
+				go t.SendVoiceResponse(chat.ID, userId, fileId, audioFileName(mimeType))
+				continue
+			}
+
+			if incoming.Photo != nil && len(*incoming.Photo) > 0 {
+				photos := *incoming.Photo
+				caption := strings.TrimSpace(strings.ReplaceAll(incoming.Caption, "@"+t.botUsername, ""))
+				t.log.With(
+					slog.String("user", chat.UserName),
+					slog.Int64("id", chat.ID),
+				).Info("incoming photo")
+				// the last size is the largest
+				go t.SendPhotoResponse(chat.ID, userId, photos[len(photos)-1].FileID, caption)
+				continue
+			}
+
+			if document != nil && command == "" {
+				caption := ""
+				if t.isMentioned(incoming.Caption) || chat.IsPrivate() {
+					caption = strings.TrimSpace(strings.ReplaceAll(incoming.Caption, "@"+t.botUsername, ""))
+				}
+				t.log.With(
+					slog.String("user", chat.UserName),
+					slog.Int64("id", chat.ID),
+					slog.String("name", document.FileName),
+				).Info("incoming document")
+				go t.addDocument(chat.ID, userId, document, caption)
 				continue
 			}
 
@@ -94,8 +233,21 @@ func (t *TgBot) Start() error {
 					text += "/hello - bot says random fact\n"
 					text += "/topic - set a subject of conversation\n"
 					text += "/ask - ask something or just reply on previous bot message\n"
-					text += "/imagine - generate an image from description\n"
+					text += "/imagine - generate an image from description, options: --style --size --quality --n\n"
+					text += "/style - show or set the style of generated images\n"
+					text += "/edit - reply to a photo to change it by description\n"
+					text += "/vary - reply to a photo to get its variation\n"
+					text += "/images - show your recent images to send again or regenerate\n"
+					text += "/autoimage - turn drawing on request in messages on or off\n"
+					text += "/say - answer with a voice message\n"
+					text += "/voice - turn voice replies on or off\n"
+					text += "/model - show or set the AI model answering in this chat\n"
+					text += "/timezone - show or set the timezone of this chat for questions about time\n"
+					text += "/docs - list or remove documents sent to this chat for questions\n"
+					text += "/tldr - summarize a web page by link, or reply to a message with a link\n"
 					text += "/clear - clear bot memory to begin new topic\n"
+					text += "/usage - show tokens and cost spent today and this month\n"
+					text += "/moderation - for admins: show blocked content or set category thresholds of this chat\n"
 					t.plainResponse(chat.ID, text)
 					continue
 				}
@@ -103,12 +255,75 @@ func (t *TgBot) Start() error {
 					question = strings.TrimPrefix(question, "/ask")
 				}
 				if incoming.Command() == "imagine" {
-					imagePrompt := strings.TrimSpace(strings.TrimPrefix(question, "/imagine"))
-					if imagePrompt == "" {
-						t.plainResponse(chat.ID, "Please provide a description for the image. Example: /imagine a sunset over mountains")
+					options, err := parseImageOptions(incoming.CommandArguments())
+					if err != nil {
+						t.plainResponse(chat.ID, errorText(err, imageErrorResponse))
+						continue
+					}
+					if options.Prompt == "" {
+						t.plainResponse(chat.ID, "Please provide a description for the image. Example: /imagine --style photo --size landscape a sunset over mountains")
+						continue
+					}
+					go t.SendImageResponse(chat.ID, userId, options)
+					continue
+				}
+				if incoming.Command() == "style" {
+					go t.switchImageStyle(chat.ID, strings.TrimSpace(incoming.CommandArguments()))
+					continue
+				}
+				if incoming.Command() == "model" {
+					go t.switchModel(chat.ID, strings.TrimSpace(incoming.CommandArguments()))
+					continue
+				}
+				if incoming.Command() == "timezone" {
+					go t.switchTimezone(chat.ID, strings.TrimSpace(incoming.CommandArguments()))
+					continue
+				}
+				if incoming.Command() == "say" {
+					sayText := strings.TrimSpace(strings.TrimPrefix(question, "/say"))
+					if sayText == "" {
+						t.plainResponse(chat.ID, "Please provide a question. Example: /say how do you pronounce croissant")
 						continue
 					}
-					go t.SendImageResponse(chat.ID, imagePrompt)
+					go t.sendVoiceReply(chat.ID, userId, sayText)
+					continue
+				}
+				if incoming.Command() == "voice" {
+					go t.switchVoiceReplies(chat.ID, strings.TrimSpace(incoming.CommandArguments()))
+					continue
+				}
+				if incoming.Command() == "autoimage" {
+					go t.switchImageIntent(chat.ID, strings.TrimSpace(incoming.CommandArguments()))
+					continue
+				}
+				if incoming.Command() == "images" {
+					go t.sendGallery(chat.ID, userId)
+					continue
+				}
+				if incoming.Command() == "tldr" {
+					link := strings.TrimSpace(incoming.CommandArguments())
+					if reply := incoming.ReplyToMessage; link == "" && reply != nil {
+						if links := core.Links(reply.Text + " " + reply.Caption); len(links) > 0 {
+							link = links[0]
+						}
+					}
+					if link == "" {
+						t.plainResponse(chat.ID, "Please provide a link. Example: /tldr https://go.dev/blog/go1.24")
+						continue
+					}
+					go t.sendPageSummary(chat.ID, userId, link)
+					continue
+				}
+				if incoming.Command() == "docs" {
+					go t.sendDocuments(chat.ID, strings.TrimSpace(incoming.CommandArguments()))
+					continue
+				}
+				if incoming.Command() == "moderation" {
+					go t.sendModeration(chat.ID, userId, incoming.CommandArguments())
+					continue
+				}
+				if incoming.Command() == "usage" {
+					go t.sendUsage(chat.ID, userId)
 					continue
 				}
 				if incoming.Command() == "clear" {
@@ -135,7 +350,7 @@ func (t *TgBot) Start() error {
 				slog.String("text", logText),
 			).Info("incoming message")
 
-			go t.SendResponse(chat.ID, question)
+			go t.SendResponse(chat.ID, userId, question)
 
 		case <-t.stopChan:
 			t.log.Info("stopping bot gracefully")
@@ -170,30 +385,82 @@ func (t *TgBot) sendRandomEmoji(chatId int64) {
 	}
 }
 
-func (t *TgBot) composeReply(chatId int64, request string) string {
+// composeReply returns the answer, or the error reply together with the error
+func (t *TgBot) composeReply(chatId, userId int64, request string) (string, error) {
 	// Get the response from the chat service
-	response, err := t.chat.GetResponse(chatId, request)
+	response, err := t.chat.GetResponse(chatId, userId, request)
 	if err != nil {
 		t.log.With(
 			slog.Int64("id", chatId),
 		).Error("composing reply", sl.Err(err))
-		response = errorResponse
+		response = errorText(err, errorResponse)
+	}
+	return response, err
+}
+
+// errorText returns a reply explaining the error class, or fallback for unclassified errors
+func errorText(err error, fallback string) string {
+	var quotaErr *core.QuotaError
+	if errors.As(err, &quotaErr) {
+		return quotaText(quotaErr)
+	}
+	var moderationErr *core.ModerationError
+	if errors.As(err, &moderationErr) {
+		return moderationText(moderationErr)
+	}
+	var optionErr *core.OptionError
+	if errors.As(err, &optionErr) {
+		return fmt.Sprintf("Unsupported %s %q. Use one of: %s.", optionErr.Option, optionErr.Value, strings.Join(optionErr.Allowed, ", "))
+	}
+	var limitErr *core.DocumentLimitError
+	if errors.As(err, &limitErr) {
+		return documentLimitText(limitErr)
 	}
-	return response
+	for _, r := range errorResponses {
+		if errors.Is(err, r.err) {
+			return r.text
+		}
+	}
+	return fallback
 }
 
-func (t *TgBot) SendResponse(chatId int64, request string) {
+func (t *TgBot) SendResponse(chatId, userId int64, request string) {
 	// First, detect if user wants to generate an image
-	wantsImage, imagePrompt := t.chat.DetectImageIntent(request)
+	wantsImage, imagePrompt := t.chat.DetectImageIntent(chatId, userId, request)
 	if wantsImage && imagePrompt != "" {
 		t.log.With(
 			slog.Int64("id", chatId),
 			slog.String("prompt", imagePrompt),
 		).Info("detected image generation intent")
-		t.SendImageResponse(chatId, imagePrompt)
+		t.SendImageResponse(chatId, userId, core.ImageOptions{Prompt: imagePrompt})
+		return
+	}
+
+	// dictionary articles are shown as text and voiced for pronunciation practice
+	dictionary := strings.HasPrefix(request, "/cat ") || strings.HasPrefix(request, "/cas ")
+	if !dictionary && t.chat.VoiceReplies(chatId) {
+		t.sendVoiceReply(chatId, userId, request)
 		return
 	}
 
+	var reply string
+	var err error
+	if streaming, ok := t.chat.(core.StreamingChatService); ok {
+		reply, err = t.sendStreamResponse(streaming, chatId, userId, request)
+	} else {
+		reply, err = t.sendTextResponse(chatId, userId, request)
+	}
+	if err == nil && dictionary {
+		if err := t.sendVoice(chatId, userId, reply); err != nil {
+			t.log.With(
+				slog.Int64("id", chatId),
+			).Warn("voicing dictionary article", sl.Err(err))
+		}
+	}
+}
+
+// sendTextResponse shows typing status while the answer is composed and sends it
+func (t *TgBot) sendTextResponse(chatId, userId int64, request string) (string, error) {
 	stopTicker := make(chan bool)
 	replyReady := make(chan string)
 
@@ -213,8 +480,10 @@ func (t *TgBot) SendResponse(chatId int64, request string) {
 		}
 	}()
 
+	var err error
 	go func() {
-		reply := t.composeReply(chatId, request)
+		reply, replyErr := t.composeReply(chatId, userId, request)
+		err = replyErr
 		replyReady <- reply
 	}()
 
@@ -222,12 +491,281 @@ func (t *TgBot) SendResponse(chatId int64, request string) {
 	stopTicker <- true
 
 	t.plainResponse(chatId, reply)
+	return reply, err
 }
 
-// SendImageResponse generates and sends an image
-func (t *TgBot) SendImageResponse(chatId int64, prompt string) {
+// sendStreamResponse posts a placeholder message and edits it as the answer is generated
+func (t *TgBot) sendStreamResponse(chat core.StreamingChatService, chatId, userId int64, request string) (string, error) {
+	t.sendChatAction(chatId, "typing")
+
+	placeholder, err := t.api.Send(tgbotapi.NewMessage(chatId, streamPlaceholder))
+	if err != nil {
+		t.log.With(
+			slog.Int64("id", chatId),
+		).Error("sending placeholder", sl.Err(err))
+		reply, err := t.composeReply(chatId, userId, request)
+		t.plainResponse(chatId, reply)
+		return reply, err
+	}
+
+	lastEdit := time.Now()
+	lastText := ""
+	reply, err := chat.GetResponseStream(chatId, userId, request, func(partial string) {
+		if time.Since(lastEdit) < streamEditInterval || len([]rune(partial)) > maxMessageLength {
+			return
+		}
+		lastEdit = time.Now()
+		lastText = prepareMarkdown(partial)
+		edit := tgbotapi.NewEditMessageText(chatId, placeholder.MessageID, lastText)
+		if _, err := t.api.Send(edit); err != nil {
+			t.log.With(
+				slog.Int64("id", chatId),
+			).Debug("editing partial message", sl.Err(err))
+		}
+	})
+	if err != nil {
+		t.log.With(
+			slog.Int64("id", chatId),
+		).Error("composing stream reply", sl.Err(err))
+		reply = errorText(err, errorResponse)
+	}
+	if reply == "" {
+		// the answer was a picture without text
+		if _, err := t.api.DeleteMessage(tgbotapi.NewDeleteMessage(chatId, placeholder.MessageID)); err != nil {
+			t.log.With(
+				slog.Int64("id", chatId),
+			).Warn("deleting placeholder", sl.Err(err))
+		}
+		return reply, nil
+	}
+
+	t.finishStreamResponse(chatId, placeholder.MessageID, reply, lastText)
+	return reply, err
+}
+
+// sendVoiceReply answers with a voice note, falling back to text when speech synthesis fails
+func (t *TgBot) sendVoiceReply(chatId, userId int64, request string) {
+	t.sendChatAction(chatId, tgbotapi.ChatRecordAudio)
+
+	reply, err := t.composeReply(chatId, userId, request)
+	if err != nil {
+		t.plainResponse(chatId, reply)
+		return
+	}
+	if reply == "" {
+		// the answer was a picture without text
+		return
+	}
+	if err := t.sendVoice(chatId, userId, reply); err != nil {
+		t.log.With(
+			slog.Int64("id", chatId),
+		).Warn("sending voice reply", sl.Err(err))
+		t.plainResponse(chatId, reply)
+	}
+}
+
+// sendVoice synthesizes the text and sends it as a voice note, or as an audio file for formats other than opus
+func (t *TgBot) sendVoice(chatId, userId int64, text string) error {
+	audio, err := t.chat.Synthesize(chatId, userId, text)
+	if err != nil {
+		return err
+	}
+
+	format := t.conf.Speech.Format
+	if format == "" || format == "opus" {
+		// OpenAI returns opus in an OGG container, as Telegram expects for voice notes
+		_, err = t.api.Send(tgbotapi.NewVoiceUpload(chatId, tgbotapi.FileBytes{Name: "reply.ogg", Bytes: audio}))
+	} else {
+		_, err = t.api.Send(tgbotapi.NewAudioUpload(chatId, tgbotapi.FileBytes{Name: "reply." + format, Bytes: audio}))
+	}
+	if err != nil {
+		return fmt.Errorf("sending audio: %w", err)
+	}
+	return nil
+}
+
+// switchVoiceReplies handles /voice on|off, without an argument it toggles the setting
+func (t *TgBot) switchVoiceReplies(chatId int64, argument string) {
+	enabled := !t.chat.VoiceReplies(chatId)
+	switch strings.ToLower(argument) {
+	case "on":
+		enabled = true
+	case "off":
+		enabled = false
+	}
+	if enabled && !t.conf.Speech.Enabled {
+		t.plainResponse(chatId, "Voice replies are not available.")
+		return
+	}
+	if err := t.chat.SetVoiceReplies(chatId, enabled); err != nil {
+		t.log.With(
+			slog.Int64("id", chatId),
+		).Error("switching voice replies", sl.Err(err))
+		t.plainResponse(chatId, errorResponse)
+		return
+	}
+	if enabled {
+		t.plainResponse(chatId, "Voice replies are on. Use /voice off to get text again.")
+	} else {
+		t.plainResponse(chatId, "Voice replies are off.")
+	}
+}
+
+// switchImageIntent handles /autoimage on|off, without an argument it toggles the setting
+func (t *TgBot) switchImageIntent(chatId int64, argument string) {
+	enabled := !t.chat.ImageIntent(chatId)
+	switch strings.ToLower(argument) {
+	case "on":
+		enabled = true
+	case "off":
+		enabled = false
+	}
+	if enabled && !t.conf.ImageIntent.Enabled {
+		t.plainResponse(chatId, "Drawing on request is not available, use /imagine.")
+		return
+	}
+	if err := t.chat.SetImageIntent(chatId, enabled); err != nil {
+		t.log.With(
+			slog.Int64("id", chatId),
+		).Error("switching image intent", sl.Err(err))
+		t.plainResponse(chatId, errorResponse)
+		return
+	}
+	if enabled {
+		t.plainResponse(chatId, "I'll draw when you ask for a picture in a message.")
+	} else {
+		t.plainResponse(chatId, "I won't look for picture requests in messages, use /imagine to draw.")
+	}
+}
+
+// finishStreamResponse makes the final MarkdownV2 edit, falling back to plain text or a new message
+func (t *TgBot) finishStreamResponse(chatId int64, messageId int, reply, lastText string) {
+	text := prepareMarkdown(reply)
+
+	edit := tgbotapi.NewEditMessageText(chatId, messageId, sanitize(text))
+	edit.ParseMode = "MarkdownV2"
+	_, err := t.api.Send(edit)
+	if err == nil {
+		return
+	}
+	t.log.With(
+		slog.Int64("id", chatId),
+	).Warn("editing message", sl.Err(err))
+
+	if text == lastText {
+		// the plain version is already shown
+		return
+	}
+	if _, err := t.api.Send(tgbotapi.NewEditMessageText(chatId, messageId, text)); err == nil {
+		return
+	}
+
+	// message is probably too long to fit, replace the placeholder with regular messages
+	if _, err := t.api.DeleteMessage(tgbotapi.NewDeleteMessage(chatId, messageId)); err != nil {
+		t.log.With(
+			slog.Int64("id", chatId),
+		).Warn("deleting placeholder", sl.Err(err))
+	}
+	t.plainResponse(chatId, reply)
+}
+
+// SendPhotoResponse answers a question about a picture sent by the user
+func (t *TgBot) SendPhotoResponse(chatId, userId int64, fileId, caption string) {
+	t.sendChatAction(chatId, "typing")
+	stopTyping := make(chan bool)
+	go func() {
+		ticker := time.NewTicker(4 * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				t.sendChatAction(chatId, "typing")
+			case <-stopTyping:
+				return
+			}
+		}
+	}()
+
+	reply, err := t.chat.GetPhotoResponse(chatId, userId, fileId, caption)
+	close(stopTyping)
+	if err != nil {
+		t.log.With(
+			slog.Int64("id", chatId),
+		).Error("composing photo reply", sl.Err(err))
+		reply = errorText(err, errorResponse)
+	}
+	t.plainResponse(chatId, reply)
+}
+
+// SendVoiceResponse transcribes a voice note or audio file and answers the transcript as a text message
+func (t *TgBot) SendVoiceResponse(chatId, userId int64, fileId, fileName string) {
+	t.sendChatAction(chatId, "typing")
+
+	transcript, err := t.chat.Transcribe(chatId, userId, fileId, fileName)
+	if err != nil {
+		t.log.With(
+			slog.Int64("id", chatId),
+		).Error("transcribing audio", sl.Err(err))
+		t.plainResponse(chatId, errorText(err, voiceErrorResponse))
+		return
+	}
+	if transcript == "" {
+		t.plainResponse(chatId, voiceErrorResponse)
+		return
+	}
+
+	if t.conf.Transcription.ShowTranscript {
+		t.plainResponse(chatId, "🎤 "+transcript)
+	}
+	t.SendResponse(chatId, userId, transcript)
+}
+
+// audioFileName returns a file name with the extension of the audio format, voice notes are OGG/Opus
+func audioFileName(mimeType string) string {
+	if ext, ok := audioExtensions[strings.ToLower(mimeType)]; ok {
+		return "audio." + ext
+	}
+	return "audio.ogg"
+}
+
+// DownloadFile downloads a file sent to the bot, implements core.FileLoader
+func (t *TgBot) DownloadFile(fileId string) ([]byte, error) {
+	link, err := t.api.GetFileDirectURL(fileId)
+	if err != nil {
+		return nil, fmt.Errorf("getting file link: %w", err)
+	}
+
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Get(link)
+	if err != nil {
+		// the error contains the link with the bot token
+		return nil, fmt.Errorf("downloading file %s failed", fileId)
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			t.log.Error("closing file body", sl.Err(err))
+		}
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading file %s: %s", fileId, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDownloadSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading file %s: %w", fileId, err)
+	}
+	if len(data) > maxDownloadSize {
+		return nil, fmt.Errorf("file %s is larger than %d bytes", fileId, maxDownloadSize)
+	}
+	return data, nil
+}
+
+// SendImageResponse generates and sends images
+func (t *TgBot) SendImageResponse(chatId, userId int64, options core.ImageOptions) {
 	stopTicker := make(chan bool)
-	imageReady := make(chan string)
+	imageReady := make(chan []core.GeneratedImage)
 	errorChan := make(chan error)
 
 	t.sendChatAction(chatId, "typing")
@@ -247,45 +785,331 @@ func (t *TgBot) SendImageResponse(chatId int64, prompt string) {
 	}()
 
 	go func() {
-		imageURL, err := t.chat.GenerateImage(chatId, prompt)
+		images, err := t.chat.GenerateImage(chatId, userId, options)
 		if err != nil {
 			errorChan <- err
 			return
 		}
-		imageReady <- imageURL
+		imageReady <- images
 	}()
 
 	select {
-	case imageURL := <-imageReady:
+	case images := <-imageReady:
 		stopTicker <- true
-		t.sendImage(chatId, imageURL)
+		t.SendImages(chatId, userId, images)
 	case err := <-errorChan:
 		stopTicker <- true
 		t.log.With(
 			slog.Int64("id", chatId),
 		).Error("generating image", sl.Err(err))
-		t.plainResponse(chatId, "Sorry, I couldn't generate the image. Please try again with a different description.")
+		t.plainResponse(chatId, imageErrorText(err))
 	}
 }
 
-func (t *TgBot) sendImage(chatId int64, imageURL string) {
-	msg := tgbotapi.NewPhotoShare(chatId, imageURL)
-	_, err := t.api.Send(msg)
+// switchImageStyle handles /style, without an argument it lists the presets
+func (t *TgBot) switchImageStyle(chatId int64, style string) {
+	if style == "" {
+		current := t.chat.ImageStyle(chatId)
+		var lines []string
+		for _, name := range t.chat.ImageStyles() {
+			if name == current {
+				name += " (current)"
+			}
+			lines = append(lines, "- "+name)
+		}
+		t.plainResponse(chatId, "Image styles:\n"+strings.Join(lines, "\n")+"\n\nUse /style name to change the style in this chat.")
+		return
+	}
+	if err := t.chat.SetImageStyle(chatId, style); err != nil {
+		t.log.With(
+			slog.Int64("id", chatId),
+		).Warn("setting image style", sl.Err(err))
+		t.plainResponse(chatId, errorText(err, errorResponse))
+		return
+	}
+	t.plainResponse(chatId, "Images in this chat will be drawn in the "+style+" style.")
+}
+
+// switchModel handles /model, without an argument it lists the models, "default" returns to the configured one
+func (t *TgBot) switchModel(chatId int64, model string) {
+	if model == "" {
+		current := t.chat.ChatModel(chatId)
+		models := t.chat.Models()
+		if len(models) == 0 {
+			t.plainResponse(chatId, "This chat is answered by "+current+", no other models are available.")
+			return
+		}
+		var lines []string
+		for _, name := range models {
+			line := "- " + name
+			if name == current {
+				line += " (current)"
+			}
+			if spec, ok := t.conf.ModelSpec(name); ok {
+				line += ": " + modelDescription(spec)
+			}
+			lines = append(lines, line)
+		}
+		t.plainResponse(chatId, "Models:\n"+strings.Join(lines, "\n")+
+			"\n\nUse /model name to change the model in this chat, /model default to return to "+t.conf.ModelFor(core.TaskChat)+".")
+		return
+	}
+	if model == "default" {
+		model = ""
+	}
+	if err := t.chat.SetChatModel(chatId, model); err != nil {
+		t.log.With(
+			slog.Int64("id", chatId),
+		).Warn("setting chat model", sl.Err(err))
+		t.plainResponse(chatId, errorText(err, errorResponse))
+		return
+	}
+	t.plainResponse(chatId, "This chat will be answered by "+t.chat.ChatModel(chatId)+".")
+}
+
+// switchTimezone handles /timezone, without an argument it shows the timezone, "default" returns to the configured one
+func (t *TgBot) switchTimezone(chatId int64, timezone string) {
+	if timezone == "" {
+		t.plainResponse(chatId, "The timezone of this chat is "+t.chat.ChatTimezone(chatId)+
+			".\n\nUse /timezone name to change it, like /timezone Europe/Kyiv, /timezone default to return to "+t.conf.Timezone+".")
+		return
+	}
+	if timezone == "default" {
+		timezone = ""
+	}
+	if err := t.chat.SetChatTimezone(chatId, timezone); err != nil {
+		t.log.With(
+			slog.Int64("id", chatId),
+		).Warn("setting chat timezone", sl.Err(err))
+		t.plainResponse(chatId, errorText(err, errorResponse))
+		return
+	}
+	t.plainResponse(chatId, "Time in this chat is told in "+t.chat.ChatTimezone(chatId)+".")
+}
+
+// modelDescription lists the context window, capabilities and price of a model
+func modelDescription(spec core.ModelSpec) string {
+	var parts []string
+	if spec.Context > 0 {
+		parts = append(parts, fmt.Sprintf("%dk context", spec.Context/1000))
+	}
+	if spec.Vision {
+		parts = append(parts, "pictures")
+	}
+	if spec.Tools {
+		parts = append(parts, "tools")
+	}
+	if spec.Price.Prompt > 0 || spec.Price.Completion > 0 {
+		parts = append(parts, fmt.Sprintf("$%.2f/$%.2f per 1M tokens", spec.Price.Prompt, spec.Price.Completion))
+	}
+	return strings.Join(parts, ", ")
+}
+
+var imageOptionNames = []string{"--style", "--size", "--quality", "--n"}
+
+// parseImageOptions splits /imagine arguments into --option value pairs and the prompt
+func parseImageOptions(arguments string) (core.ImageOptions, error) {
+	var options core.ImageOptions
+	var prompt []string
+	words := strings.Fields(arguments)
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		if !strings.HasPrefix(word, "--") {
+			prompt = append(prompt, word)
+			continue
+		}
+		name, value, found := strings.Cut(strings.TrimPrefix(word, "--"), "=")
+		if !found {
+			if i+1 == len(words) {
+				return options, &core.OptionError{Option: "option", Value: word + " without a value", Allowed: imageOptionNames}
+			}
+			i++
+			value = words[i]
+		}
+		switch strings.ToLower(name) {
+		case "style":
+			options.Style = value
+		case "size":
+			options.Size = strings.ToLower(value)
+		case "quality":
+			options.Quality = strings.ToLower(value)
+		case "n":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return options, &core.OptionError{Option: "n", Value: value, Allowed: []string{"a number"}}
+			}
+			options.N = n
+		default:
+			return options, &core.OptionError{Option: "option", Value: "--" + name, Allowed: imageOptionNames}
+		}
+	}
+	options.Prompt = strings.Join(prompt, " ")
+	return options, nil
+}
+
+// SendImages uploads generated images and records them in the gallery
+func (t *TgBot) SendImages(chatId, userId int64, images []core.GeneratedImage) {
+	t.sendChatAction(chatId, tgbotapi.ChatUploadPhoto)
+	for i, fileId := range t.sendImages(chatId, images) {
+		t.chat.RecordImage(chatId, userId, fileId, "", core.ImageGenerate, images[i])
+	}
+}
+
+// sendImages uploads generated images, several of them as one album, and returns their Telegram file IDs
+// in the order of images, empty for images that were not sent
+func (t *TgBot) sendImages(chatId int64, images []core.GeneratedImage) []string {
+	if len(images) > 1 {
+		fileIds, err := t.sendMediaGroup(chatId, images)
+		if err == nil {
+			return fileIds
+		}
+		t.log.With(
+			slog.Int64("id", chatId),
+			slog.Int("n", len(images)),
+		).Error("sending album", sl.Err(err))
+		// Fallback: send images one by one
+	}
+	var fileIds []string
+	for i, image := range images {
+		msg := tgbotapi.NewPhotoUpload(chatId, tgbotapi.FileBytes{Name: imageFileName(i, image.Data), Bytes: image.Data})
+		msg.Caption = imageCaption(image.RevisedPrompt)
+		sent, err := t.api.Send(msg)
+		if err != nil {
+			t.log.With(
+				slog.Int64("id", chatId),
+				slog.String("path", image.Path),
+			).Error("uploading image", sl.Err(err))
+			t.plainResponse(chatId, imageErrorResponse)
+		}
+		fileIds = append(fileIds, photoFileId(&sent))
+	}
+	return fileIds
+}
+
+// sendMediaGroup uploads images as an album; the library sends albums only by URL or file ID
+func (t *TgBot) sendMediaGroup(chatId int64, images []core.GeneratedImage) ([]string, error) {
+	if len(images) > maxMediaGroup {
+		images = images[:maxMediaGroup]
+	}
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	media := make([]tgbotapi.InputMediaPhoto, 0, len(images))
+	for i, image := range images {
+		name := fmt.Sprintf("photo%d", i)
+		media = append(media, tgbotapi.InputMediaPhoto{
+			Type:    "photo",
+			Media:   "attach://" + name,
+			Caption: imageCaption(image.RevisedPrompt),
+		})
+		part, err := writer.CreateFormFile(name, imageFileName(i, image.Data))
+		if err != nil {
+			return nil, err
+		}
+		if _, err = part.Write(image.Data); err != nil {
+			return nil, err
+		}
+	}
+	mediaJSON, err := json.Marshal(media)
+	if err != nil {
+		return nil, err
+	}
+	_ = writer.WriteField("chat_id", strconv.FormatInt(chatId, 10))
+	_ = writer.WriteField("media", string(mediaJSON))
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf(tgbotapi.APIEndpoint, t.api.Token, "sendMediaGroup")
+	resp, err := t.api.Client.Post(url, writer.FormDataContentType(), &body)
+	if err != nil {
+		// the error contains the URL with the bot token
+		return nil, errors.New("sendMediaGroup request failed")
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var apiResp tgbotapi.APIResponse
+	if err = json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	if !apiResp.Ok {
+		return nil, fmt.Errorf("sendMediaGroup: %d %s", apiResp.ErrorCode, apiResp.Description)
+	}
+	var sent []tgbotapi.Message
+	if err = json.Unmarshal(apiResp.Result, &sent); err != nil {
+		return nil, fmt.Errorf("decoding messages: %w", err)
+	}
+	fileIds := make([]string, 0, len(sent))
+	for i := range sent {
+		fileIds = append(fileIds, photoFileId(&sent[i]))
+	}
+	return fileIds, nil
+}
+
+// imageCaption shows the prompt the model actually drew, cut to the Telegram caption limit
+func imageCaption(revisedPrompt string) string {
+	caption := []rune(revisedPrompt)
+	if len(caption) > maxCaption {
+		return string(caption[:maxCaption-1]) + "…"
+	}
+	return revisedPrompt
+}
+
+// imageFileName names the upload by its format, Telegram relies on the extension
+func imageFileName(i int, data []byte) string {
+	switch http.DetectContentType(data) {
+	case "image/jpeg":
+		return fmt.Sprintf("image%d.jpg", i)
+	case "image/webp":
+		return fmt.Sprintf("image%d.webp", i)
+	default:
+		return fmt.Sprintf("image%d.png", i)
+	}
+}
+
+// SendImageEdit redraws the picture by the instruction and sends the result
+func (t *TgBot) SendImageEdit(chatId, userId int64, fileId, maskFileId, instruction string) {
+	t.sendChatAction(chatId, tgbotapi.ChatUploadPhoto)
+	image, err := t.chat.EditImage(chatId, userId, fileId, maskFileId, instruction)
+	t.sendDerivedImage(chatId, userId, fileId, core.ImageEdit, image, err)
+}
+
+// SendImageVariation sends a variation of the picture
+func (t *TgBot) SendImageVariation(chatId, userId int64, fileId string) {
+	t.sendChatAction(chatId, tgbotapi.ChatUploadPhoto)
+	image, err := t.chat.VaryImage(chatId, userId, fileId)
+	t.sendDerivedImage(chatId, userId, fileId, core.ImageVariation, image, err)
+}
+
+// sendDerivedImage uploads an edited or varied image and records which picture it came from
+func (t *TgBot) sendDerivedImage(chatId, userId int64, parentFileId, operation string, image core.GeneratedImage, err error) {
 	if err != nil {
 		t.log.With(
 			slog.Int64("id", chatId),
-			slog.String("url", imageURL),
-		).Error("sending image", sl.Err(err))
-		// Fallback: send the URL as text
-		t.plainResponse(chatId, "Generated image: "+imageURL)
+			slog.String("operation", operation),
+		).Error("deriving image", sl.Err(err))
+		t.plainResponse(chatId, imageErrorText(err))
+		return
 	}
+
+	msg := tgbotapi.NewPhotoUpload(chatId, tgbotapi.FileBytes{Name: imageFileName(0, image.Data), Bytes: image.Data})
+	sent, err := t.api.Send(msg)
+	if err != nil {
+		t.log.With(
+			slog.Int64("id", chatId),
+		).Error("uploading image", sl.Err(err))
+		t.plainResponse(chatId, imageErrorResponse)
+		return
+	}
+	t.chat.RecordImage(chatId, userId, photoFileId(&sent), parentFileId, operation, image)
 }
 
 func (t *TgBot) plainResponse(chatId int64, text string) {
+	if text == "" {
+		// nothing to say, the answer was a picture
+		return
+	}
 
-	// ChatGPT uses ** for bold text, so we need to replace it
-	text = strings.ReplaceAll(text, "**", "*")
-	text = strings.ReplaceAll(text, "![", "[")
+	text = prepareMarkdown(text)
 
 	// Send the response back to the user
 	sanitized := sanitize(text)
@@ -307,6 +1131,89 @@ func (t *TgBot) plainResponse(chatId int64, text string) {
 	}
 }
 
+func (t *TgBot) sendUsage(chatId, userId int64) {
+	today, month, err := t.chat.GetUsage(userId)
+	if err != nil {
+		t.log.With(
+			slog.Int64("id", chatId),
+		).Error("getting usage", sl.Err(err))
+		t.plainResponse(chatId, errorResponse)
+		return
+	}
+	text := "Usage today:\n" + formatUsage(today) + "\n\nUsage this month:\n" + formatUsage(month)
+	t.plainResponse(chatId, text)
+}
+
+func formatUsage(u core.UsageTotals) string {
+	return fmt.Sprintf("requests: %d\ntokens: %d (prompt %d, completion %d)\nimages: %d\ncost: $%.4f",
+		u.Requests, u.Tokens(), u.PromptTokens, u.CompletionTokens, u.Images, u.Cost)
+}
+
+func quotaText(e *core.QuotaError) string {
+	who := "Your"
+	if e.Scope == "chat" {
+		who = "This chat's"
+	}
+	text := fmt.Sprintf("%s %s %s limit is used up (%d of %d). It resets on %s.",
+		who, e.Period, e.Resource, e.Used, e.Limit, e.ResetAt.Format("Jan 2 15:04"))
+	if e.Used < e.Limit {
+		text = fmt.Sprintf("%s %s %s limit has %d left, not enough for %d. It resets on %s.",
+			who, e.Period, e.Resource, e.Limit-e.Used, e.Requested, e.ResetAt.Format("Jan 2 15:04"))
+	}
+
+	var left []string
+	for _, l := range []struct {
+		name  string
+		value int
+	}{
+		{"tokens today", e.Left.DailyTokens},
+		{"tokens this month", e.Left.MonthlyTokens},
+		{"images today", e.Left.DailyImages},
+		{"images this month", e.Left.MonthlyImages},
+	} {
+		if l.value >= 0 {
+			left = append(left, fmt.Sprintf("%d %s", l.value, l.name))
+		}
+	}
+	if len(left) > 0 {
+		text += "\nLeft: " + strings.Join(left, ", ") + "."
+	}
+	return text
+}
+
+// photoFileId returns the largest size of a photo, or the file ID of an image sent as a document
+func photoFileId(message *tgbotapi.Message) string {
+	if message == nil {
+		return ""
+	}
+	if message.Photo != nil && len(*message.Photo) > 0 {
+		photos := *message.Photo
+		return photos[len(photos)-1].FileID
+	}
+	if message.Document != nil && strings.HasPrefix(message.Document.MimeType, "image/") {
+		return message.Document.FileID
+	}
+	return ""
+}
+
+// captionCommand parses a command at the start of a caption, like Message.Command does for text
+func captionCommand(caption string) (command, arguments string) {
+	if !strings.HasPrefix(caption, "/") {
+		return "", ""
+	}
+	command, arguments, _ = strings.Cut(caption[1:], " ")
+	command, _, _ = strings.Cut(command, "@")
+	return command, strings.TrimSpace(arguments)
+}
+
+// senderId returns the user who sent the message, or the chat for messages without a sender
+func senderId(message *tgbotapi.Message) int64 {
+	if message.From != nil {
+		return int64(message.From.ID)
+	}
+	return message.Chat.ID
+}
+
 // detect if we are mentioned in the message
 func (t *TgBot) isMentioned(text string) bool {
 	if t.botUsername != "" {
@@ -323,6 +1230,14 @@ func (t *TgBot) isReplyToBot(message *tgbotapi.Message) bool {
 	return false
 }
 
+// prepareMarkdown converts model markdown to the subset Telegram understands
+func prepareMarkdown(text string) string {
+	// ChatGPT uses ** for bold text, so we need to replace it
+	text = strings.ReplaceAll(text, "**", "*")
+	text = strings.ReplaceAll(text, "![", "[")
+	return text
+}
+
 func sanitize(input string) string {
 	var result strings.Builder
 	// Reserved chars for MarkdownV2 (excluding backtick which we handle specially)
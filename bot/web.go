@@ -0,0 +1,37 @@
+package bot
+
+import (
+	"Brainy/lib/sl"
+	"log/slog"
+	"time"
+)
+
+// sendPageSummary handles /tldr, the page is read and summarized while the bot shows typing status
+func (t *TgBot) sendPageSummary(chatId, userId int64, pageURL string) {
+	t.sendChatAction(chatId, "typing")
+	stopTyping := make(chan bool)
+	go func() {
+		ticker := time.NewTicker(4 * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				t.sendChatAction(chatId, "typing")
+			case <-stopTyping:
+				return
+			}
+		}
+	}()
+
+	summary, err := t.chat.SummarizePage(chatId, userId, pageURL)
+	close(stopTyping)
+	if err != nil {
+		t.log.With(
+			slog.Int64("id", chatId),
+			slog.String("url", pageURL),
+		).Error("summarizing page", sl.Err(err))
+		summary = errorText(err, "Sorry, I couldn't read this page.")
+	}
+	t.plainResponse(chatId, summary)
+}
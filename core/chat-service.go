@@ -1,8 +1,70 @@
 package core
 
+// ChatService answers messages; chatId identifies the conversation and userId the person who sent
+// the message, they are equal in private chats
 type ChatService interface {
-	GetResponse(userId int64, prompt string) (string, error)
-	GenerateImage(userId int64, prompt string) (string, error)
-	DetectImageIntent(question string) (bool, string)
-	ClearContext(userId int64)
+	GetResponse(chatId, userId int64, prompt string) (string, error)
+	// GetPhotoResponse answers a question about a picture given by its Telegram file ID
+	GetPhotoResponse(chatId, userId int64, fileId, caption string) (string, error)
+	// Transcribe converts a voice note or audio file to text, the file name extension tells the format
+	Transcribe(chatId, userId int64, fileId, fileName string) (string, error)
+	// Synthesize converts text to speech in the configured audio format
+	Synthesize(chatId, userId int64, text string) ([]byte, error)
+	VoiceReplies(chatId int64) bool
+	SetVoiceReplies(chatId int64, enabled bool) error
+	// GenerateImage returns generated images, options are validated against the image model
+	GenerateImage(chatId, userId int64, options ImageOptions) ([]GeneratedImage, error)
+	ImageStyles() []string
+	ImageStyle(chatId int64) string
+	SetImageStyle(chatId int64, style string) error
+	// EditImage redraws a picture by the instruction, maskFileId is optional
+	EditImage(chatId, userId int64, fileId, maskFileId, instruction string) (GeneratedImage, error)
+	VaryImage(chatId, userId int64, fileId string) (GeneratedImage, error)
+	// RecordImage stores an image sent by the bot and the image it was derived from
+	RecordImage(chatId, userId int64, fileId, parentFileId, operation string, image GeneratedImage)
+	// ListImages returns recent images of the user, newest first
+	ListImages(userId int64, limit int) ([]GalleryImage, error)
+	// GalleryImage returns an image from the history by its ID, nil if it is unknown
+	GalleryImage(id string) (*GalleryImage, error)
+	// DetectImageIntent tells whether the message asks for a picture and returns the prompt
+	DetectImageIntent(chatId, userId int64, question string) (bool, string)
+	ImageIntent(chatId int64) bool
+	SetImageIntent(chatId int64, enabled bool) error
+	// Models returns the models a chat may choose for answers
+	Models() []string
+	ChatModel(chatId int64) string
+	SetChatModel(chatId int64, model string) error
+	// ChatTimezone returns the IANA timezone of the chat used by the time tool
+	ChatTimezone(chatId int64) string
+	// SetChatTimezone sets the timezone of the chat, an empty name returns to the configured one
+	SetChatTimezone(chatId int64, timezone string) error
+	// AddDocument extracts, chunks and embeds a text, Markdown, CSV or PDF file as a knowledge source of the chat;
+	// size is the file size reported by Telegram, zero if unknown
+	AddDocument(chatId, userId int64, fileId, fileName string, size int) (Document, error)
+	ListDocuments(chatId int64) ([]Document, error)
+	// RemoveDocument removes a document of the chat, returns false if it is unknown
+	RemoveDocument(chatId int64, id string) (bool, error)
+	// SummarizePage reads a web page and returns its short summary, which joins the dialog context
+	SummarizePage(chatId, userId int64, pageURL string) (string, error)
+	ModerationReport(chatId int64) (ModerationReport, error)
+	// SetModerationThreshold sets the score of a category that blocks content in the chat, a negative one resets it
+	SetModerationThreshold(chatId int64, category string, threshold float64) error
+	ClearContext(chatId int64)
+	GetUsage(userId int64) (today, month UsageTotals, err error)
+}
+
+// FileLoader downloads files sent to the bot by their Telegram file ID
+type FileLoader interface {
+	DownloadFile(fileId string) ([]byte, error)
+}
+
+// ImageSender delivers pictures the model decided to draw while answering a message
+type ImageSender interface {
+	SendImages(chatId, userId int64, images []GeneratedImage)
+}
+
+// StreamingChatService is implemented by chat services that can report partial answers while generating
+type StreamingChatService interface {
+	ChatService
+	GetResponseStream(chatId, userId int64, prompt string, onUpdate func(partial string)) (string, error)
 }
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"github.com/ilyakaznacheev/cleanenv"
 	"log"
+	"sort"
+	"strings"
 	"sync"
 )
 
@@ -13,7 +15,110 @@ type Config struct {
 	OpenAIApiKey   string `yaml:"openai_api_key" env-default:""`
 	Username       string `yaml:"username" env-default:""`
 	Model          string `yaml:"model" env-default:"gpt-4.1-mini"`
-	Mongo          struct {
+	VisionModel    string `yaml:"vision_model" env-default:""` // for pictures, empty to use model
+	ContextTokens  int    `yaml:"context_tokens" env-default:"8000"`
+	Timezone       string `yaml:"timezone" env-default:"UTC"` // default timezone of users
+	Models         struct {
+		Chat      string   `yaml:"chat" env-default:""` // empty to use model
+		Classify  string   `yaml:"classify" env-default:""`
+		Analysis  string   `yaml:"analysis" env-default:""`
+		Summary   string   `yaml:"summary" env-default:""`
+		Translate string   `yaml:"translate" env-default:""`
+		Fallback  []string `yaml:"fallback"` // tried in order on server, quota and unknown-model errors
+		// Registry lists models chats can choose with /model and what they support, by model name or prefix
+		Registry    map[string]ModelSpec `yaml:"registry"`
+		Temperature float64              `yaml:"temperature" env-default:"0.7"` // for models that take it
+	} `yaml:"models"`
+	Summary struct {
+		Enabled   bool `yaml:"enabled" env-default:"true"`
+		Threshold int  `yaml:"threshold" env-default:"6000"` // context tokens that start summarization
+		Keep      int  `yaml:"keep" env-default:"2000"`      // tokens of recent turns kept verbatim
+	} `yaml:"summary"`
+	Memory struct {
+		Enabled   bool    `yaml:"enabled" env-default:"true"`
+		Model     string  `yaml:"model" env-default:"text-embedding-3-small"`
+		TopK      int     `yaml:"top_k" env-default:"5"`       // snippets recalled for a message
+		Budget    int     `yaml:"budget" env-default:"600"`    // prompt tokens of recalled snippets
+		MinScore  float64 `yaml:"min_score" env-default:"0.3"` // cosine similarity of a relevant snippet
+		MinLength int     `yaml:"min_length" env-default:"12"` // shorter messages like "ok, thanks" are not remembered
+		Window    int     `yaml:"window" env-default:"5000"`   // newest messages of the chat compared with a question, 0 for all
+	} `yaml:"memory"`
+	Documents struct {
+		Enabled     bool    `yaml:"enabled" env-default:"true"`
+		ChatLimit   int     `yaml:"chat_limit" env-default:"20971520"` // bytes of files attached to a chat
+		ChunkTokens int     `yaml:"chunk_tokens" env-default:"400"`
+		TopK        int     `yaml:"top_k" env-default:"4"`       // excerpts retrieved for a message
+		Budget      int     `yaml:"budget" env-default:"2000"`   // prompt tokens of excerpts
+		MinScore    float64 `yaml:"min_score" env-default:"0.2"` // cosine similarity of a relevant excerpt
+	} `yaml:"documents"`
+	Web struct {
+		Enabled   bool `yaml:"enabled" env-default:"true"`
+		Timeout   int  `yaml:"timeout" env-default:"15"`        // seconds to download a page
+		MaxBytes  int  `yaml:"max_bytes" env-default:"2097152"` // longer pages are cut
+		MaxTokens int  `yaml:"max_tokens" env-default:"3000"`   // tokens of page text given to the model
+		Links     int  `yaml:"links" env-default:"2"`           // linked pages read for a message, 0 for /tldr only
+		// hosts with their subdomains, IP addresses or CIDR ranges; when the allow list is set only its entries are
+		// fetched, internal addresses included, otherwise any public address not denied
+		Allow []string `yaml:"allow"`
+		Deny  []string `yaml:"deny"`
+	} `yaml:"web"`
+	Moderation struct {
+		Enabled    bool                `yaml:"enabled" env-default:"true"`
+		Endpoint   bool                `yaml:"endpoint" env-default:"true"` // ask the /moderations endpoint besides keywords
+		Model      string              `yaml:"model" env-default:"omni-moderation-latest"`
+		Threshold  float64             `yaml:"threshold" env-default:"0.5"`     // category score that blocks content
+		Thresholds map[string]float64  `yaml:"thresholds"`                      // by category, chats may override them
+		Keywords   map[string][]string `yaml:"keywords"`                        // words and phrases by category, they always block
+		FailClosed bool                `yaml:"fail_closed" env-default:"false"` // block content the endpoint failed to check
+	} `yaml:"moderation"`
+	Tools struct {
+		Enabled   bool `yaml:"enabled" env-default:"true"`
+		MaxRounds int  `yaml:"max_rounds" env-default:"5"` // tool-call rounds before the model must answer
+	} `yaml:"tools"`
+	Provider struct {
+		BaseURL     string            `yaml:"base_url" env-default:"https://api.openai.com/v1"`
+		Timeout     int               `yaml:"timeout" env-default:"120"` // seconds
+		MaxAttempts int               `yaml:"max_attempts" env-default:"4"`
+		Headers     map[string]string `yaml:"headers"`
+	} `yaml:"provider"`
+	Transcription struct {
+		Enabled        bool   `yaml:"enabled" env-default:"true"`
+		BaseURL        string `yaml:"base_url" env-default:""` // empty to use the provider base URL
+		Model          string `yaml:"model" env-default:"whisper-1"`
+		Language       string `yaml:"language" env-default:""` // ISO-639-1 hint, empty to detect
+		ShowTranscript bool   `yaml:"show_transcript" env-default:"true"`
+	} `yaml:"transcription"`
+	Speech struct {
+		Enabled bool   `yaml:"enabled" env-default:"true"`
+		Model   string `yaml:"model" env-default:"gpt-4o-mini-tts"`
+		Voice   string `yaml:"voice" env-default:"alloy"`
+		Format  string `yaml:"format" env-default:"opus"` // opus is sent as a voice note, other formats as audio
+	} `yaml:"speech"`
+	Images struct {
+		Model          string            `yaml:"model" env-default:"dall-e-3"`
+		Size           string            `yaml:"size" env-default:"1024x1024"`
+		Quality        string            `yaml:"quality" env-default:""` // empty for the model default
+		Style          string            `yaml:"style" env-default:"none"`
+		Styles         map[string]string `yaml:"styles"`                  // preset name to prompt suffix, "none" adds nothing
+		SaveDir        string            `yaml:"save_dir" env-default:""` // keep copies of images, empty to disable
+		EditModel      string            `yaml:"edit_model" env-default:"gpt-image-1"`
+		VariationModel string            `yaml:"variation_model" env-default:"dall-e-2"`
+	} `yaml:"images"`
+	ImageIntent struct {
+		Enabled    bool `yaml:"enabled" env-default:"true"`    // look for image requests in messages, chats can turn it off
+		Classifier bool `yaml:"classifier" env-default:"true"` // reject messages without drawing or making words locally, ask the model about the rest
+		Tool       bool `yaml:"tool" env-default:"true"`       // let the chat model draw with a tool instead of a detection call
+		LogEvery   int  `yaml:"log_every" env-default:"100"`   // decisions between logged counts
+	} `yaml:"image_intent"`
+	Prices map[string]Price `yaml:"prices"` // by model name or prefix
+	Admins []int64          `yaml:"admins"` // user IDs exempt from quotas, they manage /moderation
+	Quotas struct {
+		Enabled bool                  `yaml:"enabled" env-default:"false"`
+		Default QuotaLimits           `yaml:"default"` // for every user and group chat
+		Users   map[int64]QuotaLimits `yaml:"users"`   // overrides by user ID
+		Chats   map[int64]QuotaLimits `yaml:"chats"`   // overrides by group chat ID
+	} `yaml:"quotas"`
+	Mongo struct {
 		Enabled  bool   `yaml:"enabled" env-default:"false"`
 		Host     string `yaml:"host" env-default:"127.0.0.1"`
 		Port     string `yaml:"port" env-default:"27017"`
@@ -23,6 +128,154 @@ type Config struct {
 	}
 }
 
+// Tasks that can have their own model
+const (
+	TaskChat      = "chat"      // answers to users
+	TaskClassify  = "classify"  // image intent detection
+	TaskAnalysis  = "analysis"  // preferences analysis
+	TaskSummary   = "summary"   // condensing old turns of the context
+	TaskTranslate = "translate" // dictionary articles of /cat and /cas
+)
+
+// ModelFor returns the model configured for the task, or the main model
+func (c *Config) ModelFor(task string) string {
+	var model string
+	switch task {
+	case TaskChat:
+		model = c.Models.Chat
+	case TaskClassify:
+		model = c.Models.Classify
+	case TaskAnalysis:
+		model = c.Models.Analysis
+	case TaskSummary:
+		model = c.Models.Summary
+	case TaskTranslate:
+		model = c.Models.Translate
+	}
+	if model == "" {
+		return c.Model
+	}
+	return model
+}
+
+// ImageStyle returns the prompt suffix of a style preset, the "none" preset always exists
+func (c *Config) ImageStyle(name string) (string, bool) {
+	if name == ImageStyleNone {
+		return "", true
+	}
+	suffix, ok := c.Images.Styles[name]
+	return suffix, ok
+}
+
+// ImageStyleNames returns names of all style presets
+func (c *Config) ImageStyleNames() []string {
+	names := []string{ImageStyleNone}
+	for name := range c.Images.Styles {
+		if name != ImageStyleNone {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// ModelSpec describes limits and capabilities of a model; requests are adapted to it
+type ModelSpec struct {
+	Context     int   `yaml:"context"`    // context window in tokens
+	MaxOutput   int   `yaml:"max_output"` // answer token limit sent with requests, zero for the API default
+	Price       Price `yaml:"price"`
+	Vision      bool  `yaml:"vision"`
+	Tools       bool  `yaml:"tools"`
+	JSONSchema  bool  `yaml:"json_schema"`
+	Temperature bool  `yaml:"temperature"` // reasoning models accept only the default temperature
+	MaxTokens   bool  `yaml:"max_tokens"`  // takes the legacy max_tokens instead of max_completion_tokens
+}
+
+// ModelSpec returns the registry entry of the model, matching the longest prefix like PriceFor
+func (c *Config) ModelSpec(model string) (ModelSpec, bool) {
+	if spec, ok := c.Models.Registry[model]; ok {
+		return spec, true
+	}
+	var found ModelSpec
+	matched := 0
+	for name, spec := range c.Models.Registry {
+		if len(name) > matched && strings.HasPrefix(model, name) {
+			found, matched = spec, len(name)
+		}
+	}
+	return found, matched > 0
+}
+
+// ModelNames returns names of the registry models
+func (c *Config) ModelNames() []string {
+	names := make([]string, 0, len(c.Models.Registry))
+	for name := range c.Models.Registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Price of a model in USD: tokens are priced per million, images per piece
+type Price struct {
+	Prompt     float64 `yaml:"prompt"`
+	Completion float64 `yaml:"completion"`
+	Image      float64 `yaml:"image"`
+}
+
+// PriceFor returns the price of the model from the registry or the prices table, matching the longest
+// configured prefix so that dated model versions like gpt-4o-2024-08-06 use the gpt-4o price
+func (c *Config) PriceFor(model string) (Price, bool) {
+	if spec, ok := c.ModelSpec(model); ok && spec.Price != (Price{}) {
+		return spec.Price, true
+	}
+	if price, ok := c.Prices[model]; ok {
+		return price, true
+	}
+	var found Price
+	matched := 0
+	for name, price := range c.Prices {
+		if len(name) > matched && strings.HasPrefix(model, name) {
+			found, matched = price, len(name)
+		}
+	}
+	return found, matched > 0
+}
+
+// QuotaLimits are spending limits for a period, zero means unlimited
+type QuotaLimits struct {
+	DailyTokens   int `yaml:"daily_tokens"`
+	MonthlyTokens int `yaml:"monthly_tokens"`
+	DailyImages   int `yaml:"daily_images"`
+	MonthlyImages int `yaml:"monthly_images"`
+}
+
+// IsAdmin reports whether the user is listed in admins
+func (c *Config) IsAdmin(userId int64) bool {
+	for _, id := range c.Admins {
+		if id == userId {
+			return true
+		}
+	}
+	return false
+}
+
+// UserLimits returns quota limits of a user, an override replaces the default entirely
+func (c *Config) UserLimits(userId int64) QuotaLimits {
+	if limits, ok := c.Quotas.Users[userId]; ok {
+		return limits
+	}
+	return c.Quotas.Default
+}
+
+// ChatLimits returns quota limits of a group chat, an override replaces the default entirely
+func (c *Config) ChatLimits(chatId int64) QuotaLimits {
+	if limits, ok := c.Quotas.Chats[chatId]; ok {
+		return limits
+	}
+	return c.Quotas.Default
+}
+
 var instance *Config
 var once sync.Once
 
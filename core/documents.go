@@ -0,0 +1,51 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Document formats
+const (
+	DocumentText = "text" // plain text and Markdown
+	DocumentCSV  = "csv"
+	DocumentPDF  = "pdf"
+)
+
+var documentFormats = map[string]string{
+	".txt":      DocumentText,
+	".text":     DocumentText,
+	".md":       DocumentText,
+	".markdown": DocumentText,
+	".csv":      DocumentCSV,
+	".pdf":      DocumentPDF,
+}
+
+// DocumentFormat returns the format of a document by the extension of its file name, empty if unsupported
+func DocumentFormat(fileName string) string {
+	return documentFormats[strings.ToLower(filepath.Ext(fileName))]
+}
+
+// Document is a file attached to a chat as a knowledge source
+type Document struct {
+	Id        string
+	Name      string
+	Size      int // bytes of the file
+	Pages     int // zero for text files
+	Chunks    int
+	CreatedAt time.Time
+}
+
+// DocumentLimitError is returned when a document does not fit into the size limit of the chat
+type DocumentLimitError struct {
+	Name  string
+	Size  int // bytes of the document
+	Used  int // bytes of documents already attached
+	Limit int
+}
+
+func (e *DocumentLimitError) Error() string {
+	return fmt.Sprintf("document %s of %d bytes exceeds the chat limit: %d of %d bytes used", e.Name, e.Size, e.Used, e.Limit)
+}
@@ -0,0 +1,27 @@
+package core
+
+import "errors"
+
+// Error classes reported by chat services, check them with errors.Is
+var (
+	ErrRateLimited    = errors.New("rate limited")
+	ErrQuotaExceeded  = errors.New("quota exceeded")
+	ErrContentPolicy  = errors.New("content policy violation")
+	ErrContextLength  = errors.New("context length exceeded")
+	ErrServer         = errors.New("server error")
+	ErrUnknownModel   = errors.New("unknown model")
+	ErrAuthentication = errors.New("authentication failed")
+	ErrInvalidRequest = errors.New("invalid request")
+	// ErrUnsupportedDocument is returned for files that are not text, Markdown, CSV or PDF
+	ErrUnsupportedDocument = errors.New("unsupported document")
+	// ErrEmptyDocument is returned for documents without extractable text, like scanned PDFs
+	ErrEmptyDocument = errors.New("document has no text")
+	// ErrForbiddenURL is returned for links to internal addresses and hosts outside the allow and deny lists
+	ErrForbiddenURL = errors.New("address is not allowed")
+	// ErrUnreadablePage is returned for web pages without readable text, like images or archives
+	ErrUnreadablePage = errors.New("page has no readable text")
+	// ErrInvalidMask is returned for edit masks that are not a PNG with a transparent area
+	ErrInvalidMask = errors.New("invalid mask")
+	// ErrUnknownTimezone is returned for timezone names missing from the IANA database
+	ErrUnknownTimezone = errors.New("unknown timezone")
+)
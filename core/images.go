@@ -0,0 +1,60 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Operations that produced an image sent by the bot
+const (
+	ImageGenerate  = "generate"
+	ImageEdit      = "edit"
+	ImageVariation = "variation"
+)
+
+// GeneratedImage is an image made by the model
+type GeneratedImage struct {
+	Data          []byte
+	Prompt        string // prompt or instruction of the user, without the style
+	RevisedPrompt string // prompt the model actually used, if it rewrote it
+	Style         string
+	Model         string
+	Path          string // local copy, empty if images are not saved
+}
+
+// GalleryImage is an image from the history of a user
+type GalleryImage struct {
+	Id            string
+	FileId        string
+	Operation     string
+	Prompt        string
+	RevisedPrompt string
+	Style         string
+	Model         string
+	Path          string
+	CreatedAt     time.Time
+}
+
+// ImageStyleNone is the style preset that leaves prompts as they are
+const ImageStyleNone = "none"
+
+// ImageOptions are parameters of image generation, empty values take the chat and config defaults
+type ImageOptions struct {
+	Prompt  string
+	Style   string // name of a style preset
+	Size    string
+	Quality string
+	N       int
+}
+
+// OptionError reports an option value that is unknown or not supported by the image model
+type OptionError struct {
+	Option  string
+	Value   string
+	Allowed []string
+}
+
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("unsupported %s %q, allowed: %s", e.Option, e.Value, strings.Join(e.Allowed, ", "))
+}
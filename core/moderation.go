@@ -0,0 +1,55 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Kinds of moderated content
+const (
+	ModerationPrompt = "prompt" // messages and captions for the chat model
+	ModerationImage  = "image"  // descriptions of pictures to draw or edit
+	ModerationPage   = "page"   // text of web pages to summarize
+)
+
+// ModerationError is returned for content blocked by moderation; without categories the content could not be
+// checked and moderation is set to fail closed
+type ModerationError struct {
+	Kind       string
+	Categories []string
+}
+
+func (e *ModerationError) Error() string {
+	if len(e.Categories) == 0 {
+		return fmt.Sprintf("%s not checked by moderation", e.Kind)
+	}
+	return fmt.Sprintf("%s blocked by moderation: %s", e.Kind, strings.Join(e.Categories, ", "))
+}
+
+// Unwrap makes blocked content a content policy error
+func (e *ModerationError) Unwrap() error {
+	return ErrContentPolicy
+}
+
+// ModerationDecision is a blocked entry of the moderation audit log
+type ModerationDecision struct {
+	UserId     int64
+	Kind       string
+	Source     string // keywords or endpoint
+	Categories []string
+	Text       string
+	CreatedAt  time.Time
+}
+
+// ModerationReport describes moderation of a chat for its admins
+type ModerationReport struct {
+	Enabled        bool
+	Endpoint       bool               // the moderation endpoint is asked, not only the keyword policy
+	Threshold      float64            // default category threshold
+	Thresholds     map[string]float64 // by category from config
+	ChatThresholds map[string]float64 // set for the chat, they take precedence
+	Checked        int                // decisions of the last 30 days
+	Blocked        int
+	Recent         []ModerationDecision // latest blocked content, newest first
+}
@@ -0,0 +1,39 @@
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+// Quota resources
+const (
+	QuotaTokens = "tokens"
+	QuotaImages = "images"
+)
+
+// QuotaError is returned when a user or group chat has used up a spending limit
+type QuotaError struct {
+	Scope    string // "user" or "chat"
+	Resource string // QuotaTokens or QuotaImages
+	Period   string // "daily" or "monthly"
+	Limit    int
+	Used     int
+	// Requested is what the request needed, more than one for several images
+	Requested int
+	ResetAt   time.Time
+	// Left holds what is still available per resource and period, -1 when unlimited
+	Left QuotaLeft
+}
+
+// QuotaLeft is the remaining allowance, -1 means unlimited
+type QuotaLeft struct {
+	DailyTokens   int
+	MonthlyTokens int
+	DailyImages   int
+	MonthlyImages int
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s %s %s quota exceeded: used %d of %d, requested %d, resets at %s",
+		e.Scope, e.Period, e.Resource, e.Used, e.Limit, max(e.Requested, 1), e.ResetAt.Format(time.RFC3339))
+}
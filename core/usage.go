@@ -0,0 +1,16 @@
+package core
+
+// UsageTotals is the consumption of a user over a period
+type UsageTotals struct {
+	PromptTokens     int
+	CompletionTokens int
+	Images           int
+	Requests         int
+	// Cost in USD by the configured price table
+	Cost float64
+}
+
+// Tokens returns prompt and completion tokens together
+func (u UsageTotals) Tokens() int {
+	return u.PromptTokens + u.CompletionTokens
+}
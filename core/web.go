@@ -0,0 +1,32 @@
+package core
+
+import (
+	"regexp"
+	"strings"
+)
+
+var linkPattern = regexp.MustCompile("https?://[^\\s<>\"'`]+")
+
+// Links returns the distinct http and https links of the text in order, without trailing punctuation
+func Links(text string) []string {
+	var links []string
+	seen := make(map[string]bool)
+	for _, link := range linkPattern.FindAllString(text, -1) {
+		for {
+			trimmed := strings.TrimRight(link, ".,;:!?]}»")
+			// a closing parenthesis belongs to the link only when it opens one, like wiki/Go_(language)
+			if strings.HasSuffix(trimmed, ")") && strings.Count(trimmed, "(") < strings.Count(trimmed, ")") {
+				trimmed = trimmed[:len(trimmed)-1]
+			}
+			if trimmed == link {
+				break
+			}
+			link = trimmed
+		}
+		if !seen[link] && !strings.HasSuffix(link, "://") {
+			seen[link] = true
+			links = append(links, link)
+		}
+	}
+	return links
+}
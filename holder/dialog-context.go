@@ -3,6 +3,7 @@ package holder
 import (
 	"Brainy/storage"
 	"log"
+	"time"
 )
 
 // Message is an alias for storage.Message for backward compatibility
@@ -13,6 +14,7 @@ type DialogContext = storage.DialogContext
 
 type ContextManager struct {
 	storage storage.ContextStorage
+	count   func(userId int64, text string) int
 }
 
 func NewContextManager(store storage.ContextStorage) *ContextManager {
@@ -30,7 +32,18 @@ func (cm *ContextManager) GetUserContext(userId int64) *DialogContext {
 	return ctx
 }
 
+// SetTokenCounter counts the turns of each chat with its own model instead of the counter of the storage
+func (cm *ContextManager) SetTokenCounter(count func(userId int64, text string) int) {
+	cm.count = count
+}
+
 func (cm *ContextManager) UpdateUserContext(userId int64, message Message) {
+	if cm.count != nil && message.Tokens == 0 {
+		message.Tokens = max(cm.count(userId, message.Text), 1)
+		if message.Image != "" {
+			message.Tokens += storage.ImageTokens
+		}
+	}
 	if err := cm.storage.UpdateUserContext(userId, message); err != nil {
 		log.Printf("error updating user context: %v", err)
 	}
@@ -42,6 +55,12 @@ func (cm *ContextManager) SetTopic(userId int64, topic string) {
 	}
 }
 
+func (cm *ContextManager) SetSummary(userId, generation int64, summary string, until time.Time) {
+	if err := cm.storage.SetSummary(userId, generation, summary, until); err != nil {
+		log.Printf("error setting summary: %v", err)
+	}
+}
+
 func (cm *ContextManager) ClearUserContext(userId int64) {
 	if err := cm.storage.ClearUserContext(userId); err != nil {
 		log.Printf("error clearing user context: %v", err)
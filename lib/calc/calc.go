@@ -0,0 +1,298 @@
+// Package calc evaluates arithmetic expressions without access to anything but math.
+package calc
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// maximum nesting of parentheses, function calls, signs and powers
+	maxDepth = 64
+	// expressions come from the model, longer ones are not arithmetic people ask about
+	maxLength = 1000
+)
+
+var constants = map[string]float64{
+	"pi": math.Pi,
+	"e":  math.E,
+}
+
+var functions = map[string]struct {
+	args int // -1 for variadic
+	fn   func(args []float64) float64
+}{
+	"sqrt":  {1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"abs":   {1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"sin":   {1, func(a []float64) float64 { return math.Sin(a[0]) }},
+	"cos":   {1, func(a []float64) float64 { return math.Cos(a[0]) }},
+	"tan":   {1, func(a []float64) float64 { return math.Tan(a[0]) }},
+	"asin":  {1, func(a []float64) float64 { return math.Asin(a[0]) }},
+	"acos":  {1, func(a []float64) float64 { return math.Acos(a[0]) }},
+	"atan":  {1, func(a []float64) float64 { return math.Atan(a[0]) }},
+	"ln":    {1, func(a []float64) float64 { return math.Log(a[0]) }},
+	"log":   {1, func(a []float64) float64 { return math.Log10(a[0]) }},
+	"log2":  {1, func(a []float64) float64 { return math.Log2(a[0]) }},
+	"exp":   {1, func(a []float64) float64 { return math.Exp(a[0]) }},
+	"floor": {1, func(a []float64) float64 { return math.Floor(a[0]) }},
+	"ceil":  {1, func(a []float64) float64 { return math.Ceil(a[0]) }},
+	"round": {1, func(a []float64) float64 { return math.Round(a[0]) }},
+	"pow":   {2, func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+	"min": {-1, func(a []float64) float64 {
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Min(m, v)
+		}
+		return m
+	}},
+	"max": {-1, func(a []float64) float64 {
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Max(m, v)
+		}
+		return m
+	}},
+}
+
+// Eval evaluates an expression with + - * / % ^, parentheses, numbers, the constants pi and e
+// and functions like sqrt, sin, log or max
+func Eval(expression string) (float64, error) {
+	p := &parser{input: []rune(strings.ToLower(expression))}
+	if len(p.input) > maxLength {
+		return 0, fmt.Errorf("expression is longer than %d characters", maxLength)
+	}
+	value, err := p.expression()
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return 0, fmt.Errorf("unexpected %q at position %d", p.input[p.pos], p.pos+1)
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("result is not a finite number")
+	}
+	return value, nil
+}
+
+type parser struct {
+	input []rune
+	pos   int
+	depth int
+}
+
+func (p *parser) skipSpaces() {
+	for p.pos < len(p.input) && unicode.IsSpace(p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *parser) peek() rune {
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+// expression = term { ("+" | "-") term }
+func (p *parser) expression() (float64, error) {
+	value, err := p.term()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch p.peek() {
+		case '+':
+			p.pos++
+			right, err := p.term()
+			if err != nil {
+				return 0, err
+			}
+			value += right
+		case '-':
+			p.pos++
+			right, err := p.term()
+			if err != nil {
+				return 0, err
+			}
+			value -= right
+		default:
+			return value, nil
+		}
+	}
+}
+
+// term = unary { ("*" | "/" | "%") unary }
+func (p *parser) term() (float64, error) {
+	value, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := p.peek()
+		if op != '*' && op != '/' && op != '%' && op != '×' && op != '÷' {
+			return value, nil
+		}
+		p.pos++
+		right, err := p.unary()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case '*', '×':
+			value *= right
+		case '/', '÷':
+			if right == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			value /= right
+		case '%':
+			if right == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			value = math.Mod(value, right)
+		}
+	}
+}
+
+// unary = ("-" | "+") unary | power
+func (p *parser) unary() (float64, error) {
+	switch p.peek() {
+	case '-':
+		p.pos++
+		value, err := p.nested(p.unary)
+		return -value, err
+	case '+':
+		p.pos++
+		return p.nested(p.unary)
+	}
+	return p.power()
+}
+
+// power = primary [ "^" unary ], right associative
+func (p *parser) power() (float64, error) {
+	base, err := p.primary()
+	if err != nil {
+		return 0, err
+	}
+	if p.peek() == '^' {
+		p.pos++
+		exponent, err := p.nested(p.unary)
+		if err != nil {
+			return 0, err
+		}
+		return math.Pow(base, exponent), nil
+	}
+	return base, nil
+}
+
+// primary = number | constant | function "(" args ")" | "(" expression ")"
+func (p *parser) primary() (float64, error) {
+	r := p.peek()
+	switch {
+	case r == '(':
+		return p.nested(func() (float64, error) {
+			p.pos++
+			value, err := p.expression()
+			if err != nil {
+				return 0, err
+			}
+			if p.peek() != ')' {
+				return 0, fmt.Errorf("missing closing parenthesis")
+			}
+			p.pos++
+			return value, nil
+		})
+	case unicode.IsDigit(r) || r == '.':
+		return p.number()
+	case unicode.IsLetter(r):
+		return p.identifier()
+	case r == 0:
+		return 0, fmt.Errorf("unexpected end of expression")
+	}
+	return 0, fmt.Errorf("unexpected %q at position %d", r, p.pos+1)
+}
+
+func (p *parser) nested(f func() (float64, error)) (float64, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxDepth {
+		return 0, fmt.Errorf("expression is nested too deeply")
+	}
+	return f()
+}
+
+func (p *parser) number() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.input) && (unicode.IsDigit(p.input[p.pos]) || p.input[p.pos] == '.' || p.input[p.pos] == '_') {
+		p.pos++
+	}
+	// exponent part like 1.5e-3
+	if p.pos < len(p.input) && p.input[p.pos] == 'e' {
+		next := p.pos + 1
+		if next < len(p.input) && (p.input[next] == '+' || p.input[next] == '-') {
+			next++
+		}
+		if next < len(p.input) && unicode.IsDigit(p.input[next]) {
+			p.pos = next
+			for p.pos < len(p.input) && unicode.IsDigit(p.input[p.pos]) {
+				p.pos++
+			}
+		}
+	}
+	text := strings.ReplaceAll(string(p.input[start:p.pos]), "_", "")
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", text)
+	}
+	return value, nil
+}
+
+func (p *parser) identifier() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.input) && (unicode.IsLetter(p.input[p.pos]) || unicode.IsDigit(p.input[p.pos])) {
+		p.pos++
+	}
+	name := string(p.input[start:p.pos])
+
+	if p.peek() != '(' {
+		if value, ok := constants[name]; ok {
+			return value, nil
+		}
+		return 0, fmt.Errorf("unknown name %q", name)
+	}
+
+	f, ok := functions[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown function %q", name)
+	}
+	return p.nested(func() (float64, error) {
+		p.pos++ // (
+		var args []float64
+		if p.peek() != ')' {
+			for {
+				value, err := p.expression()
+				if err != nil {
+					return 0, err
+				}
+				args = append(args, value)
+				if p.peek() != ',' {
+					break
+				}
+				p.pos++
+			}
+		}
+		if p.peek() != ')' {
+			return 0, fmt.Errorf("missing closing parenthesis in %s()", name)
+		}
+		p.pos++
+		if (f.args >= 0 && len(args) != f.args) || len(args) == 0 {
+			return 0, fmt.Errorf("wrong number of arguments for %s()", name)
+		}
+		return f.fn(args), nil
+	})
+}
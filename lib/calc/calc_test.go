@@ -0,0 +1,91 @@
+package calc
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 / 4", 2.5},
+		{"10 % 4", 2},
+		{"7 × 6 ÷ 3", 14},
+		{"2 ^ 10", 1024},
+		{"2 ^ 3 ^ 2", 512},
+		{"-2 ^ 2", -4},
+		{"2 ^ -1", 0.5},
+		{"--3", 3},
+		{"+-+3", -3},
+		{"1_000 * 1.5e-3", 1.5},
+		{"sqrt(16) + abs(-2)", 6},
+		{"max(1, 5, 3) - min(4, 2)", 3},
+		{"pow(2, 8)", 256},
+		{"round(PI * 100)", 314},
+		{"ln(e)", 1},
+		{"log(1000)", 3},
+	}
+	for _, tt := range tests {
+		got, err := Eval(tt.expression)
+		if err != nil {
+			t.Errorf("Eval(%q) error: %v", tt.expression, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Eval(%q) = %v, want %v", tt.expression, got, tt.want)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		err        string
+	}{
+		{"empty", "", "unexpected end"},
+		{"dangling operator", "1 +", "unexpected end"},
+		{"unclosed parenthesis", "(1 + 2", "missing closing parenthesis"},
+		{"extra parenthesis", "1 + 2)", "unexpected"},
+		{"division by zero", "1 / (2 - 2)", "division by zero"},
+		{"modulo by zero", "1 % 0", "division by zero"},
+		{"unknown name", "x + 1", "unknown name"},
+		{"unknown function", "exec(1)", "unknown function"},
+		{"wrong arguments", "pow(2)", "wrong number of arguments"},
+		{"no arguments", "max()", "wrong number of arguments"},
+		{"invalid number", "1.2.3", "invalid number"},
+		{"not finite", "sqrt(-1)", "not a finite number"},
+		{"overflow", "10 ^ 400", "not a finite number"},
+		{"stray character", "2 $ 3", "unexpected"},
+		{"deep parentheses", strings.Repeat("(", 100) + "1" + strings.Repeat(")", 100), "nested too deeply"},
+		{"deep functions", strings.Repeat("abs(", 100) + "1" + strings.Repeat(")", 100), "nested too deeply"},
+		{"long minus chain", strings.Repeat("-", 500) + "1", "nested too deeply"},
+		{"long plus chain", strings.Repeat("+", 500) + "1", "nested too deeply"},
+		{"long power chain", strings.Repeat("2^", 400) + "2", "nested too deeply"},
+		{"too long", strings.Repeat("-", 100000) + "1", "longer than"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Eval(tt.expression)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Eval error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestEvalNestingLimit(t *testing.T) {
+	for _, expression := range []string{
+		strings.Repeat("(", maxDepth) + "1" + strings.Repeat(")", maxDepth),
+		strings.Repeat("-", maxDepth) + "1",
+	} {
+		if _, err := Eval(expression); err != nil {
+			t.Errorf("nesting of %d: %v", maxDepth, err)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Package imaging prepares pictures for image APIs with strict input requirements.
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+)
+
+// SquarePNG center-crops the picture to a square, scales it down to at most size pixels and encodes
+// it as RGBA PNG; the size is halved until the file fits into maxBytes
+func SquarePNG(data []byte, size, maxBytes int) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decoding picture: %w", err)
+	}
+	square, err := cropSquare(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if side := square.Bounds().Dx(); size > side {
+		size = side
+	}
+	for ; size >= 64; size /= 2 {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, scale(square, size)); err != nil {
+			return nil, fmt.Errorf("encoding picture: %w", err)
+		}
+		if buf.Len() <= maxBytes {
+			return buf.Bytes(), nil
+		}
+	}
+	return nil, fmt.Errorf("picture does not fit into %d bytes", maxBytes)
+}
+
+// CheckMask makes sure the edit mask is a PNG with a transparent area, the part of the picture to change
+func CheckMask(data []byte) error {
+	_, err := decodeMask(data)
+	return err
+}
+
+// SquareMask checks the edit mask and crops it like SquarePNG, scaled to the size of the prepared picture
+func SquareMask(mask, picture []byte) ([]byte, error) {
+	src, err := decodeMask(mask)
+	if err != nil {
+		return nil, err
+	}
+	config, err := png.DecodeConfig(bytes.NewReader(picture))
+	if err != nil {
+		return nil, fmt.Errorf("decoding picture: %w", err)
+	}
+	square, err := cropSquare(src)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, scale(square, config.Width)); err != nil {
+		return nil, fmt.Errorf("encoding mask: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeMask(data []byte) (image.Image, error) {
+	src, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("mask is not a PNG: %w", err)
+	}
+	bounds := src.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := src.At(x, y).RGBA(); a < 0xffff {
+				return src, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("mask has no transparent area")
+}
+
+// cropSquare cuts the largest centered square out of the picture
+func cropSquare(src image.Image) (*image.RGBA, error) {
+	bounds := src.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	if side == 0 {
+		return nil, fmt.Errorf("empty picture")
+	}
+	x0 := bounds.Min.X + (bounds.Dx()-side)/2
+	y0 := bounds.Min.Y + (bounds.Dy()-side)/2
+	square := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(square, square.Bounds(), src, image.Pt(x0, y0), draw.Src)
+	return square, nil
+}
+
+// scale resizes a square picture by averaging the source pixels covered by every target pixel,
+// a smaller one is enlarged by repeating its pixels
+func scale(src *image.RGBA, size int) *image.RGBA {
+	side := src.Bounds().Dx()
+	if size == side {
+		return src
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		sy0, sy1 := y*side/size, max((y+1)*side/size, y*side/size+1)
+		for x := 0; x < size; x++ {
+			sx0, sx1 := x*side/size, max((x+1)*side/size, x*side/size+1)
+			var r, g, b, a, n uint32
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					i := src.PixOffset(sx, sy)
+					r += uint32(src.Pix[i])
+					g += uint32(src.Pix[i+1])
+					b += uint32(src.Pix[i+2])
+					a += uint32(src.Pix[i+3])
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = uint8(r/n), uint8(g/n), uint8(b/n), uint8(a/n)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,137 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// picture of the size filled with the color, the rectangle is cut out transparent
+func picture(w, h int, fill color.Color, hole image.Rectangle) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if image.Pt(x, y).In(hole) {
+				img.Set(x, y, color.NRGBA{})
+			} else {
+				img.Set(x, y, fill)
+			}
+		}
+	}
+	return img
+}
+
+func TestSquarePNG(t *testing.T) {
+	tests := []struct {
+		name string
+		w, h int
+		size int
+		want int
+	}{
+		{"landscape scaled down", 400, 200, 128, 128},
+		{"portrait scaled down", 150, 300, 100, 100},
+		{"small stays small", 120, 90, 1024, 90},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := SquarePNG(encodePNG(t, picture(tt.w, tt.h, color.White, image.Rectangle{})), tt.size, 1<<20)
+			if err != nil {
+				t.Fatal(err)
+			}
+			config, err := png.DecodeConfig(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Width != tt.want || config.Height != tt.want {
+				t.Errorf("size = %dx%d, want %dx%d", config.Width, config.Height, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckMask(t *testing.T) {
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, picture(64, 64, color.White, image.Rectangle{}), nil); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		data  []byte
+		valid bool
+	}{
+		{"transparent area", encodePNG(t, picture(64, 64, color.White, image.Rect(0, 0, 10, 10))), true},
+		{"fully transparent", encodePNG(t, image.NewNRGBA(image.Rect(0, 0, 64, 64))), true},
+		{"opaque", encodePNG(t, picture(64, 64, color.White, image.Rectangle{})), false},
+		{"without alpha", encodePNG(t, image.NewGray(image.Rect(0, 0, 64, 64))), false},
+		{"jpeg", jpg.Bytes(), false},
+		{"garbage", []byte("not a picture"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckMask(tt.data); (err == nil) != tt.valid {
+				t.Errorf("CheckMask() = %v, valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+// the mask is cropped to the same square as the picture and scaled to its size
+func TestSquareMask(t *testing.T) {
+	// the transparent stripe on the left is cropped away with the picture, the one in the middle stays
+	source := picture(400, 200, color.White, image.Rect(0, 0, 50, 200))
+	for x := 180; x < 220; x++ {
+		for y := 0; y < 200; y++ {
+			source.Set(x, y, color.NRGBA{})
+		}
+	}
+	prepared, err := SquarePNG(encodePNG(t, picture(400, 200, color.Black, image.Rectangle{})), 100, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		mask []byte
+		want int
+	}{
+		{"same size as picture", encodePNG(t, source), 100},
+		{"smaller than picture", encodePNG(t, picture(40, 20, color.White, image.Rect(18, 0, 22, 20))), 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := SquareMask(tt.mask, prepared)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mask, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b := mask.Bounds(); b.Dx() != tt.want || b.Dy() != tt.want {
+				t.Fatalf("mask size = %dx%d, want %dx%d", b.Dx(), b.Dy(), tt.want, tt.want)
+			}
+			if _, _, _, a := mask.At(tt.want/2, tt.want/2).RGBA(); a != 0 {
+				t.Errorf("center alpha = %d, want transparent", a)
+			}
+			if _, _, _, a := mask.At(0, tt.want/2).RGBA(); a != 0xffff {
+				t.Errorf("left edge alpha = %d, want opaque", a)
+			}
+		})
+	}
+
+	if _, err := SquareMask(encodePNG(t, picture(400, 200, color.White, image.Rectangle{})), prepared); err == nil {
+		t.Error("opaque mask accepted")
+	}
+}
@@ -0,0 +1,128 @@
+// Package intent tells by keywords whether a message may ask for a picture, in English, Ukrainian, Spanish and Catalan.
+// It only rejects messages on its own, the rest are left for a language model to confirm.
+package intent
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Decision of the classifier
+type Decision int
+
+const (
+	// Reject is a message that does not ask for a picture
+	Reject Decision = iota
+	// Borderline is a message that may ask for a picture; keywords alone can't tell "draw a poster"
+	// from "create a poster session schedule", so the model decides
+	Borderline
+)
+
+func (d Decision) String() string {
+	if d == Reject {
+		return "reject"
+	}
+	return "borderline"
+}
+
+// word classes
+const (
+	draw    = "draw"    // verbs of drawing
+	create  = "create"  // verbs of making, imagining or showing, a picture only with the right object
+	picture = "picture" // nouns of pictures
+)
+
+// words by class; a word ending with * matches as a prefix, words with a space match two words in a row
+var (
+	exact    = map[string]string{}
+	prefixes []struct{ prefix, class string }
+)
+
+func register(class string, words ...string) {
+	for _, word := range words {
+		if stem, ok := strings.CutSuffix(word, "*"); ok {
+			prefixes = append(prefixes, struct{ prefix, class string }{stem, class})
+			continue
+		}
+		exact[word] = class
+	}
+}
+
+func init() {
+	// English
+	register(draw, "draw", "paint", "sketch", "doodle", "illustrate", "render")
+	register(create, "generate", "create", "make", "design", "visualize", "visualise", "imagine", "show me")
+	register(picture, "image", "images", "picture", "pictures", "pic", "pics", "photo", "photos", "illustration*",
+		"drawing*", "painting*", "sketches", "artwork*", "art", "logo", "logos", "icon", "icons", "wallpaper*", "portrait*",
+		"poster", "posters", "avatar*", "meme", "memes", "cartoon*", "comic", "comics")
+
+	// Ukrainian
+	register(draw, "намалюй*", "намалювати", "малюй*", "нарисуй*", "зобрази*", "проілюструй*")
+	register(create, "згенеруй", "згенеруйте", "генеруй", "створи", "створіть", "зроби", "зробіть", "візуалізуй",
+		"уяви", "уявіть", "покажи", "покажіть")
+	register(picture, "зображенн*", "картин*", "малюн*", "фото", "фотографі*", "фотк*", "світлин*", "ілюстрац*",
+		"логотип*", "іконк*", "портрет*", "постер*", "плакат*", "аватар*", "шпалер*", "арт", "мем", "меми", "мему", "мемів")
+
+	// Spanish
+	register(draw, "dibuj*", "pinta", "pintame", "pintar", "ilustra", "ilustrame")
+	register(create, "genera", "generame", "crea", "creame", "haz", "hazme", "disena", "disename", "visualiza",
+		"imagina", "imaginate", "muestrame", "ensename")
+	register(picture, "imagen*", "foto", "fotos", "fotografia*", "dibujo*", "ilustracion*", "cuadro*", "pintura*",
+		"logotipo*", "icono", "iconos", "retrato*", "cartel", "carteles", "meme", "memes")
+
+	// Catalan
+	register(draw, "dibuixa*", "pinta'm", "illustra", "illustra'm")
+	register(create, "genera'm", "crea'm", "fes", "dissenya", "dissenya'm", "visualitza", "imagina't", "mostra'm",
+		"ensenya'm")
+	register(picture, "imatge*", "dibuix", "dibuixos", "illustracio*", "quadre*", "logotip*", "icona", "icones",
+		"retrat*", "cartell", "cartells")
+}
+
+// accents are dropped so that dibújame matches dibujame; the Catalan middle dot joins ll
+var normalizer = strings.NewReplacer(
+	"á", "a", "à", "a", "é", "e", "è", "e", "í", "i", "ì", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ú", "u", "ù", "u", "ü", "u", "ñ", "n", "ç", "c",
+	"l·l", "ll", "l.l", "ll", "’", "'", "ʼ", "'",
+)
+
+// Classify rejects texts with no verb of drawing or making and no noun of a picture; any other text is
+// borderline, since a keyword may as well be part of a text request like "create a poster session schedule"
+func Classify(text string) Decision {
+	words := tokenize(text)
+	for i, word := range words {
+		if classOf(word) != "" {
+			return Borderline
+		}
+		if i+1 < len(words) && classOf(word+" "+words[i+1]) != "" {
+			return Borderline
+		}
+	}
+	return Reject
+}
+
+func classOf(word string) string {
+	if class, ok := exact[word]; ok {
+		return class
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(word, p.prefix) {
+			return p.class
+		}
+	}
+	return ""
+}
+
+// tokenize splits normalized text into words, apostrophes stay inside words like pinta'm
+func tokenize(text string) []string {
+	text = normalizer.Replace(strings.ToLower(text))
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	words := fields[:0]
+	for _, field := range fields {
+		if word := strings.Trim(field, "'"); word != "" {
+			words = append(words, word)
+		}
+	}
+	return words
+}
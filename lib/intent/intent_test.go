@@ -0,0 +1,97 @@
+package intent
+
+import "testing"
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		language string
+		text     string
+		want     Decision
+	}{
+		{"en", "draw a cat in a spacesuit", Borderline},
+		{"en", "Please paint me a sunset", Borderline},
+		{"en", "create a logo for my bakery", Borderline},
+		{"en", "what is the capital of France?", Reject},
+		{"en", "explain quantum entanglement", Reject},
+		{"en", "", Reject},
+		// keywords in text requests are left to the model, never accepted
+		{"en", "Give me a comic book recommendation", Borderline},
+		{"en", "Create a poster session schedule", Borderline},
+		{"en", "draw a conclusion from these numbers", Borderline},
+		// prefixes of picture words don't match longer words
+		{"en", "give an iconic speech for my wedding", Reject},
+		{"en", "the posterior probability of rain", Reject},
+		{"en", "imagination is more important than knowledge", Reject},
+		// verbs of making and showing name a picture by their object, the model tells them apart
+		{"en", "generate a dragon", Borderline},
+		{"en", "create a cat in a spacesuit", Borderline},
+		{"en", "visualize my dream house", Borderline},
+		{"en", "show me a sunset", Borderline},
+		{"en", "Design a tattoo with roses", Borderline},
+		{"en", "make me a shopping list", Borderline},
+		{"en", "show the steps of long division", Reject},
+		{"en", "tell me how to show respect", Reject},
+
+		{"uk", "намалюй кота в капелюсі", Borderline},
+		{"uk", "зроби, будь ласка, логотип для кав'ярні", Borderline},
+		{"uk", "покажи фото Києва", Borderline},
+		{"uk", "поясни формулу фотосинтезу", Reject},
+		{"uk", "згенеруй дракона", Borderline},
+		{"uk", "створи кота в скафандрі", Borderline},
+		{"uk", "покажи захід сонця над морем", Borderline},
+		{"uk", "уяви будинок моєї мрії", Borderline},
+		{"uk", "як приготувати борщ?", Reject},
+		{"uk", "напиши мемуари дідуся", Reject},
+
+		{"es", "dibújame un perro", Borderline},
+		{"es", "genera una imagen de un castillo", Borderline},
+		{"es", "muestra la fotosíntesis paso a paso", Reject},
+		{"es", "muéstrame una foto de Madrid", Borderline},
+		{"es", "genera un dragón", Borderline},
+		{"es", "créame un gato astronauta", Borderline},
+		{"es", "hazme un castillo en las nubes", Borderline},
+		{"es", "diseña un tatuaje", Borderline},
+		{"es", "¿cuál es la capital de Perú?", Reject},
+
+		{"ca", "dibuixa un gat", Borderline},
+		{"ca", "fes-me una il·lustració del mar", Borderline},
+		{"ca", "pinta'm una posta de sol", Borderline},
+		{"ca", "genera un drac", Borderline},
+		{"ca", "fes un gat amb vestit espacial", Borderline},
+		{"ca", "mostra'm una posta de sol", Borderline},
+		{"ca", "imagina't la casa dels meus somnis", Borderline},
+		{"ca", "quina hora és?", Reject},
+		{"ca", "explica'm la fotosíntesi", Reject},
+	}
+	for _, tt := range tests {
+		t.Run(tt.language+" "+tt.text, func(t *testing.T) {
+			if got := Classify(tt.text); got != tt.want {
+				t.Errorf("Classify(%q) = %s, want %s", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tests := map[string][]string{
+		"Dibújame un niño":     {"dibujame", "un", "nino"},
+		"il·lustració":         {"illustracio"},
+		"pinta’m un 'gat'":     {"pinta'm", "un", "gat"},
+		"draw 3 cats, please!": {"draw", "cats", "please"},
+		"НАМАЛЮЙ кота":         {"намалюй", "кота"},
+		"   ":                  nil,
+	}
+	for text, want := range tests {
+		got := tokenize(text)
+		if len(got) != len(want) {
+			t.Errorf("tokenize(%q) = %q, want %q", text, got, want)
+			continue
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Errorf("tokenize(%q) = %q, want %q", text, got, want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package pdf
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxRange caps entries of a single bfrange of malformed maps
+const maxRange = 1 << 16
+
+// cmap is a ToUnicode map from character codes to text
+type cmap struct {
+	lengths []int // code lengths in bytes, longest first
+	text    map[string]string
+}
+
+// parseCMap reads codespace ranges, bfchar and bfrange sections of a ToUnicode stream
+func parseCMap(data []byte) *cmap {
+	m := &cmap{text: make(map[string]string)}
+	p := &parser{data: data}
+	var operands []object
+	seen := map[int]bool{}
+	for {
+		obj, err := p.object()
+		if err != nil {
+			break
+		}
+		op, ok := obj.(keyword)
+		if !ok {
+			operands = append(operands, obj)
+			continue
+		}
+		switch op {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				if lo, ok := operands[i].(string); ok && len(lo) > 0 && !seen[len(lo)] {
+					seen[len(lo)] = true
+					m.lengths = append(m.lengths, len(lo))
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(string)
+				dst, ok2 := operands[i+1].(string)
+				if ok1 && ok2 {
+					m.text[src] = utf16Text(dst)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(string)
+				hi, ok2 := operands[i+1].(string)
+				if ok1 && ok2 && len(lo) == len(hi) {
+					m.addRange(lo, hi, operands[i+2])
+				}
+			}
+		}
+		if strings.HasPrefix(string(op), "begin") || strings.HasPrefix(string(op), "end") {
+			operands = operands[:0]
+		}
+	}
+	if len(m.lengths) == 0 {
+		for src := range m.text {
+			if !seen[len(src)] {
+				seen[len(src)] = true
+				m.lengths = append(m.lengths, len(src))
+			}
+		}
+	}
+	// longest codes are tried first
+	for i := 1; i < len(m.lengths); i++ {
+		for j := i; j > 0 && m.lengths[j] > m.lengths[j-1]; j-- {
+			m.lengths[j], m.lengths[j-1] = m.lengths[j-1], m.lengths[j]
+		}
+	}
+	return m
+}
+
+// addRange maps lo..hi either to consecutive text starting at dst or to the texts of a dst array
+func (m *cmap) addRange(lo, hi string, dst object) {
+	from, to := codeValue(lo), codeValue(hi)
+	if to < from || to-from > maxRange {
+		return
+	}
+	texts, _ := dst.(array)
+	start, _ := dst.(string)
+	for code := from; code <= to; code++ {
+		offset := code - from
+		src := codeBytes(code, len(lo))
+		if texts != nil {
+			if offset < len(texts) {
+				if s, ok := texts[offset].(string); ok {
+					m.text[src] = utf16Text(s)
+				}
+			}
+			continue
+		}
+		if start == "" {
+			return
+		}
+		// the last byte of the destination is incremented
+		b := []byte(start)
+		carry := offset
+		for i := len(b) - 1; i >= 0 && carry > 0; i-- {
+			sum := int(b[i]) + carry
+			b[i] = byte(sum)
+			carry = sum >> 8
+		}
+		m.text[src] = utf16Text(string(b))
+	}
+}
+
+func codeValue(code string) int {
+	v := 0
+	for i := 0; i < len(code); i++ {
+		v = v<<8 | int(code[i])
+	}
+	return v
+}
+
+func codeBytes(v, length int) string {
+	b := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	return string(b)
+}
+
+// utf16Text decodes a UTF-16BE destination string
+func utf16Text(s string) string {
+	if len(s)%2 == 1 {
+		s += "\x00"
+	}
+	units := make([]uint16, len(s)/2)
+	for i := range units {
+		units[i] = uint16(s[2*i])<<8 | uint16(s[2*i+1])
+	}
+	return string(utf16.Decode(units))
+}
+
+// decode maps the codes of the string, unmapped one-byte codes of simple fonts fall back to the encoding
+func (m *cmap) decode(s string, f *font) string {
+	var b bytes.Buffer
+	lengths := m.lengths
+	if len(lengths) == 0 {
+		lengths = []int{1}
+		if f.wide {
+			lengths = []int{2}
+		}
+	}
+	for i := 0; i < len(s); {
+		matched := false
+		for _, length := range lengths {
+			if i+length > len(s) {
+				continue
+			}
+			if text, ok := m.text[s[i:i+length]]; ok {
+				b.WriteString(text)
+				i += length
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+		if !f.wide {
+			if r := f.encoding[s[i]]; r != 0 {
+				b.WriteRune(r)
+			}
+			i++
+			continue
+		}
+		i += lengths[len(lengths)-1]
+	}
+	return b.String()
+}
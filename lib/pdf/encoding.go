@@ -0,0 +1,59 @@
+package pdf
+
+import (
+	"strconv"
+	"strings"
+)
+
+// winAnsi is the Windows-1252 encoding, also used for fonts without an encoding
+var winAnsi [256]rune
+
+// the range 0x80-0x9f differs from Latin-1; zero marks unused codes
+var cp1252 = [32]rune{
+	'€', 0, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0, 'Ž', 0,
+	0, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0, 'ž', 'Ÿ',
+}
+
+func init() {
+	for i := 0x20; i < 256; i++ {
+		winAnsi[i] = rune(i)
+	}
+	winAnsi[0x7f] = 0
+	for i, r := range cp1252 {
+		winAnsi[0x80+i] = r
+	}
+	// line breaks and tabs shown as text
+	winAnsi['\t'], winAnsi['\n'], winAnsi['\r'] = ' ', '\n', '\n'
+}
+
+// glyphs maps glyph names of Differences arrays that are not a single character or uniXXXX
+var glyphs = map[string]rune{
+	"space": ' ', "exclam": '!', "quotedbl": '"', "numbersign": '#', "dollar": '$', "percent": '%',
+	"ampersand": '&', "quotesingle": '\'', "quoteright": '’', "parenleft": '(', "parenright": ')',
+	"asterisk": '*', "plus": '+', "comma": ',', "hyphen": '-', "minus": '−', "period": '.', "slash": '/',
+	"zero": '0', "one": '1', "two": '2', "three": '3', "four": '4', "five": '5', "six": '6', "seven": '7',
+	"eight": '8', "nine": '9', "colon": ':', "semicolon": ';', "less": '<', "equal": '=', "greater": '>',
+	"question": '?', "at": '@', "bracketleft": '[', "backslash": '\\', "bracketright": ']',
+	"asciicircum": '^', "underscore": '_', "grave": '`', "quoteleft": '‘', "braceleft": '{', "bar": '|',
+	"braceright": '}', "asciitilde": '~', "bullet": '•', "endash": '–', "emdash": '—', "ellipsis": '…',
+	"quotedblleft": '“', "quotedblright": '”', "quotesinglbase": '‚', "quotedblbase": '„',
+	"guillemotleft": '«', "guillemotright": '»', "degree": '°', "copyright": '©', "registered": '®',
+	"trademark": '™', "section": '§', "paragraph": '¶', "dagger": '†', "daggerdbl": '‡', "Euro": '€',
+	"multiply": '×', "divide": '÷', "nbspace": ' ', "fi": 'ﬁ', "fl": 'ﬂ', "ff": 'ﬀ', "ffi": 'ﬃ', "ffl": 'ﬄ',
+}
+
+// glyphRune returns the character of a glyph name
+func glyphRune(glyph string) (rune, bool) {
+	if r, ok := glyphs[glyph]; ok {
+		return r, true
+	}
+	if runes := []rune(glyph); len(runes) == 1 {
+		return runes[0], true
+	}
+	if hex, ok := strings.CutPrefix(glyph, "uni"); ok && len(hex) == 4 {
+		if v, err := strconv.ParseUint(hex, 16, 32); err == nil {
+			return rune(v), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,366 @@
+package pdf
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// PDF objects: nil, bool, float64, string (bytes of a PDF string), name, keyword, dict, array, ref and stream
+type (
+	name    string
+	keyword string // operators of content streams and delimiters like ] and >>
+	dict    map[name]object
+	array   []object
+	object  interface{}
+)
+
+type ref struct {
+	num, gen int
+}
+
+type stream struct {
+	dict dict
+	data []byte // raw, filters are not applied
+}
+
+// parser reads objects from PDF data
+type parser struct {
+	data []byte
+	pos  int
+	refs bool // "N G R" is read as a reference; off in content streams where it never occurs
+}
+
+func isSpace(c byte) bool {
+	switch c {
+	case 0, '\t', '\n', '\f', '\r', ' ':
+		return true
+	}
+	return false
+}
+
+func isDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+func (p *parser) peek(offset int) byte {
+	if p.pos+offset < len(p.data) {
+		return p.data[p.pos+offset]
+	}
+	return 0
+}
+
+// skipSpace skips white space and comments
+func (p *parser) skipSpace() {
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		switch {
+		case isSpace(c):
+			p.pos++
+		case c == '%':
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// object reads the next object; closing delimiters and operators come as keywords
+func (p *parser) object() (object, error) {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, io.EOF
+	}
+	switch c := p.data[p.pos]; {
+	case c == '/':
+		return p.name(), nil
+	case c == '(':
+		return p.literal(), nil
+	case c == '<' && p.peek(1) == '<':
+		p.pos += 2
+		return p.dict()
+	case c == '<':
+		return p.hex(), nil
+	case c == '>' && p.peek(1) == '>':
+		p.pos += 2
+		return keyword(">>"), nil
+	case c == '[':
+		p.pos++
+		return p.array()
+	case isDelimiter(c):
+		p.pos++
+		return keyword(p.data[p.pos-1 : p.pos]), nil
+	}
+
+	word := p.regular()
+	switch word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	n, ok := number(word)
+	if !ok {
+		return keyword(word), nil
+	}
+	if p.refs && isInteger(word) {
+		start := p.pos
+		p.skipSpace()
+		if gen := p.regular(); isInteger(gen) {
+			p.skipSpace()
+			if p.peek(0) == 'R' && (p.pos+1 == len(p.data) || isSpace(p.peek(1)) || isDelimiter(p.peek(1))) {
+				p.pos++
+				g, _ := strconv.Atoi(gen)
+				return ref{num: int(n), gen: g}, nil
+			}
+		}
+		p.pos = start
+	}
+	return n, nil
+}
+
+// regular reads a run of regular characters
+func (p *parser) regular() string {
+	start := p.pos
+	for p.pos < len(p.data) && !isSpace(p.data[p.pos]) && !isDelimiter(p.data[p.pos]) {
+		p.pos++
+	}
+	return string(p.data[start:p.pos])
+}
+
+func number(word string) (float64, bool) {
+	if word == "" {
+		return 0, false
+	}
+	if c := word[0]; c != '+' && c != '-' && c != '.' && (c < '0' || c > '9') {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(word, 64)
+	if err != nil {
+		// malformed numbers like 1.2.3 are read as zero, as viewers do
+		return 0, true
+	}
+	return n, true
+}
+
+func isInteger(word string) bool {
+	if word == "" {
+		return false
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < '0' || word[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *parser) name() name {
+	p.pos++ // slash
+	raw := p.regular()
+	if !strings.Contains(raw, "#") {
+		return name(raw)
+	}
+	var decoded []byte
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '#' && i+2 < len(raw) {
+			if v, err := strconv.ParseUint(raw[i+1:i+3], 16, 8); err == nil {
+				decoded = append(decoded, byte(v))
+				i += 2
+				continue
+			}
+		}
+		decoded = append(decoded, raw[i])
+	}
+	return name(decoded)
+}
+
+// literal reads a (string) with escapes and balanced parentheses
+func (p *parser) literal() string {
+	p.pos++
+	var out []byte
+	depth := 1
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return string(out)
+			}
+		case '\r':
+			// an end of line in a string is a line feed
+			if p.peek(0) == '\n' {
+				p.pos++
+			}
+			c = '\n'
+		case '\\':
+			if p.pos >= len(p.data) {
+				continue
+			}
+			e := p.data[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if p.peek(0) == '\n' {
+					p.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && p.peek(0) >= '0' && p.peek(0) <= '7'; i++ {
+						v = v*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return string(out)
+}
+
+// hex reads a <hex string>, an odd last digit is followed by zero
+func (p *parser) hex() string {
+	p.pos++
+	var out []byte
+	var digit byte
+	half := false
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		if c == '>' {
+			break
+		}
+		var v byte
+		switch {
+		case c >= '0' && c <= '9':
+			v = c - '0'
+		case c >= 'a' && c <= 'f':
+			v = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			v = c - 'A' + 10
+		default:
+			continue
+		}
+		if half {
+			out = append(out, digit<<4|v)
+		} else {
+			digit = v
+		}
+		half = !half
+	}
+	if half {
+		out = append(out, digit<<4)
+	}
+	return string(out)
+}
+
+func (p *parser) dict() (dict, error) {
+	d := dict{}
+	for {
+		key, err := p.object()
+		if err != nil {
+			return d, err
+		}
+		if key == keyword(">>") {
+			return d, nil
+		}
+		k, ok := key.(name)
+		if !ok {
+			continue
+		}
+		value, err := p.object()
+		if err != nil {
+			return d, err
+		}
+		if value == keyword(">>") {
+			return d, nil
+		}
+		d[k] = value
+	}
+}
+
+func (p *parser) array() (array, error) {
+	var a array
+	for {
+		item, err := p.object()
+		if err != nil {
+			return a, err
+		}
+		if item == keyword("]") {
+			return a, nil
+		}
+		a = append(a, item)
+	}
+}
+
+// indirect reads the body of an indirect object after "N G obj", including stream data
+func (p *parser) indirect() (object, error) {
+	obj, err := p.object()
+	if err != nil {
+		return nil, err
+	}
+	d, ok := obj.(dict)
+	if !ok {
+		return obj, nil
+	}
+	p.skipSpace()
+	if !bytes.HasPrefix(p.data[p.pos:], []byte("stream")) {
+		return d, nil
+	}
+	p.pos += len("stream")
+	if p.peek(0) == '\r' {
+		p.pos++
+	}
+	if p.peek(0) == '\n' {
+		p.pos++
+	}
+	start := p.pos
+
+	// a direct length is trusted when endstream follows it, otherwise the data ends at endstream
+	if length, ok := d["Length"].(float64); ok && length >= 0 && length <= float64(len(p.data)-start) {
+		end := start + int(length)
+		rest := bytes.TrimLeft(p.data[end:], "\x00\t\n\f\r ")
+		if bytes.HasPrefix(rest, []byte("endstream")) {
+			p.pos = len(p.data) - len(rest) + len("endstream")
+			return stream{dict: d, data: p.data[start:end]}, nil
+		}
+	}
+	end := bytes.Index(p.data[start:], []byte("endstream"))
+	if end < 0 {
+		p.pos = len(p.data)
+		return stream{dict: d, data: p.data[start:]}, nil
+	}
+	p.pos = start + end + len("endstream")
+	data := bytes.TrimSuffix(p.data[start:start+end], []byte("\n"))
+	data = bytes.TrimSuffix(data, []byte("\r"))
+	return stream{dict: d, data: data}, nil
+}
@@ -0,0 +1,263 @@
+// Package pdf extracts the text of PDF documents page by page without external tools.
+// It reads plain and Flate-compressed content, object streams, font encodings and ToUnicode maps;
+// scanned pages have no text for it and encrypted documents are refused.
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrNotPDF is returned for data without the PDF header
+	ErrNotPDF = errors.New("not a PDF document")
+	// ErrEncrypted is returned for password-protected documents
+	ErrEncrypted = errors.New("encrypted PDF document")
+)
+
+const (
+	maxDepth     = 32 // nesting of page trees and references in malformed documents
+	maxFormDepth = 8  // nesting of form XObjects, each level may show the next one several times
+)
+
+// objects are found by their headers, so documents with a broken cross-reference table are read too
+var objectHeader = regexp.MustCompile(`(\d+)[\x00\t\n\f\r ]+(\d+)[\x00\t\n\f\r ]+obj\b`)
+
+type document struct {
+	objects map[int]object
+	fonts   map[int]*font // fonts by object number
+}
+
+// Pages returns the text of every page in reading order of the content streams
+func Pages(data []byte) (pages []string, err error) {
+	head := data
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	if !bytes.Contains(head, []byte("%PDF-")) {
+		return nil, ErrNotPDF
+	}
+	// the key appears only in the trailer of encrypted documents
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return nil, ErrEncrypted
+	}
+
+	// documents come from users, a malformed one must not take the bot down
+	defer func() {
+		if r := recover(); r != nil {
+			pages, err = nil, fmt.Errorf("malformed PDF document: %v", r)
+		}
+	}()
+
+	d := load(data)
+	for _, page := range d.pages() {
+		pages = append(pages, d.pageText(page))
+	}
+	return pages, nil
+}
+
+// load reads all indirect objects; later definitions win as in incremental updates
+func load(data []byte) *document {
+	d := &document{objects: make(map[int]object), fonts: make(map[int]*font)}
+	end := 0
+	for _, match := range objectHeader.FindAllSubmatchIndex(data, -1) {
+		if match[0] < end {
+			// inside the previous object, likely in stream data
+			continue
+		}
+		num, err := strconv.Atoi(string(data[match[2]:match[3]]))
+		if err != nil {
+			continue
+		}
+		p := &parser{data: data, pos: match[1], refs: true}
+		obj, err := p.indirect()
+		if err != nil {
+			continue
+		}
+		d.objects[num] = obj
+		end = p.pos
+	}
+
+	// objects of object streams fill numbers that are not defined directly
+	var packed []int
+	for num, obj := range d.objects {
+		if s, ok := obj.(stream); ok && s.dict["Type"] == name("ObjStm") {
+			packed = append(packed, num)
+		}
+	}
+	sort.Ints(packed)
+	for _, num := range packed {
+		d.unpack(d.objects[num].(stream))
+	}
+	return d
+}
+
+// unpack reads the objects of an object stream
+func (d *document) unpack(s stream) {
+	data, err := decode(s)
+	if err != nil {
+		return
+	}
+	count, _ := d.resolve(s.dict["N"]).(float64)
+	first, _ := d.resolve(s.dict["First"]).(float64)
+	if first < 0 || first > float64(len(data)) {
+		return
+	}
+	header := &parser{data: data[:int(first)]}
+	for i := 0; i < int(count); i++ {
+		num, err1 := header.object()
+		offset, err2 := header.object()
+		n, ok1 := num.(float64)
+		o, ok2 := offset.(float64)
+		if err1 != nil || err2 != nil || !ok1 || !ok2 {
+			return
+		}
+		if o < 0 || first+o >= float64(len(data)) {
+			continue
+		}
+		if _, defined := d.objects[int(n)]; defined {
+			continue
+		}
+		p := &parser{data: data, pos: int(first + o), refs: true}
+		if obj, err := p.object(); err == nil {
+			d.objects[int(n)] = obj
+		}
+	}
+}
+
+// resolve follows references
+func (d *document) resolve(obj object) object {
+	for i := 0; i < maxDepth; i++ {
+		r, ok := obj.(ref)
+		if !ok {
+			return obj
+		}
+		obj = d.objects[r.num]
+	}
+	return nil
+}
+
+func (d *document) dict(obj object) dict {
+	switch v := d.resolve(obj).(type) {
+	case dict:
+		return v
+	case stream:
+		return v.dict
+	}
+	return nil
+}
+
+// page is a leaf of the page tree with resources inherited from its ancestors
+type page struct {
+	dict      dict
+	resources dict
+}
+
+// pages walks the page tree from the catalog; without a catalog page objects are taken in object order
+func (d *document) pages() []page {
+	var pages []page
+	visited := make(map[int]bool)
+	var walk func(node object, resources dict, depth int)
+	walk = func(node object, resources dict, depth int) {
+		if r, ok := node.(ref); ok {
+			if visited[r.num] {
+				return
+			}
+			visited[r.num] = true
+		}
+		n := d.dict(node)
+		if n == nil || depth > maxDepth {
+			return
+		}
+		if own := d.dict(n["Resources"]); own != nil {
+			resources = own
+		}
+		if kids, ok := d.resolve(n["Kids"]).(array); ok && n["Type"] != name("Page") {
+			for _, kid := range kids {
+				walk(kid, resources, depth+1)
+			}
+			return
+		}
+		pages = append(pages, page{dict: n, resources: resources})
+	}
+
+	var nums []int
+	for num := range d.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		if catalog := d.dict(d.objects[num]); catalog["Type"] == name("Catalog") && catalog["Pages"] != nil {
+			walk(catalog["Pages"], nil, 0)
+			if len(pages) > 0 {
+				return pages
+			}
+		}
+	}
+	for _, num := range nums {
+		if n := d.dict(d.objects[num]); n["Type"] == name("Page") {
+			pages = append(pages, page{dict: n, resources: d.dict(n["Resources"])})
+		}
+	}
+	return pages
+}
+
+// pageText runs the content streams of the page
+func (d *document) pageText(p page) string {
+	var content []byte
+	contents := d.resolve(p.dict["Contents"])
+	parts, ok := contents.(array)
+	if !ok {
+		parts = array{contents}
+	}
+	for _, part := range parts {
+		s, ok := d.resolve(part).(stream)
+		if !ok {
+			continue
+		}
+		data, err := decode(s)
+		if err != nil {
+			continue
+		}
+		// streams of a page are concatenated, split at token boundaries
+		content = append(append(content, data...), '\n')
+	}
+
+	w := &textWriter{}
+	d.run(content, p.resources, w, 0)
+	return w.String()
+}
+
+// textWriter joins shown strings with the spaces and line breaks implied by text positioning
+type textWriter struct {
+	b       strings.Builder
+	space   bool
+	newline bool
+}
+
+func (w *textWriter) write(s string) {
+	if s == "" {
+		return
+	}
+	if w.b.Len() > 0 {
+		switch {
+		case w.newline:
+			w.b.WriteByte('\n')
+		case w.space && !strings.HasPrefix(s, " ") && !strings.HasSuffix(w.b.String(), " "):
+			w.b.WriteByte(' ')
+		}
+	}
+	w.space, w.newline = false, false
+	w.b.WriteString(s)
+}
+
+var blankLines = regexp.MustCompile(`\n[ \t]*\n(\s*\n)+`)
+
+func (w *textWriter) String() string {
+	return strings.TrimSpace(blankLines.ReplaceAllString(w.b.String(), "\n\n"))
+}
@@ -0,0 +1,187 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// buildPDF numbers the objects from 1 after the header; the extractor finds objects by their headers,
+// so no cross-reference table is needed
+func buildPDF(objects ...string) []byte {
+	var b strings.Builder
+	b.WriteString("%PDF-1.4\n")
+	for i, obj := range objects {
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	b.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return []byte(b.String())
+}
+
+func contentStream(content string) string {
+	return fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content)
+}
+
+func flateStream(t *testing.T, content string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", buf.Len(), buf.String())
+}
+
+// onePage is a document with a single page of the content and the font F1 of object 5
+func onePage(content, font string) []byte {
+	return buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 5 0 R >> >> /Contents 4 0 R >>",
+		contentStream(content),
+		font,
+	)
+}
+
+const helvetica = "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>"
+
+func TestPages(t *testing.T) {
+	cmap := `/CIDInit /ProcSet findresource begin
+begincmap
+1 begincodespacerange <0000> <FFFF> endcodespacerange
+2 beginbfchar <0001> <041F> <0002> <0440> endbfchar
+1 beginbfrange <0003> <0005> <0438> endbfrange
+endcmap`
+
+	tests := []struct {
+		name string
+		data []byte
+		want []string
+	}{
+		{"one line", onePage("BT /F1 12 Tf 72 700 Td (Hello, world!) Tj ET", helvetica), []string{"Hello, world!"}},
+		{"lines by position", onePage("BT /F1 12 Tf 72 700 Td (First) Tj 0 -14 Td (Second) Tj ET", helvetica),
+			[]string{"First\nSecond"}},
+		{"words by kerning", onePage("BT /F1 12 Tf [(Hel) -20 (lo) -500 (there)] TJ ET", helvetica), []string{"Hello there"}},
+		{"escaped string", onePage(`BT /F1 12 Tf (a \(b\) \\ c) Tj ET`, helvetica), []string{`a (b) \ c`}},
+		{"octal and winansi", onePage(`BT /F1 12 Tf (caf\351 \200) Tj ET`, helvetica), []string{"café €"}},
+		// unknown glyph names keep the base encoding
+		{"differences", onePage("BT /F1 12 Tf (abc) Tj ET",
+			"<< /Type /Font /Subtype /Type1 /Encoding << /Differences [97 /Euro /uni00E9 /eacute] >> >>"), []string{"€éc"}},
+		{"to unicode", buildPDF(
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+			"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 5 0 R >> >> /Contents 4 0 R >>",
+			contentStream("BT /F1 12 Tf <0001000200030004> Tj ET"),
+			"<< /Type /Font /Subtype /Type0 /ToUnicode 6 0 R >>",
+			contentStream(cmap),
+		), []string{"Прий"}},
+		{"composite font without map", onePage("BT /F1 12 Tf <00010002> Tj ET",
+			"<< /Type /Font /Subtype /Type0 >>"), []string{""}},
+		{"flate content", buildPDF(
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+			"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+			flateStream(t, "BT (Compressed text) Tj ET"),
+		), []string{"Compressed text"}},
+		{"page tree order", buildPDF(
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [4 0 R 3 0 R] /Count 2 >>",
+			"<< /Type /Page /Parent 2 0 R /Contents 6 0 R >>",
+			"<< /Type /Page /Parent 2 0 R /Contents 5 0 R >>",
+			contentStream("BT (One) Tj ET"),
+			contentStream("BT (Two) Tj ET"),
+		), []string{"One", "Two"}},
+		{"pages without catalog", buildPDF(
+			"<< /Type /Page /Contents 2 0 R >>",
+			contentStream("BT (Orphan) Tj ET"),
+		), []string{"Orphan"}},
+		{"form xobject", buildPDF(
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+			"<< /Type /Page /Parent 2 0 R /Resources << /XObject << /X1 5 0 R >> >> /Contents 4 0 R >>",
+			contentStream("/X1 Do"),
+			"<< /Type /XObject /Subtype /Form /Length 21 >>\nstream\nBT (In a form) Tj ET\nendstream",
+		), []string{"In a form"}},
+		{"inline image skipped", onePage("BI /W 2 /H 1 /BPC 8 /CS /G ID \x00\xff EI BT /F1 12 Tf (After) Tj ET", helvetica),
+			[]string{"After"}},
+		{"scanned page", onePage("q 100 0 0 100 0 0 cm /Im1 Do Q", helvetica), []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages, err := Pages(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(pages, tt.want) {
+				t.Errorf("Pages() = %q, want %q", pages, tt.want)
+			}
+		})
+	}
+}
+
+func TestPagesRefused(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"not a pdf", []byte("just some text"), ErrNotPDF},
+		{"encrypted", append(onePage("BT (Secret) Tj ET", helvetica), "trailer << /Encrypt 9 0 R >>"...), ErrEncrypted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Pages(tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("Pages() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// malformed documents come from users and must neither hang nor panic
+func TestPagesMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"header only", []byte("%PDF-1.7\n")},
+		{"truncated object", []byte("%PDF-1.4\n1 0 obj\n<< /Type /Page /Contents 2 0 R")},
+		{"cyclic page tree", buildPDF(
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [2 0 R 3 0 R] >>",
+			"<< /Type /Pages /Kids [2 0 R] >>",
+		)},
+		{"reference loop", buildPDF("<< /Type /Catalog /Pages 2 0 R >>", "3 0 R", "2 0 R")},
+		{"self-showing form", buildPDF(
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [3 0 R] >>",
+			"<< /Type /Page /Resources 5 0 R /Contents 4 0 R >>",
+			contentStream("/X1 Do /X1 Do"),
+			"<< /XObject << /X1 6 0 R >> >>",
+			"<< /Subtype /Form /Resources 5 0 R /Length 13 >>\nstream\n/X1 Do /X1 Do\nendstream",
+		)},
+		{"broken flate", buildPDF(
+			"<< /Type /Page /Contents 2 0 R >>",
+			"<< /Length 4 /Filter /FlateDecode >>\nstream\nxxxx\nendstream",
+		)},
+		{"deep arrays", onePage(strings.Repeat("[", 100000)+" Tj", helvetica)},
+		{"unterminated string", onePage("BT (never closed Tj ET", helvetica)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// an error is as good as no text, the test fails on a panic or a hang
+			pages, _ := Pages(tt.data)
+			for _, page := range pages {
+				if strings.Contains(page, "\x00") {
+					t.Errorf("page text contains raw bytes: %q", page)
+				}
+			}
+		})
+	}
+}
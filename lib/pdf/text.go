@@ -0,0 +1,220 @@
+package pdf
+
+import (
+	"bytes"
+	"math"
+	"regexp"
+)
+
+// kerning wider than this, in thousandths of the font size, separates words in TJ arrays
+const wordGap = 200
+
+// inline image data ends at EI surrounded by white space
+var inlineImageEnd = regexp.MustCompile(`[\x00\t\n\f\r ]EI([\x00\t\n\f\r ]|$)`)
+
+// run interprets text operators of a content stream; text shown at another height starts a new line,
+// text moved along the line is separated by a space
+func (d *document) run(content []byte, resources dict, w *textWriter, depth int) {
+	if depth > maxFormDepth {
+		return
+	}
+	p := &parser{data: content}
+	var operands []object
+	var current *font
+	var y, shownY float64
+	moved, shown := false, false
+	show := func(s string) {
+		text := current.decode(s)
+		if text == "" {
+			return
+		}
+		if shown && math.Abs(y-shownY) >= 1 {
+			w.newline = true
+		} else if moved {
+			w.space = true
+		}
+		moved, shown, shownY = false, true, y
+		w.write(text)
+	}
+	for {
+		obj, err := p.object()
+		if err != nil {
+			return
+		}
+		op, ok := obj.(keyword)
+		if !ok {
+			operands = append(operands, obj)
+			continue
+		}
+
+		switch op {
+		case "BT":
+			y, moved = 0, true
+		case "Tf":
+			if len(operands) >= 2 {
+				if fontName, ok := operands[0].(name); ok {
+					current = d.font(resources, fontName)
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				ty, _ := operands[1].(float64)
+				y += ty
+				moved = true
+			}
+		case "Tm":
+			if len(operands) >= 6 {
+				y, _ = operands[5].(float64)
+				moved = true
+			}
+		case "T*":
+			// the leading is not tracked, any change of height starts a line
+			y--
+		case "Tj":
+			if s, ok := last(operands).(string); ok {
+				show(s)
+			}
+		case "'", "\"":
+			y--
+			if s, ok := last(operands).(string); ok {
+				show(s)
+			}
+		case "TJ":
+			items, _ := last(operands).(array)
+			for _, item := range items {
+				switch v := item.(type) {
+				case string:
+					show(v)
+				case float64:
+					if v < -wordGap {
+						moved = true
+					}
+				}
+			}
+		case "Do":
+			if xobjectName, ok := last(operands).(name); ok {
+				d.runForm(resources, xobjectName, w, depth)
+			}
+		case "BI":
+			skipInlineImage(p)
+		}
+		operands = operands[:0]
+	}
+}
+
+func last(operands []object) object {
+	if len(operands) == 0 {
+		return nil
+	}
+	return operands[len(operands)-1]
+}
+
+// runForm interprets a form XObject, images are skipped
+func (d *document) runForm(resources dict, xobjectName name, w *textWriter, depth int) {
+	form, ok := d.resolve(d.dict(resources["XObject"])[xobjectName]).(stream)
+	if !ok || form.dict["Subtype"] != name("Form") {
+		return
+	}
+	data, err := decode(form)
+	if err != nil {
+		return
+	}
+	if own := d.dict(form.dict["Resources"]); own != nil {
+		resources = own
+	}
+	d.run(data, resources, w, depth+1)
+}
+
+// skipInlineImage moves past the dictionary and binary data of an inline image
+func skipInlineImage(p *parser) {
+	for {
+		obj, err := p.object()
+		if err != nil {
+			return
+		}
+		if obj == keyword("ID") {
+			break
+		}
+	}
+	if loc := inlineImageEnd.FindIndex(p.data[p.pos:]); loc != nil {
+		p.pos += loc[1]
+	} else {
+		p.pos = len(p.data)
+	}
+}
+
+// font maps character codes of shown strings to text
+type font struct {
+	toUnicode *cmap
+	wide      bool      // two-byte codes of composite fonts
+	encoding  [256]rune // codes of simple fonts
+}
+
+// font returns the font of the resources by its name, nil when it is missing
+func (d *document) font(resources dict, fontName name) *font {
+	obj := d.dict(resources["Font"])[fontName]
+	r, isRef := obj.(ref)
+	if isRef {
+		if f, ok := d.fonts[r.num]; ok {
+			return f
+		}
+	}
+	fd := d.dict(obj)
+	if fd == nil {
+		return nil
+	}
+
+	// named encodings are read as WinAnsi, the Mac and Standard encodings share ASCII with it
+	f := &font{wide: fd["Subtype"] == name("Type0"), encoding: winAnsi}
+	if s, ok := d.resolve(fd["ToUnicode"]).(stream); ok {
+		if data, err := decode(s); err == nil {
+			f.toUnicode = parseCMap(data)
+		}
+	}
+	if enc, ok := d.resolve(fd["Encoding"]).(dict); ok {
+		if differences, ok := d.resolve(enc["Differences"]).(array); ok {
+			f.applyDifferences(differences)
+		}
+	}
+	if isRef {
+		d.fonts[r.num] = f
+	}
+	return f
+}
+
+func (f *font) applyDifferences(differences array) {
+	code := 0
+	for _, item := range differences {
+		switch v := item.(type) {
+		case float64:
+			code = int(v)
+		case name:
+			if code >= 0 && code < 256 {
+				if r, ok := glyphRune(string(v)); ok {
+					f.encoding[code] = r
+				}
+			}
+			code++
+		}
+	}
+}
+
+// decode converts a shown string to text; codes of composite fonts without ToUnicode are unknown
+func (f *font) decode(s string) string {
+	if f == nil {
+		f = &font{encoding: winAnsi}
+	}
+	if f.toUnicode != nil {
+		return f.toUnicode.decode(s, f)
+	}
+	if f.wide {
+		return ""
+	}
+	var b bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		if r := f.encoding[s[i]]; r != 0 {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,239 @@
+package readable
+
+import (
+	"html"
+	"strings"
+)
+
+// maxDepth caps nesting of malformed pages, deeper elements join their parent
+const maxDepth = 256
+
+// node is an element or, with an empty tag, a text
+type node struct {
+	tag      string
+	attrs    map[string]string
+	text     string
+	parent   *node
+	children []*node
+}
+
+func (n *node) attr(key string) string {
+	return n.attrs[key]
+}
+
+var voidElements = set("area", "base", "br", "col", "embed", "hr", "img", "input", "link", "meta", "param", "source", "track", "wbr")
+
+// raw text elements end only at their end tag; the title is kept, the rest is never shown
+var rawElements = set("script", "style", "noscript", "template", "textarea", "title", "xmp", "iframe", "noembed", "noframes")
+
+// an open paragraph ends where one of these starts
+var closesParagraph = set("address", "article", "aside", "blockquote", "details", "div", "dl", "fieldset", "figcaption",
+	"figure", "footer", "form", "h1", "h2", "h3", "h4", "h5", "h6", "header", "hr", "main", "menu", "nav", "ol", "p",
+	"pre", "section", "table", "ul")
+
+func set(items ...string) map[string]bool {
+	m := make(map[string]bool, len(items))
+	for _, item := range items {
+		m[item] = true
+	}
+	return m
+}
+
+// parse builds a tree of the document tolerating unclosed and misnested tags the way browsers mostly do
+func parse(document string) *node {
+	root := &node{tag: "#document"}
+	stack := []*node{root}
+	top := func() *node { return stack[len(stack)-1] }
+	appendText := func(text string) {
+		if text == "" {
+			return
+		}
+		parent := top()
+		if last := len(parent.children) - 1; last >= 0 && parent.children[last].tag == "" {
+			parent.children[last].text += text
+			return
+		}
+		parent.children = append(parent.children, &node{text: text, parent: parent})
+	}
+	// closeTo pops the stack up to and including the innermost open tag, scoped by the boundaries
+	closeTo := func(tag string, boundaries ...string) bool {
+		for i := len(stack) - 1; i > 0; i-- {
+			if stack[i].tag == tag {
+				stack = stack[:i]
+				return true
+			}
+			for _, boundary := range boundaries {
+				if stack[i].tag == boundary {
+					return false
+				}
+			}
+		}
+		return false
+	}
+
+	s := document
+	for i := 0; i < len(s); {
+		if s[i] != '<' {
+			end := strings.IndexByte(s[i:], '<')
+			if end < 0 {
+				end = len(s) - i
+			}
+			appendText(html.UnescapeString(s[i : i+end]))
+			i += end
+			continue
+		}
+
+		rest := s[i:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			i += skipPast(rest, "-->", 4)
+		case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?"):
+			i += skipPast(rest, ">", 2)
+		case strings.HasPrefix(rest, "</") && len(rest) > 2 && isLetter(rest[2]):
+			name, _, _, n := readTag(rest[2:])
+			i += 2 + n
+			switch name {
+			case "p", "li", "dt", "dd", "td", "th", "tr":
+				// a stray end tag of these does not close anything outside their container
+				closeTo(name, "table", "ul", "ol", "dl", "div", "section", "article", "main", "body")
+			default:
+				closeTo(name)
+			}
+		case len(rest) > 1 && isLetter(rest[1]):
+			name, attrs, selfClosing, n := readTag(rest[1:])
+			i += 1 + n
+
+			switch {
+			case closesParagraph[name]:
+				closeTo("p", "button", "table", "li", "td", "th", "div", "section", "article", "main", "body")
+			case name == "li":
+				closeTo("li", "ul", "ol", "menu")
+			case name == "dt" || name == "dd":
+				if !closeTo("dt", "dl") {
+					closeTo("dd", "dl")
+				}
+			case name == "tr":
+				closeTo("tr", "table", "tbody", "thead", "tfoot")
+			case name == "td" || name == "th":
+				if !closeTo("td", "tr", "table") {
+					closeTo("th", "tr", "table")
+				}
+			}
+
+			element := &node{tag: name, attrs: attrs, parent: top()}
+			top().children = append(top().children, element)
+			if rawElements[name] && !selfClosing {
+				end := indexFold(s[i:], "</"+name)
+				if end < 0 {
+					end = len(s) - i
+				}
+				if name == "title" || name == "textarea" {
+					element.children = []*node{{text: html.UnescapeString(s[i : i+end]), parent: element}}
+				}
+				i += end
+				i += skipPast(s[i:], ">", 0)
+				continue
+			}
+			if !voidElements[name] && !selfClosing && len(stack) < maxDepth {
+				stack = append(stack, element)
+			}
+		default:
+			appendText("<")
+			i++
+		}
+	}
+	return root
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// skipPast returns the length up to the end of the first marker after from, or the whole rest
+func skipPast(s, marker string, from int) int {
+	if from > len(s) {
+		return len(s)
+	}
+	if end := strings.Index(s[from:], marker); end >= 0 {
+		return from + end + len(marker)
+	}
+	return len(s)
+}
+
+// indexFold finds an ASCII substring ignoring case
+func indexFold(s, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+// readTag reads a tag name with attributes up to the closing bracket and returns the length read
+func readTag(s string) (name string, attrs map[string]string, selfClosing bool, n int) {
+	i := 0
+	for i < len(s) && !isTagSpace(s[i]) && s[i] != '>' && s[i] != '/' {
+		i++
+	}
+	name = strings.ToLower(s[:i])
+	for i < len(s) {
+		for i < len(s) && (isTagSpace(s[i]) || s[i] == '/') {
+			selfClosing = s[i] == '/'
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+		if s[i] == '>' {
+			return name, attrs, selfClosing, i + 1
+		}
+		selfClosing = false
+
+		start := i
+		for i < len(s) && !isTagSpace(s[i]) && s[i] != '>' && s[i] != '=' && (s[i] != '/' || i == start) {
+			i++
+		}
+		key := strings.ToLower(s[start:i])
+		for i < len(s) && isTagSpace(s[i]) {
+			i++
+		}
+		value := ""
+		if i < len(s) && s[i] == '=' {
+			i++
+			for i < len(s) && isTagSpace(s[i]) {
+				i++
+			}
+			if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+				quote := s[i]
+				end := strings.IndexByte(s[i+1:], quote)
+				if end < 0 {
+					end = len(s) - i - 1
+				}
+				value = s[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				start := i
+				for i < len(s) && !isTagSpace(s[i]) && s[i] != '>' {
+					i++
+				}
+				value = s[start:i]
+			}
+		}
+		if attrs == nil {
+			attrs = make(map[string]string)
+		}
+		if _, ok := attrs[key]; !ok {
+			attrs[key] = html.UnescapeString(value)
+		}
+	}
+	return name, attrs, selfClosing, min(i, len(s))
+}
+
+func isTagSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', '\f':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,291 @@
+// Package readable extracts the readable text of an HTML page. Scripts, styles, navigation, headers, footers,
+// sidebars, forms and blocks made mostly of links are left out; when the page marks its main content
+// with main or article, only that part is read.
+package readable
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Page is the readable content of an HTML document
+type Page struct {
+	Title string
+	Text  string
+}
+
+// elements that never carry the content of a page
+var dropElements = set("script", "style", "noscript", "template", "textarea", "iframe", "noembed", "noframes", "svg",
+	"canvas", "object", "embed", "video", "audio", "map", "nav", "aside", "footer", "form", "button", "select",
+	"input", "label", "dialog", "menu", "head", "title")
+
+var dropRoles = set("navigation", "banner", "contentinfo", "complementary", "search", "dialog", "alertdialog", "menu", "menubar")
+
+// class and id words of boilerplate containers
+var boilerplate = regexp.MustCompile(`(?i)(^|[\s_-])(nav|navbar|navigation|menu|header|footer|sidebar|breadcrumbs?|cookies?|consent|gdpr|banner|ads?|advert|advertisement|promo|sponsored|share|sharing|social|subscribe|newsletter|related|recommended|comments?|popup|modal|skip)($|[\s_-])`)
+
+var blockElements = set("address", "article", "blockquote", "dd", "details", "div", "dl", "dt", "fieldset", "figcaption",
+	"figure", "h1", "h2", "h3", "h4", "h5", "h6", "header", "hr", "li", "main", "ol", "p", "pre", "section", "summary",
+	"table", "tr", "ul", "caption", "body", "html")
+
+var headings = set("h1", "h2", "h3", "h4", "h5", "h6")
+
+// link density above which a container is taken for a menu or a list of teasers
+const (
+	maxLinkDensity  = 0.6
+	minDensityChars = 20 // shorter blocks keep their links, like a lone "Read more"
+)
+
+// Extract returns the title and the readable text of an HTML document
+func Extract(document string) Page {
+	root := parse(document)
+	page := Page{Title: collapse(textOf(find(root, func(n *node) bool { return n.tag == "title" && n.parent.tag != "svg" })))}
+
+	content := contentRoot(root)
+	w := &writer{}
+	render(content, w, content.tag == "article" || content.tag == "main")
+	page.Text = w.String()
+	if page.Title == "" {
+		page.Title = collapse(textOf(find(content, func(n *node) bool { return n.tag == "h1" })))
+	}
+	return page
+}
+
+// contentRoot returns the main element, else the article with the most text, else the body
+func contentRoot(root *node) *node {
+	main := find(root, func(n *node) bool { return n.tag == "main" || n.attr("role") == "main" })
+	if main != nil && strings.TrimSpace(textOf(main)) != "" {
+		return main
+	}
+	var best *node
+	bestLength := 0
+	walk(root, func(n *node) {
+		if n.tag == "article" {
+			if length := len(collapse(textOf(n))); length > bestLength {
+				best, bestLength = n, length
+			}
+		}
+	})
+	if best != nil {
+		return best
+	}
+	if body := find(root, func(n *node) bool { return n.tag == "body" }); body != nil {
+		return body
+	}
+	return root
+}
+
+// dropped tells whether an element is boilerplate; headers inside the content are kept for its headline
+func dropped(n *node, inContent bool) bool {
+	if n.tag == "header" && !inContent {
+		return true
+	}
+	if dropElements[n.tag] || dropRoles[n.attr("role")] {
+		return true
+	}
+	if _, hidden := n.attrs["hidden"]; hidden || n.attr("aria-hidden") == "true" {
+		return true
+	}
+	if style := strings.ReplaceAll(strings.ToLower(n.attr("style")), " ", ""); strings.Contains(style, "display:none") {
+		return true
+	}
+	switch {
+	case n.tag == "body" || n.tag == "html" || n.tag == "main" || n.tag == "article":
+		// pages put their layout into classes of these, like "has-sidebar"
+		return false
+	case !blockElements[n.tag] || headings[n.tag]:
+		// links and headings are named for their look, like "header" anchors of headings
+		return false
+	}
+	if boilerplate.MatchString(n.attr("class")) || boilerplate.MatchString(n.attr("id")) {
+		return true
+	}
+	switch n.tag {
+	case "ul", "ol", "div", "section", "table", "p", "dl":
+		return linkDensity(n) > maxLinkDensity
+	}
+	return false
+}
+
+// linkDensity is the share of text of the element that is inside links
+func linkDensity(n *node) float64 {
+	var total, linked int
+	var count func(n *node, inLink bool)
+	count = func(n *node, inLink bool) {
+		if n.tag == "" {
+			chars := utf8.RuneCountInString(strings.TrimSpace(n.text))
+			total += chars
+			if inLink {
+				linked += chars
+			}
+			return
+		}
+		for _, child := range n.children {
+			count(child, inLink || n.tag == "a")
+		}
+	}
+	count(n, false)
+	if total < minDensityChars {
+		return 0
+	}
+	return float64(linked) / float64(total)
+}
+
+func render(n *node, w *writer, inContent bool) {
+	if n.tag == "" {
+		w.text(n.text)
+		return
+	}
+	if n.tag == "br" {
+		w.lineBreak(1)
+		return
+	}
+	if n.tag == "img" {
+		return
+	}
+	if n.tag == "pre" {
+		w.lineBreak(2)
+		w.pre(textOf(n))
+		w.lineBreak(2)
+		return
+	}
+	inContent = inContent || n.tag == "article" || n.tag == "main"
+
+	block := blockElements[n.tag]
+	switch {
+	case headings[n.tag] || n.tag == "p" || n.tag == "blockquote" || n.tag == "table":
+		w.lineBreak(2)
+	case block:
+		w.lineBreak(1)
+	case n.tag == "td" || n.tag == "th":
+		w.separator(" | ")
+	}
+	if n.tag == "li" {
+		w.bullet = "- "
+	}
+	for _, child := range n.children {
+		if child.tag != "" && dropped(child, inContent) {
+			continue
+		}
+		render(child, w, inContent)
+	}
+	switch {
+	case headings[n.tag] || n.tag == "p" || n.tag == "blockquote" || n.tag == "table":
+		w.lineBreak(2)
+	case block:
+		w.lineBreak(1)
+	}
+}
+
+// writer collapses white space and joins blocks with line breaks
+type writer struct {
+	b      strings.Builder
+	breaks int // line breaks pending before the next text
+	space  bool
+	sep    string
+	bullet string // list marker of the next text
+}
+
+func (w *writer) text(s string) {
+	if s == "" {
+		return
+	}
+	if isSpaceByte(s[0]) {
+		w.space = true
+	}
+	for i, word := range strings.Fields(s) {
+		w.flush()
+		if (i > 0 || w.space) && w.b.Len() > 0 {
+			w.b.WriteByte(' ')
+		}
+		w.b.WriteString(word)
+		w.space = false
+	}
+	if isSpaceByte(s[len(s)-1]) {
+		w.space = true
+	}
+}
+
+func isSpaceByte(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func (w *writer) pre(s string) {
+	w.flush()
+	w.b.WriteString(strings.Trim(s, "\n"))
+	w.space = false
+}
+
+func (w *writer) flush() {
+	if w.b.Len() == 0 {
+		w.b.WriteString(w.bullet)
+		w.breaks, w.sep, w.bullet, w.space = 0, "", "", false
+		return
+	}
+	if w.breaks > 0 {
+		w.b.WriteString(strings.Repeat("\n", w.breaks))
+		w.space = false
+	} else if w.sep != "" {
+		w.b.WriteString(w.sep)
+		w.space = false
+	}
+	if w.bullet != "" {
+		w.b.WriteString(w.bullet)
+		w.space = false
+	}
+	w.breaks, w.sep, w.bullet = 0, "", ""
+}
+
+func (w *writer) lineBreak(n int) {
+	w.breaks = max(w.breaks, n)
+	w.space = false
+}
+
+func (w *writer) separator(sep string) {
+	w.sep = sep
+}
+
+var blankLines = regexp.MustCompile(`\n[ \t]*\n(\s*\n)+`)
+
+func (w *writer) String() string {
+	return strings.TrimSpace(blankLines.ReplaceAllString(w.b.String(), "\n\n"))
+}
+
+func textOf(n *node) string {
+	if n == nil {
+		return ""
+	}
+	if n.tag == "" {
+		return n.text
+	}
+	var b strings.Builder
+	for _, child := range n.children {
+		b.WriteString(textOf(child))
+	}
+	return b.String()
+}
+
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// find returns the first element in document order that matches
+func find(n *node, match func(n *node) bool) *node {
+	if n.tag != "" && match(n) {
+		return n
+	}
+	for _, child := range n.children {
+		if found := find(child, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func walk(n *node, visit func(n *node)) {
+	visit(n)
+	for _, child := range n.children {
+		walk(child, visit)
+	}
+}
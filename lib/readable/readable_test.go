@@ -0,0 +1,100 @@
+package readable
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		title    string
+		text     string
+	}{
+		{"title and paragraphs", `<html><head><title>Gardens</title></head><body><p>First.</p><p>Second.</p></body></html>`,
+			"Gardens", "First.\n\nSecond."},
+		{"script and style", `<body><script>var secret = "<p>no</p>";</script><style>p { color: red }</style><p>Visible</p></body>`,
+			"", "Visible"},
+		{"noscript and template", `<body><noscript>Enable JavaScript</noscript><template><p>Later</p></template><p>Shown</p></body>`,
+			"", "Shown"},
+		{"navigation", `<body><nav><a href="/">Home</a> <a href="/about">About</a></nav><p>Story</p><footer>© 2026</footer></body>`,
+			"", "Story"},
+		{"navigation role and class", `<body><div role="navigation">Menu</div><div class="site-sidebar">Links</div>` +
+			`<div id="cookie-banner">We use cookies</div><p>Story</p></body>`, "", "Story"},
+		{"hidden", `<body><div hidden>Secret</div><div style="display: none">Also secret</div><p aria-hidden="true">Icon</p><p>Open</p></body>`,
+			"", "Open"},
+		{"list of links", `<body><ul><li><a href="/1">First teaser of the list</a></li><li><a href="/2">Second teaser of the list</a></li></ul>` +
+			`<p>The article itself, with <a href="/x">one link</a> inside.</p></body>`, "", "The article itself, with one link inside."},
+		{"entities", `<body><p>Fish &amp; chips &lt;3 &quot;caf&eacute;&quot; &#8212; &#x41;&nbsp;B</p></body>`,
+			"", "Fish & chips <3 \"café\" — A B"},
+		// the head may be left out, its title is not a part of the text
+		{"entities in the title", `<title>Tom &amp; Jerry</title><p>Cartoon</p>`, "Tom & Jerry", "Cartoon"},
+		{"white space collapsed", "<body><p>  many   spaces\n\tand\nlines  </p>\n\n\n<div>next <b>bold</b>word</div></body>",
+			"", "many spaces and lines\n\nnext boldword"},
+		{"line breaks and lists", `<body><p>one<br>two</p><ul><li>apples</li><li>pears</ul></body>`,
+			"", "one\ntwo\n\n- apples\n- pears"},
+		{"table cells", `<body><table><tr><th>Name</th><th>Age</th></tr><tr><td>Ann</td><td>30</td></tr></table></body>`,
+			"", "Name | Age\nAnn | 30"},
+		{"preformatted", "<body><p>Code:</p><pre>  if x {\n    y()\n  }</pre></body>",
+			"", "Code:\n\n  if x {\n    y()\n  }"},
+		{"article over the body", `<body><header>Site name</header><div>Teaser</div><article><h1>Headline</h1><p>Story text.</p></article></body>`,
+			"Headline", "Headline\n\nStory text."},
+		{"longest article", `<body><article><p>Short</p></article><article><p>The longer story wins.</p></article></body>`,
+			"", "The longer story wins."},
+		{"main over articles", `<body><article><p>Teaser in a sidebar</p></article><main><p>Main content</p></main></body>`,
+			"", "Main content"},
+		{"main role", `<body><p>Outside</p><div role="main"><p>Inside</p></div></body>`, "", "Inside"},
+		{"empty main is skipped", `<body><main> </main><p>Body text</p></body>`, "", "Body text"},
+		{"header kept inside the article", `<body><article><header><h1>Title</h1><p>By Ann</p></header><p>Text</p></article></body>`,
+			"Title", "Title\n\nBy Ann\n\nText"},
+		{"layout class of the body", `<body class="has-sidebar"><p>Content</p></body>`, "", "Content"},
+		{"without body", `Just text &amp; more`, "", "Just text & more"},
+		{"svg title", `<body><svg><title>icon</title></svg><h1>Page</h1></body>`, "Page", "Page"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := Extract(tt.document)
+			if page.Title != tt.title {
+				t.Errorf("title = %q, want %q", page.Title, tt.title)
+			}
+			if page.Text != tt.text {
+				t.Errorf("text = %q, want %q", page.Text, tt.text)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		text     string
+	}{
+		{"unclosed paragraphs", `<body><p>one<p>two<div>three</div></body>`, "one\n\ntwo\n\nthree"},
+		{"unclosed cells", `<body><table><tr><td>a<td>b<tr><td>c</table></body>`, "a | b\nc"},
+		{"misnested inline tags", `<body><p><b>bold <i>both</b> italic</i></p></body>`, "bold both italic"},
+		{"stray end tags", `<body></p></li><p>text</p></td></body>`, "text"},
+		{"upper case tags", `<BODY><P CLASS="x">Loud</P><SCRIPT>hidden()</SCRIPT></BODY>`, "Loud"},
+		{"script without end", `<body><p>before</p><script>never closed`, "before"},
+		{"comments and doctype", `<!DOCTYPE html><!-- <p>hidden</p> --><body><?xml bogus?><p>shown</p></body>`, "shown"},
+		{"lone angle bracket", `<body><p>1 < 2 and 3 > 2</p></body>`, "1 < 2 and 3 > 2"},
+		{"attributes with brackets", `<body><p title="a > b" data-x='<p>'>text</p></body>`, "text"},
+		{"unterminated tag", `<body><p>text</p><div class="x`, "text"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Extract(tt.document).Text; got != tt.text {
+				t.Errorf("text = %q, want %q", got, tt.text)
+			}
+		})
+	}
+}
+
+// pages of thousands of nested elements are read without running out of stack
+func TestParseDepth(t *testing.T) {
+	document := "<body>" + strings.Repeat("<div>", 100000) + "deep" + strings.Repeat("</div>", 100000) + "</body>"
+	if got := Extract(document).Text; got != "deep" {
+		t.Errorf("text = %q, want deep", got)
+	}
+}
@@ -0,0 +1,52 @@
+package tokenizer
+
+import "math"
+
+// bytePairMerge splits piece into tokens by repeatedly merging the adjacent pair with the lowest rank
+func bytePairMerge(ranks map[string]int, piece string) []int {
+	type part struct {
+		start int
+		rank  int
+	}
+
+	rankOf := func(parts []part, i int) int {
+		if i+2 < len(parts) {
+			if rank, ok := ranks[piece[parts[i].start:parts[i+2].start]]; ok {
+				return rank
+			}
+		}
+		return math.MaxInt
+	}
+
+	parts := make([]part, len(piece)+1)
+	for i := range parts {
+		parts[i] = part{start: i, rank: math.MaxInt}
+	}
+	for i := 0; i < len(parts)-2; i++ {
+		parts[i].rank = rankOf(parts, i)
+	}
+
+	for len(parts) > 2 {
+		minRank, minIndex := math.MaxInt, -1
+		for i := 0; i < len(parts)-1; i++ {
+			if parts[i].rank < minRank {
+				minRank, minIndex = parts[i].rank, i
+			}
+		}
+		if minIndex < 0 {
+			break
+		}
+
+		parts = append(parts[:minIndex+1], parts[minIndex+2:]...)
+		parts[minIndex].rank = rankOf(parts, minIndex)
+		if minIndex > 0 {
+			parts[minIndex-1].rank = rankOf(parts, minIndex-1)
+		}
+	}
+
+	tokens := make([]int, 0, len(parts)-1)
+	for i := 0; i < len(parts)-1; i++ {
+		tokens = append(tokens, ranks[piece[parts[i].start:parts[i+1].start]])
+	}
+	return tokens
+}
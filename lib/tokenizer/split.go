@@ -0,0 +1,73 @@
+package tokenizer
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Unicode White_Space; Go's \s only covers ASCII whitespace
+const ws = `\t\n\v\f\r\x{85}\p{Z}`
+
+// Pre-tokenization patterns of the tiktoken encodings. RE2 has no lookahead, so the
+// `\s+(?!\S)` alternative is dropped and emulated in split.
+var (
+	cl100kPattern = expand(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^WS\p{L}\p{N}]+[\r\n]*|[WS]*[\r\n]+|[WS]+`)
+	o200kPattern  = expand(strings.Join([]string{
+		`[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?`,
+		`[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?`,
+		`\p{N}{1,3}`,
+		` ?[^WS\p{L}\p{N}]+[\r\n/]*`,
+		`[WS]*[\r\n]+`,
+		`[WS]+`,
+	}, "|"))
+)
+
+func expand(pattern string) *regexp.Regexp {
+	return regexp.MustCompile(strings.ReplaceAll(pattern, "WS", ws))
+}
+
+// split cuts text into pre-tokens the same way tiktoken does
+func split(pattern *regexp.Regexp, text string) []string {
+	var pieces []string
+	for start := 0; start < len(text); {
+		loc := pattern.FindStringIndex(text[start:])
+		if loc == nil {
+			pieces = append(pieces, text[start:])
+			break
+		}
+		from, to := start+loc[0], start+loc[1]
+		if from > start {
+			pieces = append(pieces, text[start:from])
+		}
+		piece := text[from:to]
+
+		// `\s+(?!\S)` leaves the last whitespace of a run for the following word
+		if to < len(text) && isSpaceRun(piece) {
+			next, _ := utf8.DecodeRuneInString(text[to:])
+			if !unicode.IsSpace(next) {
+				_, size := utf8.DecodeLastRuneInString(piece)
+				if size < len(piece) {
+					to -= size
+					piece = text[from:to]
+				}
+			}
+		}
+
+		pieces = append(pieces, piece)
+		start = to
+	}
+	return pieces
+}
+
+// isSpaceRun reports whether piece is whitespace without line breaks,
+// which is what only the final `\s+` alternative can produce
+func isSpaceRun(piece string) bool {
+	for _, r := range piece {
+		if !unicode.IsSpace(r) || r == '\r' || r == '\n' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,206 @@
+// Package tokenizer counts model tokens offline with tiktoken-compatible BPE encodings.
+//
+// Rank tables are committed gzipped in the data directory and embedded at build time,
+// `go generate` refreshes them. When a table is missing, counts fall back to a per-script estimate.
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:generate sh -c "curl -sSfL https://openaipublic.blob.core.windows.net/encodings/cl100k_base.tiktoken | gzip -9 > data/cl100k_base.tiktoken.gz"
+//go:generate sh -c "curl -sSfL https://openaipublic.blob.core.windows.net/encodings/o200k_base.tiktoken | gzip -9 > data/o200k_base.tiktoken.gz"
+
+//go:embed data
+var data embed.FS
+
+const (
+	Cl100kBase = "cl100k_base"
+	O200kBase  = "o200k_base"
+)
+
+// Encoding is a BPE encoding with its pre-tokenization pattern
+type Encoding struct {
+	name    string
+	pattern *regexp.Regexp
+	ranks   map[string]int
+	// average characters per token, used when ranks are not available
+	latinChars float64
+	otherChars float64
+}
+
+var (
+	encodings = map[string]*Encoding{
+		Cl100kBase: {name: Cl100kBase, pattern: cl100kPattern, latinChars: 4.5, otherChars: 2.5},
+		O200kBase:  {name: O200kBase, pattern: o200kPattern, latinChars: 4.5, otherChars: 3.5},
+	}
+	loadOnce sync.Map // map[string]*sync.Once
+)
+
+// model name prefixes and their encodings, more specific prefixes first
+var modelPrefixes = []struct {
+	prefix   string
+	encoding string
+}{
+	{"gpt-4o", O200kBase},
+	{"gpt-4.1", O200kBase},
+	{"gpt-4.5", O200kBase},
+	{"gpt-5", O200kBase},
+	{"gpt-oss", O200kBase},
+	{"chatgpt-4o", O200kBase},
+	{"o1", O200kBase},
+	{"o3", O200kBase},
+	{"o4", O200kBase},
+	{"gpt-4", Cl100kBase},
+	{"gpt-3.5", Cl100kBase},
+	{"text-embedding-3", Cl100kBase},
+	{"text-embedding-ada-002", Cl100kBase},
+}
+
+// ForModel returns the encoding used by the model, defaulting to o200k_base for unknown models
+func ForModel(model string) *Encoding {
+	name := O200kBase
+	for _, m := range modelPrefixes {
+		if strings.HasPrefix(model, m.prefix) {
+			name = m.encoding
+			break
+		}
+	}
+	enc, _ := Get(name)
+	return enc
+}
+
+// Get returns the encoding by name, loading its rank table on first use
+func Get(name string) (*Encoding, error) {
+	enc, ok := encodings[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown encoding: %s", name)
+	}
+	once, _ := loadOnce.LoadOrStore(name, &sync.Once{})
+	once.(*sync.Once).Do(func() {
+		enc.ranks, _ = loadRanks(name)
+	})
+	return enc, nil
+}
+
+// Name returns the encoding name
+func (e *Encoding) Name() string {
+	return e.name
+}
+
+// Exact reports whether the rank table is available, otherwise counts are estimated
+func (e *Encoding) Exact() bool {
+	return e.ranks != nil
+}
+
+// Encode returns token ids of the text, or nil when the rank table is not available
+func (e *Encoding) Encode(text string) []int {
+	if e.ranks == nil {
+		return nil
+	}
+	var tokens []int
+	for _, piece := range split(e.pattern, text) {
+		if rank, ok := e.ranks[piece]; ok {
+			tokens = append(tokens, rank)
+			continue
+		}
+		tokens = append(tokens, bytePairMerge(e.ranks, piece)...)
+	}
+	return tokens
+}
+
+// Count returns the number of tokens in the text
+func (e *Encoding) Count(text string) int {
+	if e.ranks != nil {
+		return len(e.Encode(text))
+	}
+	count := 0
+	for _, piece := range split(e.pattern, text) {
+		count += e.estimate(piece)
+	}
+	return count
+}
+
+// estimate approximates tokens of a single pre-token by its script
+func (e *Encoding) estimate(piece string) int {
+	var latin, other, wide int
+	for _, r := range piece {
+		switch {
+		case r < utf8.RuneSelf:
+			latin++
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			wide++
+		default:
+			other++
+		}
+	}
+	tokens := wide + ceil(float64(latin)/e.latinChars) + ceil(float64(other)/e.otherChars)
+	if tokens == 0 {
+		return 1
+	}
+	return tokens
+}
+
+func ceil(v float64) int {
+	n := int(v)
+	if float64(n) < v {
+		n++
+	}
+	return n
+}
+
+// loadRanks reads an embedded gzipped tiktoken file: one "base64-token rank" pair per line
+func loadRanks(name string) (map[string]int, error) {
+	raw, err := data.ReadFile("data/" + name + ".tiktoken.gz")
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", name, err)
+	}
+	defer func(zr io.Closer) {
+		_ = zr.Close()
+	}(zr)
+	return parseRanks(zr)
+}
+
+func parseRanks(r io.Reader) (map[string]int, error) {
+	ranks := make(map[string]int, 200000)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		token, rank, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("invalid rank line: %q", line)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			return nil, fmt.Errorf("decoding token %q: %w", token, err)
+		}
+		value, err := strconv.Atoi(rank)
+		if err != nil {
+			return nil, fmt.Errorf("parsing rank %q: %w", rank, err)
+		}
+		ranks[string(decoded)] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ranks, nil
+}
@@ -0,0 +1,147 @@
+package tokenizer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		text     string
+		want     []string
+	}{
+		{"words and punctuation", Cl100kBase, "Hello, world!", []string{"Hello", ",", " world", "!"}},
+		{"contraction", Cl100kBase, "I'm here", []string{"I", "'m", " here"}},
+		{"digits by three", Cl100kBase, "1234567", []string{"123", "456", "7"}},
+		{"space run leaves last space", Cl100kBase, "a   b", []string{"a", "  ", " b"}},
+		{"line breaks", Cl100kBase, "line\n\nnext", []string{"line", "\n\n", "next"}},
+		{"trailing spaces", Cl100kBase, "end  ", []string{"end", "  "}},
+		{"cyrillic", Cl100kBase, "Привіт, світ", []string{"Привіт", ",", " світ"}},
+		{"camel case whole", Cl100kBase, "HelloWorld", []string{"HelloWorld"}},
+		{"camel case split", O200kBase, "HelloWorld", []string{"Hello", "World"}},
+		{"contraction attached", O200kBase, "don't stop", []string{"don't", " stop"}},
+		{"path slashes", O200kBase, "a /b", []string{"a", " /", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := split(encodings[tt.encoding].pattern, tt.text)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("split(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+			if joined := strings.Join(got, ""); joined != tt.text {
+				t.Errorf("pieces join to %q, want %q", joined, tt.text)
+			}
+		})
+	}
+}
+
+func TestBytePairMerge(t *testing.T) {
+	ranks := map[string]int{"a": 0, "b": 1, "c": 2, "ab": 3, "bc": 4, "abc": 5}
+	tests := []struct {
+		piece string
+		want  []int
+	}{
+		{"a", []int{0}},
+		{"ab", []int{3}},
+		{"abc", []int{5}},
+		{"abcb", []int{5, 1}},
+		{"cab", []int{2, 3}},
+		{"bca", []int{4, 0}},
+	}
+	for _, tt := range tests {
+		if got := bytePairMerge(ranks, tt.piece); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("bytePairMerge(%q) = %v, want %v", tt.piece, got, tt.want)
+		}
+	}
+}
+
+func TestParseRanks(t *testing.T) {
+	ranks, err := parseRanks(strings.NewReader("aGVsbG8= 0\nIHdvcmxk 1\n\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"hello": 0, " world": 1}; !reflect.DeepEqual(ranks, want) {
+		t.Errorf("parseRanks = %v, want %v", ranks, want)
+	}
+	for _, input := range []string{"aGVsbG8=", "!!! 1", "aGVsbG8= x"} {
+		if _, err := parseRanks(strings.NewReader(input)); err == nil {
+			t.Errorf("parseRanks(%q) accepted an invalid line", input)
+		}
+	}
+}
+
+func TestForModel(t *testing.T) {
+	tests := map[string]string{
+		"gpt-4o":           O200kBase,
+		"gpt-4o-mini":      O200kBase,
+		"gpt-4.1-nano":     O200kBase,
+		"gpt-5-nano":       O200kBase,
+		"o3-mini":          O200kBase,
+		"gpt-4":            Cl100kBase,
+		"gpt-4-turbo":      Cl100kBase,
+		"gpt-3.5-turbo":    Cl100kBase,
+		"llama-3.1-70b":    O200kBase,
+		"":                 O200kBase,
+		"text-embedding-3": Cl100kBase,
+	}
+	for model, want := range tests {
+		if got := ForModel(model).Name(); got != want {
+			t.Errorf("ForModel(%q) = %s, want %s", model, got, want)
+		}
+	}
+}
+
+// known token ids and counts of the tiktoken reference implementation
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		encoding string
+		text     string
+		ids      []int
+		count    int
+	}{
+		{Cl100kBase, "hello world", []int{15339, 1917}, 2},
+		{Cl100kBase, "tiktoken is great!", []int{83, 1609, 5963, 374, 2294, 0}, 6},
+		{Cl100kBase, "Hello, world!", nil, 4},
+		{O200kBase, "hello world", []int{24912, 2375}, 2},
+		{O200kBase, "Hello, world!", nil, 4},
+		{O200kBase, "", nil, 0},
+	}
+	for _, tt := range tests {
+		enc, err := Get(tt.encoding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !enc.Exact() {
+			t.Fatalf("rank table of %s is not embedded, run go generate ./lib/tokenizer", tt.encoding)
+		}
+		if tt.ids != nil {
+			if got := enc.Encode(tt.text); !reflect.DeepEqual(got, tt.ids) {
+				t.Errorf("%s Encode(%q) = %v, want %v", tt.encoding, tt.text, got, tt.ids)
+			}
+		}
+		if got := enc.Count(tt.text); got != tt.count {
+			t.Errorf("%s Count(%q) = %d, want %d", tt.encoding, tt.text, got, tt.count)
+		}
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	enc := &Encoding{name: "test", pattern: cl100kPattern, latinChars: 4.5, otherChars: 2.5}
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"a", 1},
+		{"hello world", 4},
+		{"你好", 2},
+	}
+	for _, tt := range tests {
+		if got := enc.Count(tt.text); got != tt.want {
+			t.Errorf("estimated Count(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
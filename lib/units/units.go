@@ -0,0 +1,143 @@
+// Package units converts values between common units of measurement.
+package units
+
+import (
+	"fmt"
+	"strings"
+)
+
+type unit struct {
+	kind   string
+	factor float64 // value in the base unit of the kind
+}
+
+// base units: metre, kilogram, litre, second, square metre, metre per second, byte
+var table = map[string]unit{}
+
+func register(kind string, factor float64, names ...string) {
+	for _, name := range names {
+		table[name] = unit{kind: kind, factor: factor}
+	}
+}
+
+func init() {
+	register("length", 1, "m", "meter", "meters", "metre", "metres")
+	register("length", 1e-3, "mm", "millimeter", "millimeters", "millimetre", "millimetres")
+	register("length", 1e-2, "cm", "centimeter", "centimeters", "centimetre", "centimetres")
+	register("length", 1e3, "km", "kilometer", "kilometers", "kilometre", "kilometres")
+	register("length", 0.0254, "in", "inch", "inches")
+	register("length", 0.3048, "ft", "foot", "feet")
+	register("length", 0.9144, "yd", "yard", "yards")
+	register("length", 1609.344, "mi", "mile", "miles")
+	register("length", 1852, "nmi", "nautical mile", "nautical miles")
+
+	register("mass", 1, "kg", "kilogram", "kilograms")
+	register("mass", 1e-3, "g", "gram", "grams")
+	register("mass", 1e-6, "mg", "milligram", "milligrams")
+	register("mass", 1e3, "t", "tonne", "tonnes", "metric ton")
+	register("mass", 0.45359237, "lb", "lbs", "pound", "pounds")
+	register("mass", 0.028349523125, "oz", "ounce", "ounces")
+	register("mass", 6.35029318, "st", "stone", "stones")
+
+	register("volume", 1, "l", "liter", "liters", "litre", "litres")
+	register("volume", 1e-3, "ml", "milliliter", "milliliters", "millilitre", "millilitres")
+	register("volume", 1e3, "m3", "cubic meter", "cubic meters")
+	register("volume", 3.785411784, "gal", "gallon", "gallons", "us gallon")
+	register("volume", 4.54609, "imperial gallon", "uk gallon")
+	register("volume", 0.946352946, "qt", "quart", "quarts")
+	register("volume", 0.473176473, "pt", "pint", "pints")
+	register("volume", 0.2365882365, "cup", "cups")
+	register("volume", 0.0295735295625, "fl oz", "fluid ounce", "fluid ounces")
+	register("volume", 0.01478676478125, "tbsp", "tablespoon", "tablespoons")
+	register("volume", 0.00492892159375, "tsp", "teaspoon", "teaspoons")
+
+	register("time", 1, "s", "sec", "second", "seconds")
+	register("time", 1e-3, "ms", "millisecond", "milliseconds")
+	register("time", 60, "min", "minute", "minutes")
+	register("time", 3600, "h", "hr", "hour", "hours")
+	register("time", 86400, "d", "day", "days")
+	register("time", 604800, "wk", "week", "weeks")
+	register("time", 31557600, "yr", "year", "years")
+
+	register("area", 1, "m2", "square meter", "square meters")
+	register("area", 1e6, "km2", "square kilometer", "square kilometers")
+	register("area", 1e-4, "cm2", "square centimeter", "square centimeters")
+	register("area", 0.09290304, "ft2", "sq ft", "square foot", "square feet")
+	register("area", 1e4, "ha", "hectare", "hectares")
+	register("area", 4046.8564224, "ac", "acre", "acres")
+
+	register("speed", 1, "m/s", "meters per second")
+	register("speed", 1/3.6, "km/h", "kmh", "kph", "kilometers per hour")
+	register("speed", 0.44704, "mph", "miles per hour")
+	register("speed", 0.514444, "kn", "knot", "knots")
+
+	register("data", 1, "b", "byte", "bytes")
+	register("data", 1e3, "kb", "kilobyte", "kilobytes")
+	register("data", 1e6, "mb", "megabyte", "megabytes")
+	register("data", 1e9, "gb", "gigabyte", "gigabytes")
+	register("data", 1e12, "tb", "terabyte", "terabytes")
+	register("data", 1024, "kib", "kibibyte", "kibibytes")
+	register("data", 1024*1024, "mib", "mebibyte", "mebibytes")
+	register("data", 1024*1024*1024, "gib", "gibibyte", "gibibytes")
+
+	// temperature is converted by formulas, factor is unused
+	register("temperature", 0, "c", "°c", "celsius")
+	register("temperature", 0, "f", "°f", "fahrenheit")
+	register("temperature", 0, "k", "kelvin")
+}
+
+// Convert converts value between units of the same kind, unit names are case-insensitive
+func Convert(value float64, from, to string) (float64, error) {
+	fromUnit, ok := table[normalize(from)]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", from)
+	}
+	toUnit, ok := table[normalize(to)]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", to)
+	}
+	if fromUnit.kind != toUnit.kind {
+		return 0, fmt.Errorf("cannot convert %s to %s", fromUnit.kind, toUnit.kind)
+	}
+	if fromUnit.kind == "temperature" {
+		return convertTemperature(value, normalize(from), normalize(to)), nil
+	}
+	return value * fromUnit.factor / toUnit.factor, nil
+}
+
+func normalize(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.ReplaceAll(name, "²", "2")
+	name = strings.ReplaceAll(name, "³", "3")
+	return name
+}
+
+func convertTemperature(value float64, from, to string) float64 {
+	var kelvin float64
+	switch baseTemperature(from) {
+	case "c":
+		kelvin = value + 273.15
+	case "f":
+		kelvin = (value-32)*5/9 + 273.15
+	default:
+		kelvin = value
+	}
+	switch baseTemperature(to) {
+	case "c":
+		return kelvin - 273.15
+	case "f":
+		return (kelvin-273.15)*9/5 + 32
+	default:
+		return kelvin
+	}
+}
+
+func baseTemperature(name string) string {
+	switch name {
+	case "c", "°c", "celsius":
+		return "c"
+	case "f", "°f", "fahrenheit":
+		return "f"
+	}
+	return "k"
+}
@@ -0,0 +1,52 @@
+package units
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		value    float64
+		from, to string
+		want     float64
+	}{
+		{1, "km", "m", 1000},
+		{1, "mile", "km", 1.609344},
+		{12, "in", "ft", 1},
+		{1, "lb", "g", 453.59237},
+		{1, "gal", "l", 3.785411784},
+		{90, "min", "h", 1.5},
+		{1, "ha", "m²", 10000},
+		{36, "km/h", "m/s", 10},
+		{1, "GiB", "MiB", 1024},
+		{100, "C", "F", 212},
+		{32, "fahrenheit", "celsius", 0},
+		{0, "K", "°C", -273.15},
+		{1, " Fluid Ounce ", "tbsp", 2},
+	}
+	for _, tt := range tests {
+		got, err := Convert(tt.value, tt.from, tt.to)
+		if err != nil {
+			t.Errorf("Convert(%v, %q, %q) error: %v", tt.value, tt.from, tt.to, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-9*math.Max(1, math.Abs(tt.want)) {
+			t.Errorf("Convert(%v, %q, %q) = %v, want %v", tt.value, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	tests := []struct{ from, to string }{
+		{"parsec", "m"},
+		{"m", "furlong"},
+		{"kg", "m"},
+		{"c", "kg"},
+	}
+	for _, tt := range tests {
+		if _, err := Convert(1, tt.from, tt.to); err == nil {
+			t.Errorf("Convert(1, %q, %q) succeeded", tt.from, tt.to)
+		}
+	}
+}
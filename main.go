@@ -5,6 +5,7 @@ import (
 	"Brainy/bot"
 	"Brainy/core"
 	"Brainy/lib/sl"
+	"Brainy/lib/tokenizer"
 	"Brainy/storage"
 	"flag"
 	"fmt"
@@ -31,12 +32,27 @@ func main() {
 	log.With(
 		slog.String("config", *configPath),
 		slog.String("env", conf.Env),
-		slog.String("model", conf.Model),
+		slog.String("model", conf.ModelFor(core.TaskChat)),
+		slog.String("provider", conf.Provider.BaseURL),
 	).Info("starting brainy bot")
 
+	// the storage falls back to the encoding of the default chat model, chats are counted with their own models
+	encoding := tokenizer.ForModel(conf.ModelFor(core.TaskChat))
+	if encoding.Exact() {
+		log.Info("token counting", slog.String("encoding", encoding.Name()))
+	} else {
+		log.Warn("token counting is estimated, rank table not embedded", slog.String("encoding", encoding.Name()))
+	}
+
 	// Initialize storage based on config
 	var store storage.ContextStorage
 	var prefsStore storage.PreferencesStorage
+	var usageStore storage.UsageStorage
+	var settingsStore storage.SettingsStorage
+	var imageStore storage.ImageStorage
+	var vectorStore storage.VectorStorage
+	var documentStore storage.DocumentStorage
+	var moderationStore storage.ModerationStorage
 	var mongoStore *storage.MongoStorage
 
 	if conf.Mongo.Enabled {
@@ -47,15 +63,21 @@ func main() {
 			conf.Mongo.Host, conf.Mongo.Port,
 			conf.Mongo.Database, conf.Mongo.Database)
 		var err error
-		mongoStore, err = storage.NewMongoStorage(mongoURI, conf.Mongo.Database, log)
+		mongoStore, err = storage.NewMongoStorage(mongoURI, conf.Mongo.Database, encoding.Count, conf.ContextTokens, log)
 		if err != nil {
 			log.With(
 				slog.String("db", conf.Mongo.Database),
 				slog.String("user", conf.Mongo.User),
 				slog.String("host", conf.Mongo.Host),
 			).Error("falling back to memory", sl.Err(err))
-			store = storage.NewMemoryStorage()
+			store = storage.NewMemoryStorage(encoding.Count, conf.ContextTokens)
 			prefsStore = storage.NewMemoryPreferencesStorage()
+			usageStore = storage.NewMemoryUsageStorage()
+			settingsStore = storage.NewMemorySettingsStorage()
+			imageStore = storage.NewMemoryImageStorage()
+			vectorStore = storage.NewMemoryVectorStorage()
+			documentStore = storage.NewMemoryDocumentStorage()
+			moderationStore = storage.NewMemoryModerationStorage()
 		} else {
 			store = mongoStore
 			// Initialize preferences storage with shared MongoDB client
@@ -68,19 +90,89 @@ func main() {
 				log.Warn("preferences storage fallback to memory", sl.Err(err))
 				prefsStore = storage.NewMemoryPreferencesStorage()
 			}
+			usageStore, err = storage.NewMongoUsageStorage(
+				mongoStore.GetClient(),
+				mongoStore.GetDatabase(),
+				log,
+			)
+			if err != nil {
+				log.Warn("usage storage fallback to memory", sl.Err(err))
+				usageStore = storage.NewMemoryUsageStorage()
+			}
+			settingsStore, err = storage.NewMongoSettingsStorage(
+				mongoStore.GetClient(),
+				mongoStore.GetDatabase(),
+				log,
+			)
+			if err != nil {
+				log.Warn("settings storage fallback to memory", sl.Err(err))
+				settingsStore = storage.NewMemorySettingsStorage()
+			}
+			imageStore, err = storage.NewMongoImageStorage(
+				mongoStore.GetClient(),
+				mongoStore.GetDatabase(),
+				log,
+			)
+			if err != nil {
+				log.Warn("image storage fallback to memory", sl.Err(err))
+				imageStore = storage.NewMemoryImageStorage()
+			}
+			vectorStore, err = storage.NewMongoVectorStorage(
+				mongoStore.GetClient(),
+				mongoStore.GetDatabase(),
+				log,
+			)
+			if err != nil {
+				log.Warn("vector storage fallback to memory", sl.Err(err))
+				vectorStore = storage.NewMemoryVectorStorage()
+			}
+			documentStore, err = storage.NewMongoDocumentStorage(
+				mongoStore.GetClient(),
+				mongoStore.GetDatabase(),
+				log,
+			)
+			if err != nil {
+				log.Warn("document storage fallback to memory", sl.Err(err))
+				documentStore = storage.NewMemoryDocumentStorage()
+			}
+			moderationStore, err = storage.NewMongoModerationStorage(
+				mongoStore.GetClient(),
+				mongoStore.GetDatabase(),
+				log,
+			)
+			if err != nil {
+				log.Warn("moderation storage fallback to memory", sl.Err(err))
+				moderationStore = storage.NewMemoryModerationStorage()
+			}
 			log.Info("using MongoDB storage")
 		}
 	} else {
-		store = storage.NewMemoryStorage()
+		store = storage.NewMemoryStorage(encoding.Count, conf.ContextTokens)
 		prefsStore = storage.NewMemoryPreferencesStorage()
+		usageStore = storage.NewMemoryUsageStorage()
+		settingsStore = storage.NewMemorySettingsStorage()
+		imageStore = storage.NewMemoryImageStorage()
+		vectorStore = storage.NewMemoryVectorStorage()
+		documentStore = storage.NewMemoryDocumentStorage()
+		moderationStore = storage.NewMemoryModerationStorage()
 		log.Info("using in-memory storage")
 	}
 
-	chat := ai.NewChat(conf, log, store)
+	provider := ai.NewFallbackProvider(ai.NewOpenAIProvider(conf, log), conf.Models.Fallback, log)
+	chat := ai.NewChat(conf, log, store, provider)
+	usageTracker := ai.NewUsageTracker(conf, log, usageStore)
+	chat.SetUsageTracker(usageTracker)
+	chat.SetSettingsStorage(settingsStore)
+	chat.SetImageStorage(imageStore)
+	chat.SetVectorStorage(vectorStore)
+	chat.SetDocumentStorage(documentStore)
+	chat.SetModerationStorage(moderationStore)
+	chat.SetFetcher(ai.NewFetcher(conf, log))
 
 	// Initialize preferences analyzer
-	prefsAnalyzer := ai.NewPreferencesAnalyzer(conf, log, store, prefsStore)
+	prefsAnalyzer := ai.NewPreferencesAnalyzer(conf, log, store, prefsStore, provider)
 	chat.SetPreferencesAnalyzer(prefsAnalyzer)
+	prefsAnalyzer.SetUsageTracker(usageTracker)
 	prefsAnalyzer.StartBackgroundAnalysis()
 	tgBot, err := bot.NewTgBot(conf, log)
 	if err != nil {
@@ -89,6 +181,8 @@ func main() {
 	}
 
 	tgBot.SetChat(chat)
+	chat.SetFileLoader(tgBot)
+	chat.SetImageSender(tgBot)
 
 	// Setup signal handling for graceful shutdown
 	sigChan := make(chan os.Signal, 1)
@@ -118,6 +212,24 @@ func main() {
 	if err := prefsStore.Close(); err != nil {
 		log.Error("error closing preferences storage", sl.Err(err))
 	}
+	if err := usageStore.Close(); err != nil {
+		log.Error("error closing usage storage", sl.Err(err))
+	}
+	if err := settingsStore.Close(); err != nil {
+		log.Error("error closing settings storage", sl.Err(err))
+	}
+	if err := imageStore.Close(); err != nil {
+		log.Error("error closing image storage", sl.Err(err))
+	}
+	if err := vectorStore.Close(); err != nil {
+		log.Error("error closing vector storage", sl.Err(err))
+	}
+	if err := documentStore.Close(); err != nil {
+		log.Error("error closing document storage", sl.Err(err))
+	}
+	if err := moderationStore.Close(); err != nil {
+		log.Error("error closing moderation storage", sl.Err(err))
+	}
 
 	log.Info("shutdown complete")
 }
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DocumentRecord is a file attached to a chat as a knowledge source
+type DocumentRecord struct {
+	Id        string    `bson:"id"` // short key for buttons, assigned on save
+	ChatId    int64     `bson:"chat_id"`
+	UserId    int64     `bson:"user_id"` // who sent the file
+	Name      string    `bson:"name"`
+	Size      int       `bson:"size"`  // bytes of the file
+	Pages     int       `bson:"pages"` // zero for text files
+	Chunks    int       `bson:"chunks"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+// DocumentChunk is a part of a document text with its embedding
+type DocumentChunk struct {
+	DocumentId string    `bson:"document_id"`
+	ChatId     int64     `bson:"chat_id"`
+	Name       string    `bson:"name"` // file name for citations
+	Page       int       `bson:"page"` // zero for text files
+	Text       string    `bson:"text"`
+	Model      string    `bson:"model"` // embedding model
+	Vector     []float32 `bson:"vector"`
+}
+
+// ScoredChunk is a found chunk with its cosine similarity to the query
+type ScoredChunk struct {
+	DocumentChunk
+	Score float64
+}
+
+// prepareDocument fills the key and creation time of a new record and links the chunks to it
+func prepareDocument(record *DocumentRecord, chunks []DocumentChunk) {
+	if record.Id == "" {
+		record.Id = primitive.NewObjectID().Hex()
+	}
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+	for i := range chunks {
+		chunks[i].DocumentId = record.Id
+		chunks[i].ChatId = record.ChatId
+	}
+}
+
+// addChunk scores the chunk against the query, the vector is not returned to callers
+func addChunk(top *topScored[ScoredChunk], vector []float32, chunk DocumentChunk) {
+	score := cosine(vector, chunk.Vector)
+	chunk.Vector = nil
+	top.add(ScoredChunk{DocumentChunk: chunk, Score: score}, score)
+}
+
+// DocumentStorage defines the interface for documents of chats and their embedded chunks
+type DocumentStorage interface {
+	// SaveDocument stores the document with its chunks
+	SaveDocument(record *DocumentRecord, chunks []DocumentChunk) error
+	// ListDocuments returns documents of the chat, oldest first
+	ListDocuments(chatId int64) ([]DocumentRecord, error)
+	// DeleteDocument removes the document of the chat with its chunks and frees its space (returns false if it is unknown)
+	DeleteDocument(chatId int64, id string) (bool, error)
+	// ReserveSpace atomically adds size bytes to the space taken by documents of the chat unless the total
+	// would pass limit; returns the bytes taken before and whether the space was reserved. No limit when it is not positive
+	ReserveSpace(chatId int64, size, limit int) (int, bool, error)
+	// ReleaseSpace returns the space reserved for a document that was not saved
+	ReleaseSpace(chatId int64, size int) error
+	// SearchChunks returns up to limit chunks of the chat embedded by the model, most similar to the vector first
+	SearchChunks(chatId int64, model string, vector []float32, limit int) ([]ScoredChunk, error)
+	// Close closes the storage connection
+	Close() error
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"slices"
+	"sync"
+)
+
+// MemoryDocumentStorage is an in-memory implementation of DocumentStorage, searched by brute force
+type MemoryDocumentStorage struct {
+	documents map[int64][]DocumentRecord
+	chunks    map[string][]DocumentChunk
+	space     map[int64]int // bytes of saved and reserved documents by chat
+	mutex     sync.RWMutex
+}
+
+// NewMemoryDocumentStorage creates a new in-memory document storage
+func NewMemoryDocumentStorage() *MemoryDocumentStorage {
+	return &MemoryDocumentStorage{
+		documents: make(map[int64][]DocumentRecord),
+		chunks:    make(map[string][]DocumentChunk),
+		space:     make(map[int64]int),
+	}
+}
+
+// SaveDocument stores the document with its chunks
+func (m *MemoryDocumentStorage) SaveDocument(record *DocumentRecord, chunks []DocumentChunk) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	prepareDocument(record, chunks)
+	m.documents[record.ChatId] = append(m.documents[record.ChatId], *record)
+	m.chunks[record.Id] = chunks
+	return nil
+}
+
+// ListDocuments returns documents of the chat, oldest first
+func (m *MemoryDocumentStorage) ListDocuments(chatId int64) ([]DocumentRecord, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return slices.Clone(m.documents[chatId]), nil
+}
+
+// DeleteDocument removes the document of the chat with its chunks
+func (m *MemoryDocumentStorage) DeleteDocument(chatId int64, id string) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	documents := m.documents[chatId]
+	i := slices.IndexFunc(documents, func(record DocumentRecord) bool { return record.Id == id })
+	if i < 0 {
+		return false, nil
+	}
+	m.space[chatId] -= documents[i].Size
+	m.documents[chatId] = slices.Delete(documents, i, i+1)
+	delete(m.chunks, id)
+	return true, nil
+}
+
+// ReserveSpace checks and takes the space under the lock of the storage
+func (m *MemoryDocumentStorage) ReserveSpace(chatId int64, size, limit int) (int, bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	used := m.space[chatId]
+	if limit > 0 && used+size > limit {
+		return used, false, nil
+	}
+	m.space[chatId] = used + size
+	return used, true, nil
+}
+
+// ReleaseSpace returns the space reserved for a document that was not saved
+func (m *MemoryDocumentStorage) ReleaseSpace(chatId int64, size int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.space[chatId] -= size
+	return nil
+}
+
+// SearchChunks compares the vector with every chunk of the chat
+func (m *MemoryDocumentStorage) SearchChunks(chatId int64, model string, vector []float32, limit int) ([]ScoredChunk, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	top := topScored[ScoredChunk]{limit: limit}
+	for _, record := range m.documents[chatId] {
+		for _, chunk := range m.chunks[record.Id] {
+			if chunk.Model == model {
+				addChunk(&top, vector, chunk)
+			}
+		}
+	}
+	return top.items, nil
+}
+
+// Close closes the storage (no-op for memory)
+func (m *MemoryDocumentStorage) Close() error {
+	return nil
+}
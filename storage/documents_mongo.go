@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	documentsCollectionName = "documents"
+	chunksCollectionName    = "document_chunks"
+	spaceCollectionName     = "document_space"
+)
+
+// MongoDocumentStorage is a MongoDB implementation of DocumentStorage; chunk vectors are compared in the bot
+// like in MongoVectorStorage. The space taken by documents of a chat is counted in one record per chat,
+// so that it is reserved with a conditional update
+type MongoDocumentStorage struct {
+	documents *mongo.Collection
+	chunks    *mongo.Collection
+	space     *mongo.Collection
+	log       *slog.Logger
+}
+
+// documentSpace counts bytes of saved and reserved documents of a chat
+type documentSpace struct {
+	ChatId int64 `bson:"chat_id"`
+	Used   int   `bson:"used"`
+}
+
+// NewMongoDocumentStorage creates a new MongoDB document storage
+func NewMongoDocumentStorage(client *mongo.Client, database string, log *slog.Logger) (*MongoDocumentStorage, error) {
+	documents := client.Database(database).Collection(documentsCollectionName)
+	chunks := client.Database(database).Collection(chunksCollectionName)
+	space := client.Database(database).Collection(spaceCollectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := documents.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "chat_id", Value: 1}, {Key: "created_at", Value: 1}},
+	})
+	if err != nil {
+		log.Warn("creating documents index", slog.String("error", err.Error()))
+	}
+	_, err = chunks.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "chat_id", Value: 1}, {Key: "model", Value: 1}}},
+		{Keys: bson.D{{Key: "document_id", Value: 1}}},
+	})
+	if err != nil {
+		log.Warn("creating document chunks index", slog.String("error", err.Error()))
+	}
+	// one space record per chat, concurrent uploads creating it can't add a second one
+	_, err = space.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "chat_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Warn("creating document space index", slog.String("error", err.Error()))
+	}
+
+	return &MongoDocumentStorage{
+		documents: documents,
+		chunks:    chunks,
+		space:     space,
+		log:       log,
+	}, nil
+}
+
+// SaveDocument stores the chunks first, so that a listed document is always searchable
+func (m *MongoDocumentStorage) SaveDocument(record *DocumentRecord, chunks []DocumentChunk) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	prepareDocument(record, chunks)
+	if len(chunks) > 0 {
+		docs := make([]interface{}, len(chunks))
+		for i := range chunks {
+			docs[i] = chunks[i]
+		}
+		if _, err := m.chunks.InsertMany(ctx, docs); err != nil {
+			return fmt.Errorf("inserting chunks: %w", err)
+		}
+	}
+	if _, err := m.documents.InsertOne(ctx, record); err != nil {
+		return fmt.Errorf("inserting document: %w", err)
+	}
+	return nil
+}
+
+// ListDocuments returns documents of the chat, oldest first
+func (m *MongoDocumentStorage) ListDocuments(chatId int64) ([]DocumentRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := m.documents.Find(ctx, bson.M{"chat_id": chatId}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("finding documents: %w", err)
+	}
+	var records []DocumentRecord
+	if err = cursor.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("decoding documents: %w", err)
+	}
+	return records, nil
+}
+
+// DeleteDocument removes the document of the chat with its chunks
+func (m *MongoDocumentStorage) DeleteDocument(chatId int64, id string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var record DocumentRecord
+	err := m.documents.FindOneAndDelete(ctx, bson.M{"chat_id": chatId, "id": id}).Decode(&record)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("deleting document: %w", err)
+	}
+	if _, err = m.chunks.DeleteMany(ctx, bson.M{"document_id": id}); err != nil {
+		return true, fmt.Errorf("deleting chunks: %w", err)
+	}
+	if err = m.addSpace(ctx, chatId, -record.Size); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+// ReserveSpace increments the space of the chat only if the record still has room for the document;
+// concurrent uploads of several bot instances can't pass the limit together
+func (m *MongoDocumentStorage) ReserveSpace(chatId int64, size, limit int) (int, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := m.initSpace(ctx, chatId); err != nil {
+		return 0, false, err
+	}
+	filter := bson.M{"chat_id": chatId}
+	if limit > 0 {
+		filter["used"] = bson.M{"$lte": limit - size}
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+	var space documentSpace
+	err := m.space.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"used": size}}, opts).Decode(&space)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// no room: read what is taken for the error message
+		if err = m.space.FindOne(ctx, bson.M{"chat_id": chatId}).Decode(&space); err != nil {
+			return 0, false, fmt.Errorf("reading document space: %w", err)
+		}
+		return space.Used, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("reserving document space: %w", err)
+	}
+	return space.Used, true, nil
+}
+
+// ReleaseSpace returns the space reserved for a document that was not saved
+func (m *MongoDocumentStorage) ReleaseSpace(chatId int64, size int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return m.addSpace(ctx, chatId, -size)
+}
+
+func (m *MongoDocumentStorage) addSpace(ctx context.Context, chatId int64, size int) error {
+	if _, err := m.space.UpdateOne(ctx, bson.M{"chat_id": chatId}, bson.M{"$inc": bson.M{"used": size}}); err != nil {
+		return fmt.Errorf("updating document space: %w", err)
+	}
+	return nil
+}
+
+// initSpace creates the space record of the chat from its documents, for chats that had documents
+// before the space was counted
+func (m *MongoDocumentStorage) initSpace(ctx context.Context, chatId int64) error {
+	count, err := m.space.CountDocuments(ctx, bson.M{"chat_id": chatId})
+	if err != nil {
+		return fmt.Errorf("finding document space: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+	cursor, err := m.documents.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"chat_id": chatId}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "used": bson.M{"$sum": "$size"}}}},
+	})
+	if err != nil {
+		return fmt.Errorf("summing document sizes: %w", err)
+	}
+	var sums []struct {
+		Used int `bson:"used"`
+	}
+	if err = cursor.All(ctx, &sums); err != nil {
+		return fmt.Errorf("decoding document sizes: %w", err)
+	}
+	used := 0
+	if len(sums) > 0 {
+		used = sums[0].Used
+	}
+	// $setOnInsert keeps the record of a concurrent upload that created it first
+	_, err = m.space.UpdateOne(ctx, bson.M{"chat_id": chatId},
+		bson.M{"$setOnInsert": bson.M{"used": used}}, options.Update().SetUpsert(true))
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("creating document space: %w", err)
+	}
+	return nil
+}
+
+// SearchChunks streams the chunks of the chat and keeps the most similar ones
+func (m *MongoDocumentStorage) SearchChunks(chatId int64, model string, vector []float32, limit int) ([]ScoredChunk, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := m.chunks.Find(ctx, bson.M{"chat_id": chatId, "model": model})
+	if err != nil {
+		return nil, fmt.Errorf("finding chunks: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	top := topScored[ScoredChunk]{limit: limit}
+	for cursor.Next(ctx) {
+		var chunk DocumentChunk
+		if err := cursor.Decode(&chunk); err != nil {
+			return nil, fmt.Errorf("decoding chunk: %w", err)
+		}
+		addChunk(&top, vector, chunk)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("reading chunks: %w", err)
+	}
+	return top.items, nil
+}
+
+// Close closes the storage (client is shared, don't disconnect here)
+func (m *MongoDocumentStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestReserveSpace(t *testing.T) {
+	store := NewMemoryDocumentStorage()
+	steps := []struct {
+		name     string
+		size     int
+		release  bool
+		wantUsed int
+		wantOk   bool
+	}{
+		{"first", 600, false, 0, true},
+		{"does not fit", 500, false, 600, false},
+		{"fits exactly", 400, false, 600, true},
+		{"full", 1, false, 1000, false},
+		{"released", 400, true, 0, false},
+		{"room again", 300, false, 600, true},
+	}
+	for _, step := range steps {
+		if step.release {
+			if err := store.ReleaseSpace(1, step.size); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		used, ok, err := store.ReserveSpace(1, step.size, 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if used != step.wantUsed || ok != step.wantOk {
+			t.Errorf("%s: ReserveSpace(%d) = %d, %v, want %d, %v", step.name, step.size, used, ok, step.wantUsed, step.wantOk)
+		}
+	}
+	if _, ok, _ := store.ReserveSpace(2, 1000, 1000); !ok {
+		t.Error("space of another chat is shared")
+	}
+	if _, ok, _ := store.ReserveSpace(3, 1<<40, 0); !ok {
+		t.Error("no limit refused the document")
+	}
+}
+
+func TestDeleteDocumentFreesSpace(t *testing.T) {
+	store := NewMemoryDocumentStorage()
+	if _, ok, _ := store.ReserveSpace(1, 800, 1000); !ok {
+		t.Fatal("first document refused")
+	}
+	record := &DocumentRecord{ChatId: 1, Name: "a.txt", Size: 800}
+	if err := store.SaveDocument(record, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := store.ReserveSpace(1, 800, 1000); ok {
+		t.Fatal("second document fits with the first one saved")
+	}
+	if deleted, err := store.DeleteDocument(1, record.Id); err != nil || !deleted {
+		t.Fatalf("DeleteDocument() = %v, %v", deleted, err)
+	}
+	if _, ok, _ := store.ReserveSpace(1, 800, 1000); !ok {
+		t.Error("space of the deleted document is not freed")
+	}
+}
+
+// concurrent uploads can't pass the limit together
+func TestReserveSpaceConcurrent(t *testing.T) {
+	store := NewMemoryDocumentStorage()
+	var reserved atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok, _ := store.ReserveSpace(1, 100, 1000); ok {
+				reserved.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := reserved.Load(); got != 10 {
+		t.Errorf("%d documents of 100 bytes reserved within 1000, want 10", got)
+	}
+}
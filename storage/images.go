@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ImageRecord is an image sent by the bot, linked to the image it was derived from
+type ImageRecord struct {
+	Id            string    `bson:"id"`                       // short key for buttons, assigned on save
+	FileId        string    `bson:"file_id"`                  // Telegram file ID of the sent image
+	ParentFileId  string    `bson:"parent_file_id,omitempty"` // source of edits and variations
+	ChatId        int64     `bson:"chat_id"`
+	UserId        int64     `bson:"user_id"`
+	Operation     string    `bson:"operation"` // generate, edit or variation
+	Prompt        string    `bson:"prompt"`
+	RevisedPrompt string    `bson:"revised_prompt,omitempty"`
+	Style         string    `bson:"style,omitempty"`
+	Model         string    `bson:"model"`
+	Path          string    `bson:"path,omitempty"` // local copy of the image
+	CreatedAt     time.Time `bson:"created_at"`
+}
+
+// prepareImage fills the key and creation time of a new record
+func prepareImage(record *ImageRecord) {
+	if record.Id == "" {
+		record.Id = primitive.NewObjectID().Hex()
+	}
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+}
+
+// ImageStorage defines the interface for sent images persistence
+type ImageStorage interface {
+	SaveImage(record *ImageRecord) error
+	// GetImage returns the record of a sent image (returns nil if it is unknown)
+	GetImage(fileId string) (*ImageRecord, error)
+	// GetImageById returns the record by its key (returns nil if it is unknown)
+	GetImageById(id string) (*ImageRecord, error)
+	// ListImages returns images of the user, newest first
+	ListImages(userId int64, limit int) ([]ImageRecord, error)
+	// Close closes the storage connection
+	Close() error
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+)
+
+// MemoryImageStorage is an in-memory implementation of ImageStorage
+type MemoryImageStorage struct {
+	images map[string]ImageRecord
+	mutex  sync.RWMutex
+}
+
+// NewMemoryImageStorage creates a new in-memory image storage
+func NewMemoryImageStorage() *MemoryImageStorage {
+	return &MemoryImageStorage{
+		images: make(map[string]ImageRecord),
+	}
+}
+
+// SaveImage stores the image record
+func (m *MemoryImageStorage) SaveImage(record *ImageRecord) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	prepareImage(record)
+	m.images[record.FileId] = *record
+	return nil
+}
+
+// GetImage returns the record of a sent image
+func (m *MemoryImageStorage) GetImage(fileId string) (*ImageRecord, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if record, ok := m.images[fileId]; ok {
+		return &record, nil
+	}
+	return nil, nil
+}
+
+// GetImageById returns the record by its key
+func (m *MemoryImageStorage) GetImageById(id string) (*ImageRecord, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	for _, record := range m.images {
+		if record.Id == id {
+			return &record, nil
+		}
+	}
+	return nil, nil
+}
+
+// ListImages returns images of the user, newest first
+func (m *MemoryImageStorage) ListImages(userId int64, limit int) ([]ImageRecord, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	var records []ImageRecord
+	for _, record := range m.images {
+		if record.UserId == userId {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].CreatedAt.After(records[j].CreatedAt)
+	})
+	if limit > 0 && len(records) > limit {
+		records = records[:limit]
+	}
+	return records, nil
+}
+
+// Close closes the storage (no-op for memory)
+func (m *MemoryImageStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const imagesCollectionName = "images"
+
+// MongoImageStorage is a MongoDB implementation of ImageStorage
+type MongoImageStorage struct {
+	collection *mongo.Collection
+	log        *slog.Logger
+}
+
+// NewMongoImageStorage creates a new MongoDB image storage
+func NewMongoImageStorage(client *mongo.Client, database string, log *slog.Logger) (*MongoImageStorage, error) {
+	collection := client.Database(database).Collection(imagesCollectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "file_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "id", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+	})
+	if err != nil {
+		log.Warn("creating images index", slog.String("error", err.Error()))
+	}
+
+	return &MongoImageStorage{
+		collection: collection,
+		log:        log,
+	}, nil
+}
+
+// SaveImage stores the image record
+func (m *MongoImageStorage) SaveImage(record *ImageRecord) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	prepareImage(record)
+	opts := options.Replace().SetUpsert(true)
+	_, err := m.collection.ReplaceOne(ctx, bson.M{"file_id": record.FileId}, record, opts)
+	return err
+}
+
+// GetImage returns the record of a sent image
+func (m *MongoImageStorage) GetImage(fileId string) (*ImageRecord, error) {
+	return m.findImage(bson.M{"file_id": fileId})
+}
+
+// GetImageById returns the record by its key
+func (m *MongoImageStorage) GetImageById(id string) (*ImageRecord, error) {
+	return m.findImage(bson.M{"id": id})
+}
+
+// ListImages returns images of the user, newest first
+func (m *MongoImageStorage) ListImages(userId int64, limit int) ([]ImageRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	cursor, err := m.collection.Find(ctx, bson.M{"user_id": userId}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("finding images: %w", err)
+	}
+	var records []ImageRecord
+	if err = cursor.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("decoding images: %w", err)
+	}
+	return records, nil
+}
+
+func (m *MongoImageStorage) findImage(filter bson.M) (*ImageRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var record ImageRecord
+	err := m.collection.FindOne(ctx, filter).Decode(&record)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("finding image: %w", err)
+	}
+	return &record, nil
+}
+
+// Close closes the storage (client is shared, don't disconnect here)
+func (m *MongoImageStorage) Close() error {
+	return nil
+}
@@ -6,37 +6,50 @@ import (
 	"time"
 )
 
-const maxTokens = 20000
+const maxTokens = 8000
 
 type MemoryStorage struct {
-	contexts map[int64]*DialogContext
-	mutex    sync.RWMutex
+	contexts  map[int64]*DialogContext
+	count     TokenCounter
+	maxTokens int
+	mutex     sync.RWMutex
 }
 
-func NewMemoryStorage() *MemoryStorage {
+// NewMemoryStorage creates an in-memory storage; context is trimmed when it exceeds limit tokens
+func NewMemoryStorage(count TokenCounter, limit int) *MemoryStorage {
+	if limit <= 0 {
+		limit = maxTokens
+	}
 	return &MemoryStorage{
-		contexts: make(map[int64]*DialogContext),
+		contexts:  make(map[int64]*DialogContext),
+		count:     count,
+		maxTokens: limit,
 	}
 }
 
+// GetUserContext returns a copy of the context, the stored one keeps changing under the lock
 func (m *MemoryStorage) GetUserContext(userId int64) (*DialogContext, error) {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
-	return m.contexts[userId], nil
+	context, ok := m.contexts[userId]
+	if !ok {
+		return nil, nil
+	}
+	return context.clone(), nil
 }
 
 func (m *MemoryStorage) UpdateUserContext(userId int64, message Message) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	message.Tokens = len([]rune(message.Text))
+	message.Tokens = messageTokens(m.count, message)
 	message.Timestamp = time.Now()
 
 	if context, ok := m.contexts[userId]; ok {
 		context.Tokens += message.Tokens
 
 		// Remove old messages if over token limit
-		for context.Tokens > maxTokens && len(context.Messages) > 0 {
+		for context.Tokens > m.maxTokens && len(context.Messages) > 0 {
 			log.Printf("MemoryStorage: removing message from context of user %d", userId)
 			tokensToRemove := context.Messages[0].Tokens
 			context.Messages = context.Messages[1:]
@@ -47,10 +60,11 @@ func (m *MemoryStorage) UpdateUserContext(userId int64, message Message) error {
 		context.UpdatedAt = time.Now()
 	} else {
 		m.contexts[userId] = &DialogContext{
-			UserId:    userId,
-			Messages:  []Message{message},
-			Tokens:    message.Tokens,
-			UpdatedAt: time.Now(),
+			UserId:     userId,
+			Messages:   []Message{message},
+			Tokens:     message.Tokens,
+			UpdatedAt:  time.Now(),
+			Generation: newGeneration(),
 		}
 	}
 	return nil
@@ -64,16 +78,31 @@ func (m *MemoryStorage) SetTopic(userId int64, topic string) error {
 		context.Topic = topic
 	} else {
 		m.contexts[userId] = &DialogContext{
-			UserId:    userId,
-			Topic:     topic,
-			Messages:  []Message{},
-			Tokens:    0,
-			UpdatedAt: time.Now(),
+			UserId:     userId,
+			Topic:      topic,
+			Messages:   []Message{},
+			Tokens:     0,
+			UpdatedAt:  time.Now(),
+			Generation: newGeneration(),
 		}
 	}
 	return nil
 }
 
+func (m *MemoryStorage) SetSummary(userId, generation int64, summary string, until time.Time) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	context, ok := m.contexts[userId]
+	if !ok || context.Generation != generation {
+		return nil // cleared while summarizing
+	}
+	context.Summary = summary
+	context.Messages, context.Tokens = dropSummarized(context.Messages, until)
+	context.UpdatedAt = time.Now()
+	return nil
+}
+
 func (m *MemoryStorage) ClearUserContext(userId int64) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
@@ -0,0 +1,29 @@
+package storage
+
+import "time"
+
+// ModerationRecord is a moderation decision kept in the audit log
+type ModerationRecord struct {
+	ChatId     int64              `bson:"chat_id"`
+	UserId     int64              `bson:"user_id"`
+	Kind       string             `bson:"kind"`   // prompt or image
+	Source     string             `bson:"source"` // keywords or endpoint
+	Blocked    bool               `bson:"blocked"`
+	Categories []string           `bson:"categories"`     // categories over their thresholds
+	Scores     map[string]float64 `bson:"scores"`         // category scores of the endpoint
+	Text       string             `bson:"text,omitempty"` // kept for blocked content only
+	Error      string             `bson:"error,omitempty"`
+	Timestamp  time.Time          `bson:"timestamp"`
+}
+
+// ModerationStorage defines the interface for the moderation audit log
+type ModerationStorage interface {
+	// SaveDecision appends the decision to the log
+	SaveDecision(record *ModerationRecord) error
+	// ListDecisions returns decisions of the chat, newest first; only blocked ones when blocked is set
+	ListDecisions(chatId int64, blocked bool, limit int) ([]ModerationRecord, error)
+	// CountDecisions returns the number of all and of blocked decisions of the chat since the time
+	CountDecisions(chatId int64, since time.Time) (total, blocked int, err error)
+	// Close closes the storage connection
+	Close() error
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// maxMemoryDecisions caps the in-memory audit log, the oldest decisions are dropped
+const maxMemoryDecisions = 10000
+
+// MemoryModerationStorage is an in-memory implementation of ModerationStorage
+type MemoryModerationStorage struct {
+	records []ModerationRecord
+	mutex   sync.RWMutex
+}
+
+// NewMemoryModerationStorage creates a new in-memory moderation audit log
+func NewMemoryModerationStorage() *MemoryModerationStorage {
+	return &MemoryModerationStorage{}
+}
+
+// SaveDecision appends the decision to the log
+func (m *MemoryModerationStorage) SaveDecision(record *ModerationRecord) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if record.Timestamp.IsZero() {
+		record.Timestamp = time.Now()
+	}
+	m.records = append(m.records, *record)
+	if len(m.records) > maxMemoryDecisions {
+		m.records = m.records[len(m.records)-maxMemoryDecisions:]
+	}
+	return nil
+}
+
+// ListDecisions returns decisions of the chat, newest first
+func (m *MemoryModerationStorage) ListDecisions(chatId int64, blocked bool, limit int) ([]ModerationRecord, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	var records []ModerationRecord
+	for i := len(m.records) - 1; i >= 0 && len(records) < limit; i-- {
+		record := m.records[i]
+		if record.ChatId == chatId && (!blocked || record.Blocked) {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// CountDecisions returns the number of all and of blocked decisions of the chat since the time
+func (m *MemoryModerationStorage) CountDecisions(chatId int64, since time.Time) (total, blocked int, err error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	for _, record := range m.records {
+		if record.ChatId != chatId || record.Timestamp.Before(since) {
+			continue
+		}
+		total++
+		if record.Blocked {
+			blocked++
+		}
+	}
+	return total, blocked, nil
+}
+
+// Close closes the storage (no-op for memory)
+func (m *MemoryModerationStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const moderationCollectionName = "moderation_log"
+
+// MongoModerationStorage is a MongoDB implementation of ModerationStorage
+type MongoModerationStorage struct {
+	collection *mongo.Collection
+	log        *slog.Logger
+}
+
+// NewMongoModerationStorage creates a new MongoDB moderation audit log
+func NewMongoModerationStorage(client *mongo.Client, database string, log *slog.Logger) (*MongoModerationStorage, error) {
+	collection := client.Database(database).Collection(moderationCollectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "chat_id", Value: 1}, {Key: "timestamp", Value: -1}},
+	})
+	if err != nil {
+		log.Warn("creating moderation log index", slog.String("error", err.Error()))
+	}
+
+	return &MongoModerationStorage{
+		collection: collection,
+		log:        log,
+	}, nil
+}
+
+// SaveDecision appends the decision to the log
+func (m *MongoModerationStorage) SaveDecision(record *ModerationRecord) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if record.Timestamp.IsZero() {
+		record.Timestamp = time.Now()
+	}
+	if _, err := m.collection.InsertOne(ctx, record); err != nil {
+		return fmt.Errorf("inserting moderation decision: %w", err)
+	}
+	return nil
+}
+
+// ListDecisions returns decisions of the chat, newest first
+func (m *MongoModerationStorage) ListDecisions(chatId int64, blocked bool, limit int) ([]ModerationRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"chat_id": chatId}
+	if blocked {
+		filter["blocked"] = true
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}}).SetLimit(int64(limit))
+	cursor, err := m.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("finding moderation decisions: %w", err)
+	}
+	var records []ModerationRecord
+	if err = cursor.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("decoding moderation decisions: %w", err)
+	}
+	return records, nil
+}
+
+// CountDecisions returns the number of all and of blocked decisions of the chat since the time
+func (m *MongoModerationStorage) CountDecisions(chatId int64, since time.Time) (total, blocked int, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"chat_id": chatId, "timestamp": bson.M{"$gte": since}}
+	all, err := m.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return 0, 0, fmt.Errorf("counting moderation decisions: %w", err)
+	}
+	filter["blocked"] = true
+	flagged, err := m.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return 0, 0, fmt.Errorf("counting blocked decisions: %w", err)
+	}
+	return int(all), int(flagged), nil
+}
+
+// Close closes the storage (client is shared, don't disconnect here)
+func (m *MongoModerationStorage) Close() error {
+	return nil
+}
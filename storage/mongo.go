@@ -13,16 +13,19 @@ import (
 
 const (
 	collectionName = "dialog_contexts"
-	maxTokensMongo = 20000
+	maxTokensMongo = 8000
 )
 
 type MongoStorage struct {
 	client     *mongo.Client
 	collection *mongo.Collection
+	count      TokenCounter
+	maxTokens  int
 	log        *slog.Logger
 }
 
-func NewMongoStorage(uri, database string, log *slog.Logger) (*MongoStorage, error) {
+// NewMongoStorage connects to MongoDB; context is trimmed when it exceeds limit tokens
+func NewMongoStorage(uri, database string, count TokenCounter, limit int, log *slog.Logger) (*MongoStorage, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -46,9 +49,15 @@ func NewMongoStorage(uri, database string, log *slog.Logger) (*MongoStorage, err
 		log.Warn("creating index", slog.String("error", err.Error()))
 	}
 
+	if limit <= 0 {
+		limit = maxTokensMongo
+	}
+
 	return &MongoStorage{
 		client:     client,
 		collection: collection,
+		count:      count,
+		maxTokens:  limit,
 		log:        log,
 	}, nil
 }
@@ -72,7 +81,7 @@ func (m *MongoStorage) UpdateUserContext(userId int64, message Message) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	message.Tokens = len([]rune(message.Text))
+	message.Tokens = messageTokens(m.count, message)
 	message.Timestamp = time.Now()
 
 	existing, err := m.GetUserContext(userId)
@@ -82,10 +91,11 @@ func (m *MongoStorage) UpdateUserContext(userId int64, message Message) error {
 
 	if existing == nil {
 		newCtx := &DialogContext{
-			UserId:    userId,
-			Messages:  []Message{message},
-			Tokens:    message.Tokens,
-			UpdatedAt: time.Now(),
+			UserId:     userId,
+			Messages:   []Message{message},
+			Tokens:     message.Tokens,
+			UpdatedAt:  time.Now(),
+			Generation: newGeneration(),
 		}
 		_, err = m.collection.InsertOne(ctx, newCtx)
 		return err
@@ -93,7 +103,7 @@ func (m *MongoStorage) UpdateUserContext(userId int64, message Message) error {
 
 	existing.Tokens += message.Tokens
 
-	for existing.Tokens > maxTokensMongo && len(existing.Messages) > 0 {
+	for existing.Tokens > m.maxTokens && len(existing.Messages) > 0 {
 		tokensToRemove := existing.Messages[0].Tokens
 		existing.Messages = existing.Messages[1:]
 		existing.Tokens -= tokensToRemove
@@ -116,9 +126,10 @@ func (m *MongoStorage) SetTopic(userId int64, topic string) error {
 			"updated_at": time.Now(),
 		},
 		"$setOnInsert": bson.M{
-			"user_id":  userId,
-			"messages": []Message{},
-			"tokens":   0,
+			"user_id":    userId,
+			"messages":   []Message{},
+			"tokens":     0,
+			"generation": newGeneration(),
 		},
 	}
 
@@ -127,6 +138,29 @@ func (m *MongoStorage) SetTopic(userId int64, topic string) error {
 	return err
 }
 
+func (m *MongoStorage) SetSummary(userId, generation int64, summary string, until time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	existing, err := m.GetUserContext(userId)
+	if err != nil || existing == nil || existing.Generation != generation {
+		return err // cleared while summarizing
+	}
+
+	existing.Summary = summary
+	existing.Messages, existing.Tokens = dropSummarized(existing.Messages, until)
+	existing.UpdatedAt = time.Now()
+
+	// the generation is checked again by the filter, a context cleared meanwhile is not replaced
+	filter := bson.M{"user_id": userId, "generation": generation}
+	if generation == 0 {
+		// contexts stored before generations were introduced
+		filter["generation"] = bson.M{"$in": bson.A{0, nil}}
+	}
+	_, err = m.collection.ReplaceOne(ctx, filter, existing)
+	return err
+}
+
 func (m *MongoStorage) ClearUserContext(userId int64) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
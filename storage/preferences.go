@@ -18,15 +18,15 @@ type UserPreferences struct {
 	UpdatedAt         time.Time `bson:"updated_at"`
 }
 
-// PreferencesAnalysis is used for parsing AI analysis response
+// PreferencesAnalysis is used for parsing AI analysis response, tags describe its JSON schema
 type PreferencesAnalysis struct {
-	PreferredLanguage string   `json:"preferred_language"`
-	Formality         string   `json:"formality"`
-	Verbosity         string   `json:"verbosity"`
-	FavoriteTopics    []string `json:"favorite_topics"`
-	TechnicalLevel    string   `json:"technical_level"`
-	HumorPreference   string   `json:"humor_preference"`
-	ResponseLength    string   `json:"response_length"`
+	PreferredLanguage string   `json:"preferred_language" desc:"the language the user writes in most, e.g. English"`
+	Formality         string   `json:"formality" enum:"formal,informal,neutral"`
+	Verbosity         string   `json:"verbosity" enum:"verbose,concise,balanced"`
+	FavoriteTopics    []string `json:"favorite_topics" desc:"topics the user discusses frequently"`
+	TechnicalLevel    string   `json:"technical_level" enum:"beginner,intermediate,expert"`
+	HumorPreference   string   `json:"humor_preference" enum:"none,occasional,frequent"`
+	ResponseLength    string   `json:"response_length" enum:"short,medium,long"`
 }
 
 // PreferencesStorage defines the interface for user preferences persistence
@@ -0,0 +1,26 @@
+package storage
+
+import "time"
+
+// ChatSettings stores options chosen by users for a chat
+type ChatSettings struct {
+	ChatId        int64  `bson:"chat_id"`
+	VoiceReplies  bool   `bson:"voice_replies"`   // answer with voice notes instead of text
+	ImageStyle    string `bson:"image_style"`     // style preset of generated images, empty for the default
+	Model         string `bson:"model"`           // chat model chosen with /model, empty for the default
+	NoImageIntent bool   `bson:"no_image_intent"` // don't look for image requests in messages
+	Timezone      string `bson:"timezone"`        // IANA timezone chosen with /timezone, empty for the default
+	// ModerationThresholds override category thresholds of the config in the chat
+	ModerationThresholds map[string]float64 `bson:"moderation_thresholds,omitempty"`
+	UpdatedAt            time.Time          `bson:"updated_at"`
+}
+
+// SettingsStorage defines the interface for chat settings persistence
+type SettingsStorage interface {
+	// GetChatSettings retrieves settings of a chat (returns nil if none exist)
+	GetChatSettings(chatId int64) (*ChatSettings, error)
+	// SaveChatSettings creates or updates chat settings
+	SaveChatSettings(settings *ChatSettings) error
+	// Close closes the storage connection
+	Close() error
+}
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// MemorySettingsStorage is an in-memory implementation of SettingsStorage
+type MemorySettingsStorage struct {
+	settings map[int64]ChatSettings
+	mutex    sync.RWMutex
+}
+
+// NewMemorySettingsStorage creates a new in-memory settings storage
+func NewMemorySettingsStorage() *MemorySettingsStorage {
+	return &MemorySettingsStorage{
+		settings: make(map[int64]ChatSettings),
+	}
+}
+
+// GetChatSettings retrieves settings of a chat
+func (m *MemorySettingsStorage) GetChatSettings(chatId int64) (*ChatSettings, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if settings, ok := m.settings[chatId]; ok {
+		return &settings, nil
+	}
+	return nil, nil
+}
+
+// SaveChatSettings creates or updates chat settings
+func (m *MemorySettingsStorage) SaveChatSettings(settings *ChatSettings) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	settings.UpdatedAt = time.Now()
+	m.settings[settings.ChatId] = *settings
+	return nil
+}
+
+// Close closes the storage (no-op for memory)
+func (m *MemorySettingsStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const settingsCollectionName = "chat_settings"
+
+// MongoSettingsStorage is a MongoDB implementation of SettingsStorage
+type MongoSettingsStorage struct {
+	collection *mongo.Collection
+	log        *slog.Logger
+}
+
+// NewMongoSettingsStorage creates a new MongoDB settings storage
+func NewMongoSettingsStorage(client *mongo.Client, database string, log *slog.Logger) (*MongoSettingsStorage, error) {
+	collection := client.Database(database).Collection(settingsCollectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "chat_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Warn("creating settings index", slog.String("error", err.Error()))
+	}
+
+	return &MongoSettingsStorage{
+		collection: collection,
+		log:        log,
+	}, nil
+}
+
+// GetChatSettings retrieves settings of a chat
+func (m *MongoSettingsStorage) GetChatSettings(chatId int64) (*ChatSettings, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var settings ChatSettings
+	err := m.collection.FindOne(ctx, bson.M{"chat_id": chatId}).Decode(&settings)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("finding settings: %w", err)
+	}
+	return &settings, nil
+}
+
+// SaveChatSettings creates or updates chat settings
+func (m *MongoSettingsStorage) SaveChatSettings(settings *ChatSettings) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	settings.UpdatedAt = time.Now()
+	opts := options.Replace().SetUpsert(true)
+	_, err := m.collection.ReplaceOne(ctx, bson.M{"chat_id": settings.ChatId}, settings, opts)
+	return err
+}
+
+// Close closes the storage (client is shared, don't disconnect here)
+func (m *MongoSettingsStorage) Close() error {
+	return nil
+}
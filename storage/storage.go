@@ -2,9 +2,13 @@ package storage
 
 import "time"
 
+// ImageTokens approximates prompt tokens of a picture attached to a message
+const ImageTokens = 765
+
 type Message struct {
 	IsUser    bool      `bson:"is_user"`
 	Text      string    `bson:"text"`
+	Image     string    `bson:"image,omitempty"` // Telegram file ID of an attached picture
 	Tokens    int       `bson:"tokens"`
 	Timestamp time.Time `bson:"timestamp"`
 }
@@ -12,15 +16,62 @@ type Message struct {
 type DialogContext struct {
 	UserId    int64     `bson:"user_id"`
 	Topic     string    `bson:"topic"`
+	Summary   string    `bson:"summary"` // condensed earlier turns of the dialog
 	Messages  []Message `bson:"messages"`
 	Tokens    int       `bson:"tokens"`
 	UpdatedAt time.Time `bson:"updated_at"`
+	// Generation is set when the context is created, a cleared and restarted dialog gets a new one
+	Generation int64 `bson:"generation"`
+}
+
+// clone copies the context, so callers can read it while the storage changes the original
+func (c *DialogContext) clone() *DialogContext {
+	copied := *c
+	copied.Messages = append([]Message(nil), c.Messages...)
+	return &copied
+}
+
+func newGeneration() int64 {
+	return time.Now().UnixNano()
 }
 
+// TokenCounter returns the number of model tokens in a text
+type TokenCounter func(text string) int
+
 type ContextStorage interface {
 	GetUserContext(userId int64) (*DialogContext, error)
 	UpdateUserContext(userId int64, message Message) error
 	SetTopic(userId int64, topic string) error
+	// SetSummary replaces the summary and removes the messages it covers, sent up to and including until;
+	// the write is dropped when the context is no longer of the generation the summary was made from
+	SetSummary(userId, generation int64, summary string, until time.Time) error
 	ClearUserContext(userId int64) error
 	Close() error
 }
+
+// messageTokens counts the text and the attached picture of a message, unless the caller counted them
+// with the model of the chat
+func messageTokens(count TokenCounter, message Message) int {
+	if message.Tokens > 0 {
+		return message.Tokens
+	}
+	tokens := count(message.Text)
+	if message.Image != "" {
+		tokens += ImageTokens
+	}
+	return tokens
+}
+
+// dropSummarized removes messages sent up to and including until and returns the remaining tokens
+func dropSummarized(messages []Message, until time.Time) ([]Message, int) {
+	kept := make([]Message, 0, len(messages))
+	tokens := 0
+	for _, message := range messages {
+		if !message.Timestamp.After(until) {
+			continue
+		}
+		kept = append(kept, message)
+		tokens += message.Tokens
+	}
+	return kept, tokens
+}
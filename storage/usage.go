@@ -0,0 +1,49 @@
+package storage
+
+import "time"
+
+// Call types of usage records
+const (
+	UsageChat          = "chat"
+	UsageIntent        = "intent"
+	UsageImage         = "image"
+	UsageAnalysis      = "analysis"
+	UsageSummarize     = "summary"
+	UsageEmbedding     = "embedding"
+	UsageTranscription = "transcription"
+	UsageSpeech        = "speech"
+)
+
+// UsageRecord is the consumption of a single paid API call
+type UsageRecord struct {
+	UserId           int64     `bson:"user_id"` // who made the request
+	ChatId           int64     `bson:"chat_id"` // where it was made, equals UserId in private chats
+	Model            string    `bson:"model"`
+	CallType         string    `bson:"call_type"`
+	PromptTokens     int       `bson:"prompt_tokens"`
+	CompletionTokens int       `bson:"completion_tokens"`
+	Images           int       `bson:"images"`
+	CreatedAt        time.Time `bson:"created_at"`
+}
+
+// UsageSummary is aggregated consumption of a user for one model and call type
+type UsageSummary struct {
+	Model            string
+	CallType         string
+	PromptTokens     int
+	CompletionTokens int
+	Images           int
+	Requests         int
+}
+
+// UsageStorage defines the interface for the usage ledger
+type UsageStorage interface {
+	// RecordUsage appends a usage record
+	RecordUsage(record *UsageRecord) error
+	// GetUsageSummary returns usage of a user in all chats since the given time grouped by model and call type
+	GetUsageSummary(userId int64, since time.Time) ([]UsageSummary, error)
+	// GetChatUsageSummary returns usage of all users in a chat since the given time grouped by model and call type
+	GetChatUsageSummary(chatId int64, since time.Time) ([]UsageSummary, error)
+	// Close closes the storage connection
+	Close() error
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// records older than this are dropped, enough to cover the current and previous month
+const usageRetention = 62 * 24 * time.Hour
+
+// MemoryUsageStorage is an in-memory implementation of UsageStorage
+type MemoryUsageStorage struct {
+	records []UsageRecord
+	mutex   sync.RWMutex
+}
+
+// NewMemoryUsageStorage creates a new in-memory usage storage
+func NewMemoryUsageStorage() *MemoryUsageStorage {
+	return &MemoryUsageStorage{}
+}
+
+// RecordUsage appends a usage record and drops expired ones
+func (m *MemoryUsageStorage) RecordUsage(record *UsageRecord) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+
+	cutoff := time.Now().Add(-usageRetention)
+	for len(m.records) > 0 && m.records[0].CreatedAt.Before(cutoff) {
+		m.records = m.records[1:]
+	}
+	m.records = append(m.records, *record)
+	return nil
+}
+
+// GetUsageSummary returns usage of a user in all chats since the given time grouped by model and call type
+func (m *MemoryUsageStorage) GetUsageSummary(userId int64, since time.Time) ([]UsageSummary, error) {
+	return m.summary(since, func(record *UsageRecord) bool {
+		return record.UserId == userId
+	}), nil
+}
+
+// GetChatUsageSummary returns usage of all users in a chat since the given time grouped by model and call type
+func (m *MemoryUsageStorage) GetChatUsageSummary(chatId int64, since time.Time) ([]UsageSummary, error) {
+	return m.summary(since, func(record *UsageRecord) bool {
+		return record.ChatId == chatId
+	}), nil
+}
+
+func (m *MemoryUsageStorage) summary(since time.Time, match func(record *UsageRecord) bool) []UsageSummary {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	type key struct{ model, callType string }
+	index := make(map[key]int)
+	var summary []UsageSummary
+
+	for i := range m.records {
+		record := &m.records[i]
+		if record.CreatedAt.Before(since) || !match(record) {
+			continue
+		}
+		k := key{record.Model, record.CallType}
+		j, ok := index[k]
+		if !ok {
+			j = len(summary)
+			index[k] = j
+			summary = append(summary, UsageSummary{Model: record.Model, CallType: record.CallType})
+		}
+		summary[j].PromptTokens += record.PromptTokens
+		summary[j].CompletionTokens += record.CompletionTokens
+		summary[j].Images += record.Images
+		summary[j].Requests++
+	}
+	return summary
+}
+
+// Close closes the storage (no-op for memory)
+func (m *MemoryUsageStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const usageCollectionName = "usage_records"
+
+// MongoUsageStorage is a MongoDB implementation of UsageStorage
+type MongoUsageStorage struct {
+	collection *mongo.Collection
+	log        *slog.Logger
+}
+
+// NewMongoUsageStorage creates a new MongoDB usage storage
+func NewMongoUsageStorage(client *mongo.Client, database string, log *slog.Logger) (*MongoUsageStorage, error) {
+	collection := client.Database(database).Collection(usageCollectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Create indexes for per-user and per-chat period queries
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "chat_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	if err != nil {
+		log.Warn("creating usage index", slog.String("error", err.Error()))
+	}
+
+	return &MongoUsageStorage{
+		collection: collection,
+		log:        log,
+	}, nil
+}
+
+// RecordUsage appends a usage record
+func (m *MongoUsageStorage) RecordUsage(record *UsageRecord) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+	_, err := m.collection.InsertOne(ctx, record)
+	return err
+}
+
+// GetUsageSummary returns usage of a user in all chats since the given time grouped by model and call type
+func (m *MongoUsageStorage) GetUsageSummary(userId int64, since time.Time) ([]UsageSummary, error) {
+	return m.summary(bson.M{"user_id": userId, "created_at": bson.M{"$gte": since}})
+}
+
+// GetChatUsageSummary returns usage of all users in a chat since the given time grouped by model and call type
+func (m *MongoUsageStorage) GetChatUsageSummary(chatId int64, since time.Time) ([]UsageSummary, error) {
+	return m.summary(bson.M{"chat_id": chatId, "created_at": bson.M{"$gte": since}})
+}
+
+func (m *MongoUsageStorage) summary(filter bson.M) ([]UsageSummary, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{
+			"_id":               bson.M{"model": "$model", "call_type": "$call_type"},
+			"prompt_tokens":     bson.M{"$sum": "$prompt_tokens"},
+			"completion_tokens": bson.M{"$sum": "$completion_tokens"},
+			"images":            bson.M{"$sum": "$images"},
+			"requests":          bson.M{"$sum": 1},
+		}}},
+	}
+
+	cursor, err := m.collection.Aggregate(ctx, pipeline, options.Aggregate())
+	if err != nil {
+		return nil, fmt.Errorf("aggregating usage: %w", err)
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		err := cursor.Close(ctx)
+		if err != nil {
+			m.log.Warn("closing cursor", slog.String("error", err.Error()))
+		}
+	}(cursor, ctx)
+
+	var summary []UsageSummary
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID struct {
+				Model    string `bson:"model"`
+				CallType string `bson:"call_type"`
+			} `bson:"_id"`
+			PromptTokens     int `bson:"prompt_tokens"`
+			CompletionTokens int `bson:"completion_tokens"`
+			Images           int `bson:"images"`
+			Requests         int `bson:"requests"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+		summary = append(summary, UsageSummary{
+			Model:            doc.ID.Model,
+			CallType:         doc.ID.CallType,
+			PromptTokens:     doc.PromptTokens,
+			CompletionTokens: doc.CompletionTokens,
+			Images:           doc.Images,
+			Requests:         doc.Requests,
+		})
+	}
+	return summary, nil
+}
+
+// Close closes the storage (client is shared, don't disconnect here)
+func (m *MongoUsageStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"time"
+)
+
+// MemoryRecord is a message with its embedding, kept for recall after it leaves the dialog context
+type MemoryRecord struct {
+	ChatId    int64     `bson:"chat_id"`
+	UserId    int64     `bson:"user_id"`
+	IsUser    bool      `bson:"is_user"`
+	Text      string    `bson:"text"`
+	Model     string    `bson:"model"` // embedding model, vectors of different models are not compared
+	Vector    []float32 `bson:"vector"`
+	Timestamp time.Time `bson:"timestamp"`
+}
+
+// ScoredMemory is a recalled record with its cosine similarity to the query
+type ScoredMemory struct {
+	MemoryRecord
+	Score float64
+}
+
+// VectorStorage defines the interface for the long-term memory of conversations
+type VectorStorage interface {
+	// SaveMemory stores the message with its embedding
+	SaveMemory(record *MemoryRecord) error
+	// SearchMemories returns up to limit records of the chat embedded by the model, most similar to the vector first;
+	// only the newest window records are compared, all of them if window is zero
+	SearchMemories(chatId int64, model string, vector []float32, limit, window int) ([]ScoredMemory, error)
+	// Close closes the storage connection
+	Close() error
+}
+
+// cosine returns the cosine similarity of two vectors, zero for vectors of different length
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// topScored collects the limit items with the highest scores, best first
+type topScored[T any] struct {
+	limit  int
+	items  []T
+	scores []float64
+}
+
+func (t *topScored[T]) add(item T, score float64) {
+	if t.limit <= 0 || len(t.items) == t.limit && score <= t.scores[len(t.scores)-1] {
+		return
+	}
+	i := sort.Search(len(t.scores), func(i int) bool { return t.scores[i] < score })
+	t.items = slices.Insert(t.items, i, item)
+	t.scores = slices.Insert(t.scores, i, score)
+	if len(t.items) > t.limit {
+		t.items, t.scores = t.items[:t.limit], t.scores[:t.limit]
+	}
+}
+
+// addMemory scores the record against the query, the vector is not returned to callers
+func addMemory(top *topScored[ScoredMemory], vector []float32, record MemoryRecord) {
+	score := cosine(vector, record.Vector)
+	record.Vector = nil
+	top.add(ScoredMemory{MemoryRecord: record, Score: score}, score)
+}
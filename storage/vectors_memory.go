@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryVectorStorage is an in-memory implementation of VectorStorage, searched by brute force
+type MemoryVectorStorage struct {
+	records map[int64][]MemoryRecord
+	mutex   sync.RWMutex
+}
+
+// NewMemoryVectorStorage creates a new in-memory vector storage
+func NewMemoryVectorStorage() *MemoryVectorStorage {
+	return &MemoryVectorStorage{
+		records: make(map[int64][]MemoryRecord),
+	}
+}
+
+// SaveMemory stores the message with its embedding
+func (m *MemoryVectorStorage) SaveMemory(record *MemoryRecord) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if record.Timestamp.IsZero() {
+		record.Timestamp = time.Now()
+	}
+	m.records[record.ChatId] = append(m.records[record.ChatId], *record)
+	return nil
+}
+
+// SearchMemories compares the vector with the newest records of the chat, records are kept in the order they came
+func (m *MemoryVectorStorage) SearchMemories(chatId int64, model string, vector []float32, limit, window int) ([]ScoredMemory, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	top := topScored[ScoredMemory]{limit: limit}
+	records := m.records[chatId]
+	compared := 0
+	for i := len(records) - 1; i >= 0 && (window <= 0 || compared < window); i-- {
+		if records[i].Model == model {
+			addMemory(&top, vector, records[i])
+			compared++
+		}
+	}
+	return top.items, nil
+}
+
+// Close closes the storage (no-op for memory)
+func (m *MemoryVectorStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const memoriesCollectionName = "memories"
+
+// MongoVectorStorage is a MongoDB implementation of VectorStorage; messages are copied with their vectors
+// into the memories collection and compared in the bot, so no vector search index is required
+type MongoVectorStorage struct {
+	collection *mongo.Collection
+	log        *slog.Logger
+}
+
+// NewMongoVectorStorage creates a new MongoDB vector storage
+func NewMongoVectorStorage(client *mongo.Client, database string, log *slog.Logger) (*MongoVectorStorage, error) {
+	collection := client.Database(database).Collection(memoriesCollectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "chat_id", Value: 1}, {Key: "model", Value: 1}, {Key: "timestamp", Value: -1}},
+	})
+	if err != nil {
+		log.Warn("creating memories index", slog.String("error", err.Error()))
+	}
+
+	return &MongoVectorStorage{
+		collection: collection,
+		log:        log,
+	}, nil
+}
+
+// SaveMemory stores the message with its embedding
+func (m *MongoVectorStorage) SaveMemory(record *MemoryRecord) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if record.Timestamp.IsZero() {
+		record.Timestamp = time.Now()
+	}
+	_, err := m.collection.InsertOne(ctx, record)
+	return err
+}
+
+// SearchMemories streams the newest records of the chat and keeps the most similar ones
+func (m *MongoVectorStorage) SearchMemories(chatId int64, model string, vector []float32, limit, window int) ([]ScoredMemory, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}})
+	if window > 0 {
+		opts.SetLimit(int64(window))
+	}
+	cursor, err := m.collection.Find(ctx, bson.M{"chat_id": chatId, "model": model}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("finding memories: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	top := topScored[ScoredMemory]{limit: limit}
+	for cursor.Next(ctx) {
+		var record MemoryRecord
+		if err := cursor.Decode(&record); err != nil {
+			return nil, fmt.Errorf("decoding memory: %w", err)
+		}
+		addMemory(&top, vector, record)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("reading memories: %w", err)
+	}
+	return top.items, nil
+}
+
+// Close closes the storage (client is shared, don't disconnect here)
+func (m *MongoVectorStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestCosine(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float32
+		want float64
+	}{
+		{"same direction", []float32{1, 2, 3}, []float32{2, 4, 6}, 1},
+		{"opposite", []float32{1, 0}, []float32{-1, 0}, -1},
+		{"orthogonal", []float32{1, 0}, []float32{0, 1}, 0},
+		{"diagonal", []float32{1, 1}, []float32{1, 0}, math.Sqrt2 / 2},
+		{"different length", []float32{1, 2}, []float32{1, 2, 3}, 0},
+		{"empty", nil, nil, 0},
+		{"zero vector", []float32{0, 0}, []float32{1, 1}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cosine(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("cosine(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTopScored(t *testing.T) {
+	tests := []struct {
+		name   string
+		limit  int
+		scores []float64
+		want   []float64
+	}{
+		{"best first", 3, []float64{0.2, 0.9, 0.5}, []float64{0.9, 0.5, 0.2}},
+		{"keeps the limit", 2, []float64{0.1, 0.7, 0.3, 0.8}, []float64{0.8, 0.7}},
+		{"worse than the last is skipped", 2, []float64{0.9, 0.8, 0.1}, []float64{0.9, 0.8}},
+		{"ties keep the earlier", 2, []float64{0.5, 0.5, 0.5}, []float64{0.5, 0.5}},
+		{"zero limit", 0, []float64{0.5}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			top := topScored[float64]{limit: tt.limit}
+			for _, score := range tt.scores {
+				top.add(score, score)
+			}
+			if !reflect.DeepEqual(top.items, tt.want) {
+				t.Errorf("items = %v, want %v", top.items, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchMemories(t *testing.T) {
+	store := NewMemoryVectorStorage()
+	records := []MemoryRecord{
+		{ChatId: 1, Text: "cats", Model: "small", Vector: []float32{1, 0, 0}},
+		{ChatId: 1, Text: "kittens", Model: "small", Vector: []float32{0.9, 0.1, 0}},
+		{ChatId: 1, Text: "cars", Model: "small", Vector: []float32{0, 1, 0}},
+		{ChatId: 1, Text: "cats of another model", Model: "large", Vector: []float32{1, 0, 0}},
+		{ChatId: 2, Text: "cats of another chat", Model: "small", Vector: []float32{1, 0, 0}},
+	}
+	for i := range records {
+		if err := store.SaveMemory(&records[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		chatId int64
+		model  string
+		vector []float32
+		limit  int
+		window int
+		want   []string
+	}{
+		{"most similar first", 1, "small", []float32{1, 0, 0}, 3, 0, []string{"cats", "kittens", "cars"}},
+		{"limit", 1, "small", []float32{0, 1, 0}, 1, 0, []string{"cars"}},
+		{"other model", 1, "large", []float32{1, 0, 0}, 5, 0, []string{"cats of another model"}},
+		{"other chat", 2, "small", []float32{1, 0, 0}, 5, 0, []string{"cats of another chat"}},
+		{"unknown chat", 3, "small", []float32{1, 0, 0}, 5, 0, nil},
+		// the window counts records of the model, newest first
+		{"window", 1, "small", []float32{1, 0, 0}, 5, 2, []string{"kittens", "cars"}},
+		{"window of one", 1, "small", []float32{1, 0, 0}, 5, 1, []string{"cars"}},
+		{"window over the records", 1, "small", []float32{1, 0, 0}, 5, 10, []string{"cats", "kittens", "cars"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := store.SearchMemories(tt.chatId, tt.model, tt.vector, tt.limit, tt.window)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var texts []string
+			for i, memory := range found {
+				texts = append(texts, memory.Text)
+				if memory.Vector != nil {
+					t.Errorf("%s returned with its vector", memory.Text)
+				}
+				if i > 0 && memory.Score > found[i-1].Score {
+					t.Errorf("%s scored %v after %v", memory.Text, memory.Score, found[i-1].Score)
+				}
+			}
+			if !reflect.DeepEqual(texts, tt.want) {
+				t.Errorf("found %v, want %v", texts, tt.want)
+			}
+		})
+	}
+}